@@ -3,25 +3,48 @@ package cam
 import (
 	"fmt"
 	"math"
-	"strings"
+	"sync/atomic"
 
 	"github.com/agdt3/goray/vec"
-	"github.com/satori/go.uuid"
 )
 
+// RayID is a compact identifier for a ray. The zero value means the ray
+// has no id (or no parent, when used as a ParentID)
+type RayID uint64
+
+// IDManager hands out unique ray ids from a counter. It is safe for
+// concurrent use and does not allocate
+type IDManager struct {
+	last uint64
+}
+
+// NewIDManager creates an id manager whose first id is 1
+func NewIDManager() *IDManager {
+	return new(IDManager)
+}
+
+// Next returns the next unused id
+func (m *IDManager) Next() RayID {
+	return RayID(atomic.AddUint64(&m.last, 1))
+}
+
+// defaultIDs is used by rays that are not given an explicit id
+var defaultIDs = NewIDManager()
+
 // Ray contains the basic paramters for a ray in a scene
 type Ray struct {
-	ID        string
+	ID        RayID
+	ParentID  RayID
 	Type      string
 	Origin    vec.Vec3
 	Direction vec.Vec3
 }
 
-// NewRay is a constructor of Rays
-func NewRay(id, typ string, orig, dir *vec.Vec3) *Ray {
+// NewRay is a constructor of Rays. An id of 0 generates a new id
+func NewRay(id RayID, typ string, orig, dir *vec.Vec3) *Ray {
 	ray := new(Ray)
-	if id == "" {
-		ray.ID = GenerateID(nil)
+	if id == 0 {
+		ray.ID = GenerateID()
 	} else {
 		ray.ID = id
 	}
@@ -31,16 +54,18 @@ func NewRay(id, typ string, orig, dir *vec.Vec3) *Ray {
 	return ray
 }
 
-// GenerateID creates chains of uuids, based on parent ID
-func GenerateID(parent *Ray) string {
-	// TODO: Replace with shorter string id manager
-	// Otherwise id chains will be very long
-	id := uuid.NewV4().String()
-	if parent != nil {
-		ids := []string{parent.ID, id}
-		id = strings.Join(ids, "|")
-	}
-	return id
+// NewChildRay is a constructor of Rays spawned by another ray, such as
+// reflections and refractions. The parent id is kept so the chain of rays
+// can be reconstructed
+func NewChildRay(parent *Ray, typ string, orig, dir *vec.Vec3) *Ray {
+	ray := NewRay(0, typ, orig, dir)
+	ray.ParentID = parent.ID
+	return ray
+}
+
+// GenerateID returns a new id from the default id manager
+func GenerateID() RayID {
+	return defaultIDs.Next()
 }
 
 // String is the string representation of a Ray
@@ -51,7 +76,7 @@ func (r *Ray) String() string {
 		r.Direction.X, r.Direction.Y, r.Direction.Z)
 }
 
-// IsEqual is a comparator of Rays. It ignores the ID
+// IsEqual is a comparator of Rays. It ignores the ID and ParentID
 func IsEqual(r1, r2 *Ray) bool {
 	// Note: Does not consider ID as unique value
 	if r1.Type != r2.Type {
@@ -2,13 +2,20 @@ package files
 
 import (
 	"bufio"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"image/color"
+	"image/png"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/agdt3/goray/obj"
+	"github.com/agdt3/goray/vec"
 )
 
 // ReadMeshFiles reads a lot of mesh files and creates
@@ -149,16 +156,25 @@ func parseWavLine(line, sep string, poly *obj.PolygonMesh) {
 		poly.Verticies = append(poly.Verticies, verticies...)
 		//poly.NumVerticies = append(poly.NumVerticies, len(verticies))
 	case "vt":
-		fmt.Println("a texture vertex")
-		fmt.Println(stringToFloat64Array(values))
+		// Texture verticies are stored as u, v pairs. The optional w is
+		// dropped and a missing v is 0
+		uvw := stringToFloat64Array(values)
+		uv := make([]float64, 2, 2)
+		copy(uv, uvw)
+		poly.TextureVertecies = append(poly.TextureVertecies, uv...)
 	case "vn":
-		fmt.Println("a vertex normal")
-		fmt.Println(stringToFloat64Array(values))
+		normals := stringToFloat64Array(values)
+		poly.VertexNormals = append(poly.VertexNormals, normals...)
 	case "f":
 		poly.NumFaces[0] += 1
-		vertexIndecies, _, _ := extractIndecies(values)
+		vertexIndecies, textureIndecies, normalIndecies := extractIndecies(values)
 		poly.VertexIndecies = append(poly.VertexIndecies, vertexIndecies...)
 		poly.NumVerticies = append(poly.NumVerticies, len(vertexIndecies))
+
+		// Keep texture and normal indecies lined up with vertex indecies,
+		// even for faces without them
+		poly.TextureIndecies = append(poly.TextureIndecies, alignIndecies(textureIndecies, len(vertexIndecies))...)
+		poly.NormalIndecies = append(poly.NormalIndecies, alignIndecies(normalIndecies, len(vertexIndecies))...)
 	default:
 		//Do nothing
 	}
@@ -182,14 +198,14 @@ func extractIndecies(faceValues []string) ([]int, []int, []int) {
 			// vertexIndecies are normalized to start at 0
 			// blender export starts them at 1
 			vertexIndecies = append(vertexIndecies, valuesInt[0]-1)
-			textureIndecies = append(textureIndecies, valuesInt[1])
-			vertexNormalIndecies = append(vertexNormalIndecies, valuesInt[2])
+			textureIndecies = append(textureIndecies, valuesInt[1]-1)
+			vertexNormalIndecies = append(vertexNormalIndecies, valuesInt[2]-1)
 		} else if len(valuesString) == 3 && valuesString[1] == "" {
 			vertexIndecies = append(vertexIndecies, valuesInt[0]-1)
-			vertexNormalIndecies = append(vertexNormalIndecies, valuesInt[2])
+			vertexNormalIndecies = append(vertexNormalIndecies, valuesInt[2]-1)
 		} else if len(valuesString) == 2 {
 			vertexIndecies = append(vertexIndecies, valuesInt[0]-1)
-			textureIndecies = append(textureIndecies, valuesInt[1])
+			textureIndecies = append(textureIndecies, valuesInt[1]-1)
 		} else {
 			vertexIndecies = append(vertexIndecies, valuesInt[0]-1)
 		}
@@ -197,3 +213,569 @@ func extractIndecies(faceValues []string) ([]int, []int, []int) {
 
 	return vertexIndecies, textureIndecies, vertexNormalIndecies
 }
+
+// alignIndecies returns the per-vertex indecies of a face, or -1 for every
+// vertex when the face does not have one for each
+func alignIndecies(indecies []int, numVerticies int) []int {
+	if len(indecies) == numVerticies {
+		return indecies
+	}
+	aligned := make([]int, numVerticies, numVerticies)
+	for i := range aligned {
+		aligned[i] = -1
+	}
+	return aligned
+}
+
+// ReadBezierFile reads bicubic patches from a .bpt file. The file starts
+// with the number of patches, and each patch is its degree along u and v,
+// which must be 3 3, followed by its 16 control points one per line
+func ReadBezierFile(path string) ([]obj.BezierPatch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	nextFields := func() ([]string, error) {
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+				return fields, nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	fields, err := nextFields()
+	if err != nil {
+		return nil, err
+	}
+	numPatches, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	if numPatches < 0 {
+		return nil, fmt.Errorf("patch count %d is negative", numPatches)
+	}
+
+	// The count is not trusted for the allocation, so a file that claims
+	// more patches than it holds fails at its end instead
+	patches := make([]obj.BezierPatch, 0)
+	for k := 0; k < numPatches; k++ {
+		patches = append(patches, obj.BezierPatch{})
+		fields, err := nextFields()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 2 || fields[0] != "3" || fields[1] != "3" {
+			return nil, fmt.Errorf("patch %d is not bicubic", k)
+		}
+
+		for i := range patches[k].Control {
+			fields, err := nextFields()
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("patch %d has a malformed control point", k)
+			}
+			var p [3]float64
+			for c := range p {
+				if p[c], err = strconv.ParseFloat(fields[c], 64); err != nil {
+					return nil, fmt.Errorf("patch %d: %v", k, err)
+				}
+			}
+			patches[k].Control[i] = *vec.NewVec3(p[0], p[1], p[2])
+		}
+	}
+	return patches, nil
+}
+
+// ReadHeightMap reads a grayscale elevation image into a height map. Files
+// ending in .pgm are read as binary (P5) or plain (P2) portable graymaps,
+// anything else is decoded as a PNG
+func ReadHeightMap(path string) (*obj.HeightMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(path)) != ".pgm" {
+		img, err := png.Decode(file)
+		if err != nil {
+			return nil, err
+		}
+		return obj.NewHeightMap(img), nil
+	}
+	return readPGM(bufio.NewReader(file))
+}
+
+// readPGM parses a portable graymap. The header is the magic number, the
+// width, the height and the largest value, separated by whitespace and
+// comments starting with #
+func readPGM(reader *bufio.Reader) (*obj.HeightMap, error) {
+	nextToken := func() (string, error) {
+		var token []byte
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				if err == io.EOF && len(token) > 0 {
+					return string(token), nil
+				}
+				return "", err
+			}
+			switch {
+			case b == '#' && len(token) == 0:
+				if _, err := reader.ReadString('\n'); err != nil {
+					return "", err
+				}
+			case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+				if len(token) > 0 {
+					return string(token), nil
+				}
+			default:
+				token = append(token, b)
+			}
+		}
+	}
+
+	magic, err := nextToken()
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P2" && magic != "P5" {
+		return nil, fmt.Errorf("not a PGM file: %q", magic)
+	}
+
+	var header [3]int
+	for i := range header {
+		token, err := nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if header[i], err = strconv.Atoi(token); err != nil {
+			return nil, err
+		}
+	}
+	width, height, maxValue := header[0], header[1], header[2]
+	if width < 1 || height < 1 || maxValue < 1 || maxValue > 65535 {
+		return nil, fmt.Errorf("malformed PGM header %dx%d max %d", width, height, maxValue)
+	}
+	if uint64(width)*uint64(height) > 1<<30 {
+		return nil, fmt.Errorf("PGM image %dx%d is too large", width, height)
+	}
+
+	// The size is not trusted for the allocation, so a file that claims
+	// more samples than it holds fails at its end instead
+	heights := &obj.HeightMap{Width: width, Height: height, Heights: make([]float64, 0)}
+	for i := 0; i < width*height; i++ {
+		var value int
+		if magic == "P2" {
+			token, err := nextToken()
+			if err != nil {
+				return nil, err
+			}
+			if value, err = strconv.Atoi(token); err != nil {
+				return nil, err
+			}
+		} else {
+			// Binary samples are one byte, or two big endian bytes when
+			// the largest value needs them
+			size := 1
+			if maxValue > 255 {
+				size = 2
+			}
+			var sample [2]byte
+			if _, err := io.ReadFull(reader, sample[:size]); err != nil {
+				return nil, err
+			}
+			value = int(sample[0])
+			if size == 2 {
+				value = value<<8 | int(sample[1])
+			}
+		}
+		heights.Heights = append(heights.Heights, float64(value)/float64(maxValue))
+	}
+	return heights, nil
+}
+
+// ReadCurveFile reads curves from a .curve file, one per line. Each line
+// is the cross section (tube or ribbon), the degree (1 for a line or 3
+// for a cubic Bezier), the widths at the start and end and then the
+// control points. Lines starting with # are comments. The curves are
+// named after their line in the file and share a color, and can be put in
+// a BVH together
+func ReadCurveFile(path string, col color.RGBA, refractive float64) ([]obj.Object, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	curves := make([]obj.Object, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: malformed curve", line)
+		}
+
+		var curveType obj.CurveType
+		switch fields[0] {
+		case "tube":
+			curveType = obj.CurveTube
+		case "ribbon":
+			curveType = obj.CurveRibbon
+		default:
+			return nil, fmt.Errorf("line %d: unknown curve type %q", line, fields[0])
+		}
+
+		var numPoints int
+		switch fields[1] {
+		case "1":
+			numPoints = 2
+		case "3":
+			numPoints = 4
+		default:
+			return nil, fmt.Errorf("line %d: curves must be degree 1 or 3", line)
+		}
+		if len(fields) != 4+numPoints*3 {
+			return nil, fmt.Errorf("line %d: expected %d control points", line, numPoints)
+		}
+
+		values := make([]float64, len(fields)-2)
+		for i, f := range fields[2:] {
+			if values[i], err = strconv.ParseFloat(f, 64); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		control := make([]vec.Vec3, numPoints)
+		for i := range control {
+			control[i] = *vec.NewVec3(values[2+i*3], values[3+i*3], values[4+i*3])
+		}
+
+		curve, err := obj.NewCurve(fmt.Sprintf("curve%d", line), control, [2]float64{values[0], values[1]}, curveType, col, refractive)
+		if err != nil {
+			return nil, err
+		}
+		curves = append(curves, curve)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return curves, nil
+}
+
+// ReadXYZFile reads a point cloud from an .xyz file with one point per
+// line. Each line is the position, optionally followed by either the
+// normal or an 8 bit red, green and blue color, or by the normal and then
+// the color. Every line must have as many values as the first. Six values
+// are read as a normal when all of the last three lie between -1 and 1,
+// and otherwise as a color, as in the common XYZRGB layout. Lines
+// starting with # are comments
+func ReadXYZFile(path string, set *obj.PointSet) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	columns := 0
+	rows := make([][]float64, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if columns == 0 {
+			if len(fields) != 3 && len(fields) != 6 && len(fields) != 9 {
+				return fmt.Errorf("line %d: expected 3, 6 or 9 values", line)
+			}
+			columns = len(fields)
+		} else if len(fields) != columns {
+			return fmt.Errorf("line %d: expected %d values like the first line, got %d", line, columns, len(fields))
+		}
+
+		values := make([]float64, len(fields))
+		for i, f := range fields {
+			if values[i], err = strconv.ParseFloat(f, 64); err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		rows = append(rows, values)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Six values are a normal only if every line could hold one
+	sixNormals := columns == 6
+	for _, values := range rows {
+		for _, v := range values[3:] {
+			if columns == 6 && (v < -1 || v > 1) {
+				sixNormals = false
+			}
+		}
+	}
+
+	for _, values := range rows {
+		set.Points = append(set.Points, values[:3]...)
+		switch {
+		case columns == 6 && sixNormals:
+			set.Normals = append(set.Normals, values[3:6]...)
+		case columns == 6:
+			set.Colors = append(set.Colors, color.RGBA{
+				colorChannel(values[3]), colorChannel(values[4]), colorChannel(values[5]), 255})
+		case columns == 9:
+			set.Normals = append(set.Normals, values[3:6]...)
+			set.Colors = append(set.Colors, color.RGBA{
+				colorChannel(values[6]), colorChannel(values[7]), colorChannel(values[8]), 255})
+		}
+	}
+	return nil
+}
+
+// plyProperty is a scalar property of the vertex element of a PLY file
+type plyProperty struct {
+	name     string
+	dataType string
+}
+
+// plySizes are the sizes in bytes of the PLY scalar types
+var plySizes = map[string]int{
+	"char": 1, "uchar": 1, "int8": 1, "uint8": 1,
+	"short": 2, "ushort": 2, "int16": 2, "uint16": 2,
+	"int": 4, "uint": 4, "int32": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8}
+
+// ReadPLYFile reads the vertex element of an ASCII or binary PLY file
+// into a point set. The positions x, y and z are required, the normals
+// nx, ny and nz and the colors red, green and blue are read when the file
+// has them. Any other elements, such as faces, must come after the
+// verticies and are ignored
+func ReadPLYFile(path string, set *obj.PointSet) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	var format string
+	var numPoints int
+	var properties []plyProperty
+	inVertex := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "end_header" {
+			break
+		}
+
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return errors.New("malformed PLY format")
+			}
+			format = fields[1]
+		case "element":
+			if len(fields) < 3 {
+				return errors.New("malformed PLY element")
+			}
+			if inVertex = fields[1] == "vertex"; inVertex {
+				if format == "" || properties != nil || numPoints != 0 {
+					return errors.New("PLY verticies must be the first element")
+				}
+				if numPoints, err = strconv.Atoi(fields[2]); err != nil {
+					return err
+				}
+				if numPoints < 0 {
+					return fmt.Errorf("PLY vertex count %d is negative", numPoints)
+				}
+			} else if numPoints == 0 {
+				return errors.New("PLY verticies must be the first element")
+			}
+		case "property":
+			if !inVertex {
+				continue
+			}
+			if len(fields) != 3 || plySizes[fields[1]] == 0 {
+				return fmt.Errorf("unsupported PLY vertex property %q", strings.TrimSpace(line))
+			}
+			properties = append(properties, plyProperty{fields[2], fields[1]})
+		}
+	}
+
+	var order binary.ByteOrder
+	switch format {
+	case "ascii":
+	case "binary_little_endian":
+		order = binary.LittleEndian
+	case "binary_big_endian":
+		order = binary.BigEndian
+	default:
+		return fmt.Errorf("unsupported PLY format %q", format)
+	}
+
+	index := make(map[string]int)
+	for i, p := range properties {
+		index[p.name] = i
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("PLY verticies have no %s", name)
+		}
+	}
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := index[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	hasNormals := has("nx", "ny", "nz")
+	hasColors := has("red", "green", "blue")
+
+	values := make([]float64, len(properties))
+	for i := 0; i < numPoints; i++ {
+		if order == nil {
+			line, err := reader.ReadString('\n')
+			fields := strings.Fields(line)
+			if len(fields) < len(properties) {
+				if err != nil {
+					return err
+				}
+				return fmt.Errorf("PLY vertex %d has too few values", i)
+			}
+			for k := range properties {
+				if values[k], err = strconv.ParseFloat(fields[k], 64); err != nil {
+					return err
+				}
+			}
+		} else {
+			for k, p := range properties {
+				if values[k], err = readPLYValue(reader, order, p.dataType); err != nil {
+					return err
+				}
+			}
+		}
+
+		set.Points = append(set.Points, values[index["x"]], values[index["y"]], values[index["z"]])
+		if hasNormals {
+			set.Normals = append(set.Normals, values[index["nx"]], values[index["ny"]], values[index["nz"]])
+		}
+		if hasColors {
+			// Integer colors are 8 bit, floating point colors run from 0 to 1
+			channel := func(name string) uint8 {
+				v := values[index[name]]
+				if t := properties[index[name]].dataType; t == "float" || t == "float32" || t == "double" || t == "float64" {
+					v *= 255
+				}
+				return colorChannel(v)
+			}
+			set.Colors = append(set.Colors, color.RGBA{channel("red"), channel("green"), channel("blue"), 255})
+		}
+	}
+	return nil
+}
+
+// colorChannel rounds an 8 bit color value read from a file, clamping it
+// to 0 to 255
+func colorChannel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// readPLYValue reads one binary PLY scalar of the given type
+func readPLYValue(reader io.Reader, order binary.ByteOrder, dataType string) (float64, error) {
+	var buf [8]byte
+	b := buf[:plySizes[dataType]]
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return 0, err
+	}
+	switch dataType {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(order.Uint32(b))), nil
+	}
+	return math.Float64frombits(order.Uint64(b)), nil
+}
+
+// ReadVoxelGrid reads a dense density grid from a .vgrid file. The file
+// starts with the four bytes VGRD and the number of voxels along x, y and
+// z as little endian uint32s, followed by every density as a little
+// endian float32 with x varying fastest, then y, then z
+func ReadVoxelGrid(path string) (*obj.VoxelGrid, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(reader, magic[:]); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "VGRD" {
+		return nil, errors.New("not a voxel grid file")
+	}
+
+	var size [3]uint32
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	// The size is only trusted as far as the file holds the densities
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	limit := uint64(info.Size()-16) / 4
+	slice := uint64(size[0]) * uint64(size[1])
+	if slice != 0 && uint64(size[2]) > limit/slice {
+		return nil, fmt.Errorf("voxel grid %dx%dx%d is larger than its file", size[0], size[1], size[2])
+	}
+	count := slice * uint64(size[2])
+
+	// Densities are read in chunks so the float32s are never all held
+	// alongside the float64s
+	densities := make([]float64, 0, count)
+	values := make([]float32, 4096)
+	for remaining := count; remaining > 0; {
+		chunk := values[:int(math.Min(float64(remaining), float64(len(values))))]
+		if err := binary.Read(reader, binary.LittleEndian, chunk); err != nil {
+			return nil, err
+		}
+		for _, v := range chunk {
+			densities = append(densities, float64(v))
+		}
+		remaining -= uint64(len(chunk))
+	}
+	return obj.NewVoxelGrid(int(size[0]), int(size[1]), int(size[2]), densities)
+}
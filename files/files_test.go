@@ -0,0 +1,384 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agdt3/goray/obj"
+	"github.com/agdt3/goray/vec"
+)
+
+// writeFixture writes data to a file called name in a temporary directory
+// and returns its path
+func writeFixture(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadXYZFile(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		data       string
+		numPoints  int
+		numNormals int
+		numColors  int
+	}{
+		{"# points\n0 0 0\n1 2 3\n", 2, 0, 0},
+		{"0 0 0 0 0 1\n1 2 3 0 -1 0\n", 2, 2, 0},
+		{"0 0 0 255 0 0\n1 2 3 0 0.5 1\n", 2, 0, 2},
+		{"0 0 0 0 0 1 255 128 0\n", 1, 1, 1},
+	}
+	for _, test := range tests {
+		set := new(obj.PointSet)
+		if err := ReadXYZFile(writeFixture(t, "points.xyz", []byte(test.data)), set); err != nil {
+			t.Fatal(err)
+		}
+		if set.NumPoints() != test.numPoints || len(set.Normals)/3 != test.numNormals || len(set.Colors) != test.numColors {
+			t.Error("Point set not read correctly", test.data, set.NumPoints(), len(set.Normals)/3, len(set.Colors))
+		}
+	}
+
+	// XYZRGB colors land in the color buffer
+	set := new(obj.PointSet)
+	if err := ReadXYZFile(writeFixture(t, "rgb.xyz", []byte("0 0 0 255 128 0\n")), set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Colors[0] != (color.RGBA{255, 128, 0, 255}) {
+		t.Error("XYZRGB color not correct", set.Colors[0])
+	}
+
+	// Colors out of range are clamped, and fractions rounded
+	set = new(obj.PointSet)
+	if err := ReadXYZFile(writeFixture(t, "clamp.xyz", []byte("0 0 0 300 -1 127.6\n")), set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Colors[0] != (color.RGBA{255, 0, 128, 255}) {
+		t.Error("XYZRGB color should be clamped and rounded", set.Colors[0])
+	}
+	set = new(obj.PointSet)
+	if err := ReadXYZFile(writeFixture(t, "clamp.xyz", []byte("0 0 0 0 0 1 1e9 -1e9 2.4\n")), set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Colors[0] != (color.RGBA{255, 0, 2, 255}) {
+		t.Error("XYZ normal and color should be clamped and rounded", set.Colors[0])
+	}
+
+	// Lines must all have as many values as the first
+	for _, data := range []string{"0 0 0\n1 1 1 0 0 1\n", "0 0 0 0 0 1 255 0 0\n1 1 1 0 0 1\n", "0 0\n", "0 0 x\n"} {
+		err := ReadXYZFile(writeFixture(t, "bad.xyz", []byte(data)), new(obj.PointSet))
+		if err == nil || !strings.HasPrefix(err.Error(), "line ") {
+			t.Error("Malformed file should fail with the line number", data, err)
+		}
+	}
+}
+
+// plyHeader returns a PLY header for count verticies with positions,
+// normals and colors of the given type
+func plyHeader(format string, count int, colorType string) string {
+	return "ply\nformat " + format + " 1.0\ncomment test\n" +
+		"element vertex " + strconv.Itoa(count) + "\n" +
+		"property float x\nproperty float y\nproperty float z\n" +
+		"property float nx\nproperty float ny\nproperty float nz\n" +
+		"property " + colorType + " red\nproperty " + colorType + " green\nproperty " + colorType + " blue\n" +
+		"element face 0\nproperty list uchar int vertex_indices\nend_header\n"
+}
+
+// plyBinary returns a binary PLY file of two verticies with uchar colors
+func plyBinary(format string, order binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(plyHeader(format, 2, "uchar"))
+	for _, v := range [][9]float32{{1, 2, 3, 0, 0, 1, 255, 128, 0}, {-1, -2, -3, 0, 1, 0, 0, 0, 255}} {
+		binary.Write(&buf, order, v[:6])
+		buf.Write([]byte{uint8(v[6]), uint8(v[7]), uint8(v[8])})
+	}
+	return buf.Bytes()
+}
+
+func TestReadPLYFile(t *testing.T) {
+	t.Parallel()
+
+	ascii := plyHeader("ascii", 2, "uchar") + "1 2 3 0 0 1 255 128 0\n-1 -2 -3 0 1 0 0 0 255\n"
+	floats := plyHeader("ascii", 2, "float") + "1 2 3 0 0 1 1 0.5 0\n-1 -2 -3 0 1 0 0 0 1\n"
+	var tests = []struct {
+		name  string
+		data  []byte
+		green uint8
+	}{
+		{"ascii", []byte(ascii), 128},
+		{"float colors", []byte(floats), 128},
+		{"little endian", plyBinary("binary_little_endian", binary.LittleEndian), 128},
+		{"big endian", plyBinary("binary_big_endian", binary.BigEndian), 128},
+	}
+	for _, test := range tests {
+		set := new(obj.PointSet)
+		if err := ReadPLYFile(writeFixture(t, "points.ply", test.data), set); err != nil {
+			t.Fatal(test.name, err)
+		}
+		if set.NumPoints() != 2 || len(set.Normals) != 6 || len(set.Colors) != 2 {
+			t.Fatal("Point set not read correctly", test.name, set.NumPoints(), len(set.Normals), len(set.Colors))
+		}
+		if set.Points[0] != 1 || set.Points[5] != -3 || set.Normals[2] != 1 || set.Normals[4] != 1 {
+			t.Error("Positions or normals not correct", test.name, set.Points, set.Normals)
+		}
+		if set.Colors[0] != (color.RGBA{255, test.green, 0, 255}) || set.Colors[1] != (color.RGBA{0, 0, 255, 255}) {
+			t.Error("Colors not correct", test.name, set.Colors)
+		}
+	}
+
+	little := plyBinary("binary_little_endian", binary.LittleEndian)
+	var bad = []struct {
+		name string
+		data []byte
+	}{
+		{"truncated binary", little[:len(little)-5]},
+		{"truncated ascii", []byte(plyHeader("ascii", 3, "uchar") + "1 2 3 0 0 1 255 128 0\n")},
+		{"short ascii line", []byte(plyHeader("ascii", 1, "uchar") + "1 2 3\n")},
+		{"negative count", []byte(plyHeader("ascii", -1, "uchar"))},
+		{"unknown format", []byte(plyHeader("binary_middle_endian", 1, "uchar") + "1 2 3 0 0 1 255 128 0\n")},
+		{"unknown type", []byte(plyHeader("ascii", 1, "rgb") + "1 2 3 0 0 1 255 128 0\n")},
+		{"no header end", []byte("ply\nformat ascii 1.0\nelement vertex 1\n")},
+		{"face first", []byte("ply\nformat ascii 1.0\nelement face 1\nelement vertex 1\nend_header\n")},
+		{"no positions", []byte("ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n1\n")},
+	}
+	for _, test := range bad {
+		if err := ReadPLYFile(writeFixture(t, "bad.ply", test.data), new(obj.PointSet)); err == nil {
+			t.Error("Malformed PLY file should fail", test.name)
+		}
+	}
+}
+
+func TestReadPLYValue(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		dataType string
+		data     []byte
+		order    binary.ByteOrder
+		expected float64
+	}{
+		{"char", []byte{0xff}, binary.LittleEndian, -1},
+		{"uchar", []byte{0xff}, binary.LittleEndian, 255},
+		{"short", []byte{0xfe, 0xff}, binary.LittleEndian, -2},
+		{"ushort", []byte{0x01, 0x02}, binary.BigEndian, 258},
+		{"int32", []byte{0xff, 0xff, 0xff, 0xfd}, binary.BigEndian, -3},
+		{"uint", []byte{0x00, 0x00, 0x01, 0x00}, binary.LittleEndian, 65536},
+		{"float", []byte{0x3f, 0xc0, 0x00, 0x00}, binary.BigEndian, 1.5},
+		{"double", []byte{0, 0, 0, 0, 0, 0, 0x04, 0xc0}, binary.LittleEndian, -2.5},
+	}
+	for _, test := range tests {
+		v, err := readPLYValue(bytes.NewReader(test.data), test.order, test.dataType)
+		if err != nil || v != test.expected {
+			t.Error("PLY value not read correctly", test.dataType, v, err)
+		}
+		if _, err := readPLYValue(bytes.NewReader(test.data[1:]), test.order, test.dataType); err == nil {
+			t.Error("Truncated PLY value should fail", test.dataType)
+		}
+	}
+}
+
+func TestReadCurveFile(t *testing.T) {
+	t.Parallel()
+
+	data := "# hair\ntube 3 0.1 0.05 0 0 0 1 0 0 1 1 0 2 1 0\n\nribbon 1 0.2 0.2 0 0 0 0 1 0\n"
+	col := color.RGBA{64, 64, 64, 1}
+	curves, err := ReadCurveFile(writeFixture(t, "hair.crv", []byte(data)), col, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(curves) != 2 {
+		t.Fatal("Two curves should be read", len(curves))
+	}
+
+	tube, ribbon := curves[0].(*obj.Curve), curves[1].(*obj.Curve)
+	if tube.Type != obj.CurveTube || len(tube.Control) != 4 || tube.Widths != [2]float64{0.1, 0.05} ||
+		tube.Control[3] != *vec.NewVec3(2, 1, 0) || tube.GetColor() != col {
+		t.Error("Cubic tube not read correctly", tube.Type, tube.Control, tube.Widths)
+	}
+	if ribbon.Type != obj.CurveRibbon || len(ribbon.Control) != 2 || ribbon.Control[1] != *vec.NewVec3(0, 1, 0) {
+		t.Error("Linear ribbon not read correctly", ribbon.Type, ribbon.Control)
+	}
+	if tube.GetID() == ribbon.GetID() {
+		t.Error("Curves should have their own IDs", tube.GetID())
+	}
+
+	for _, data := range []string{
+		"tube 3 0.1\n",
+		"rope 1 0.1 0.1 0 0 0 0 1 0\n",
+		"tube 2 0.1 0.1 0 0 0 0 1 0\n",
+		"tube 3 0.1 0.1 0 0 0 0 1 0\n",
+		"tube 1 0.1 0.1 0 0 0 0 1 x\n",
+	} {
+		_, err := ReadCurveFile(writeFixture(t, "bad.crv", []byte("# bad\n"+data)), col, 1)
+		if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Error("Malformed curve should fail with the line number", data, err)
+		}
+	}
+}
+
+// bezierPatch returns a .bpt patch whose control points lie on the grid
+// x = i, y = j at height z
+func bezierPatch(z int) string {
+	patch := "3 3\n"
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			patch += strconv.Itoa(i) + " " + strconv.Itoa(j) + " " + strconv.Itoa(z) + "\n"
+		}
+	}
+	return patch
+}
+
+func TestReadBezierFile(t *testing.T) {
+	t.Parallel()
+
+	patches, err := ReadBezierFile(writeFixture(t, "teapot.bpt", []byte("2\n"+bezierPatch(0)+"\n"+bezierPatch(1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 {
+		t.Fatal("Two patches should be read", len(patches))
+	}
+	if patches[0].Control[5] != *vec.NewVec3(1, 1, 0) || patches[1].Control[15] != *vec.NewVec3(3, 3, 1) {
+		t.Error("Control points not read correctly", patches[0].Control[5], patches[1].Control[15])
+	}
+
+	for _, data := range []string{
+		"",
+		"x\n",
+		"-1\n",
+		"1000000000000\n" + bezierPatch(0),
+		"1\n3 2\n",
+		"1\n" + bezierPatch(0)[:40],
+		"1\n3 3\n0 0\n",
+		"1\n3 3\n0 0 x\n" + bezierPatch(0)[4+6:],
+	} {
+		if _, err := ReadBezierFile(writeFixture(t, "bad.bpt", []byte(data))); err == nil {
+			t.Error("Malformed patch file should fail", data)
+		}
+	}
+}
+
+func TestReadPGM(t *testing.T) {
+	t.Parallel()
+
+	binary16 := append([]byte("P5 2 1 1000\n"), 0x01, 0xf4, 0x03, 0xe8)
+	var tests = []struct {
+		name     string
+		data     []byte
+		width    int
+		height   int
+		expected []float64
+	}{
+		{"plain", []byte("P2\n# terrain\n3 2\n4\n0 1 2\n3 4 4"), 3, 2, []float64{0, 0.25, 0.5, 0.75, 1, 1}},
+		{"binary", append([]byte("P5\n2 2 255\n"), 0, 51, 255, 102), 2, 2, []float64{0, 0.2, 1, 0.4}},
+		{"binary 16 bit", binary16, 2, 1, []float64{0.5, 1}},
+	}
+	for _, test := range tests {
+		heights, err := readPGM(bufio.NewReader(bytes.NewReader(test.data)))
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if heights.Width != test.width || heights.Height != test.height || len(heights.Heights) != len(test.expected) {
+			t.Fatal("Size not read correctly", test.name, heights.Width, heights.Height)
+		}
+		for i := range test.expected {
+			if math.Abs(heights.Heights[i]-test.expected[i]) > 1e-9 {
+				t.Error("Height not read correctly", test.name, i, heights.Heights[i])
+			}
+		}
+	}
+
+	for _, data := range []string{
+		"P6 1 1 255\n\x00\x00\x00",
+		"P2 2 x 255\n0 0\n",
+		"P2 0 1 255\n",
+		"P2 1 1 70000\n0\n",
+		"P5 100000 100000 255\n",
+		"P5 30000 30000 255\n\x00",
+		"P2 2 2 255\n0 1 2\n",
+		"P2 1 1 255\nz\n",
+		"P5 2 2 255\n\x00\x01\x02",
+		"P5 1 1 1000\n\x01",
+	} {
+		if _, err := readPGM(bufio.NewReader(strings.NewReader(data))); err == nil {
+			t.Errorf("Malformed PGM should fail %q", data)
+		}
+	}
+
+	// ReadHeightMap picks the reader by extension
+	heights, err := ReadHeightMap(writeFixture(t, "hill.pgm", []byte("P2 2 2 2\n0 1 1 2\n")))
+	if err != nil || heights.Heights[3] != 1 {
+		t.Error("PGM height map not read correctly", err)
+	}
+	if _, err := ReadHeightMap(writeFixture(t, "hill.png", []byte("P2 2 2 2\n0 1 1 2\n"))); err == nil {
+		t.Error("Other extensions should be decoded as PNG")
+	}
+}
+
+// voxelGrid returns a .vgrid file with the given size and densities
+func voxelGrid(magic string, size [3]uint32, densities []float32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	binary.Write(&buf, binary.LittleEndian, size)
+	binary.Write(&buf, binary.LittleEndian, densities)
+	return buf.Bytes()
+}
+
+func TestReadVoxelGrid(t *testing.T) {
+	t.Parallel()
+
+	densities := []float32{0, 0.5, 1, 2, 0, 0, 0.25, 0}
+	grid, err := ReadVoxelGrid(writeFixture(t, "smoke.vgrid", voxelGrid("VGRD", [3]uint32{2, 2, 2}, densities)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid.Nx != 2 || grid.Ny != 2 || grid.Nz != 2 || grid.MaxDensity != 2 {
+		t.Error("Voxel grid size not read correctly", grid.Nx, grid.Ny, grid.Nz, grid.MaxDensity)
+	}
+	for i, d := range densities {
+		if grid.Densities[i] != float64(d) {
+			t.Error("Density not read correctly", i, grid.Densities[i])
+		}
+	}
+
+	// Grids larger than one chunk of densities
+	large := make([]float32, 20*20*20)
+	for i := range large {
+		large[i] = float32(i)
+	}
+	grid, err = ReadVoxelGrid(writeFixture(t, "large.vgrid", voxelGrid("VGRD", [3]uint32{20, 20, 20}, large)))
+	if err != nil || len(grid.Densities) != len(large) || grid.Densities[7999] != 7999 || grid.Densities[4096] != 4096 {
+		t.Error("Large voxel grid not read correctly", err)
+	}
+
+	full := voxelGrid("VGRD", [3]uint32{2, 2, 2}, densities)
+	var bad = []struct {
+		name string
+		data []byte
+	}{
+		{"bad magic", voxelGrid("VGRX", [3]uint32{2, 2, 2}, densities)},
+		{"short magic", []byte("VG")},
+		{"truncated size", full[:10]},
+		{"truncated densities", full[:len(full)-2]},
+		{"empty grid", voxelGrid("VGRD", [3]uint32{0, 2, 2}, nil)},
+		{"too large", voxelGrid("VGRD", [3]uint32{1 << 11, 1 << 11, 1 << 11}, nil)},
+		{"larger than file", voxelGrid("VGRD", [3]uint32{1 << 10, 1 << 10, 1 << 10}, densities)},
+		{"overflowing size", voxelGrid("VGRD", [3]uint32{1<<32 - 1, 1<<32 - 1, 1<<32 - 1}, densities)},
+	}
+	for _, test := range bad {
+		if _, err := ReadVoxelGrid(writeFixture(t, "bad.vgrid", test.data)); err == nil {
+			t.Error("Malformed voxel grid should fail", test.name)
+		}
+	}
+}
@@ -13,15 +13,21 @@ import (
 	"image/draw"
 	"image/jpeg"
 	"math"
+	"math/rand"
 	"os"
 )
 
 const (
 	INF_DIST float64 = 100000
 	//MESH_FILE_PATH string  = "./res/meshes/cow.mesh"
-	MESH_FILE_PATH string = "./res/meshes/cube.mesh"
-	WAV_FILE_PATH  string = "./files/test_files/test12.obj"
-	IMG_FILE_PATH  string = "./test.jpg"
+	MESH_FILE_PATH  string = "./res/meshes/cube.mesh"
+	WAV_FILE_PATH   string = "./files/test_files/test12.obj"
+	BPT_FILE_PATH   string = "./res/meshes/bump.bpt"
+	PGM_FILE_PATH   string = "./res/meshes/hill.pgm"
+	CRV_FILE_PATH   string = "./res/meshes/wires.curve"
+	XYZ_FILE_PATH   string = "./res/meshes/ball.xyz"
+	VGRID_FILE_PATH string = "./res/meshes/puff.vgrid"
+	IMG_FILE_PATH   string = "./test.jpg"
 )
 
 type CollisionStats struct {
@@ -41,9 +47,15 @@ type World struct {
 	Img             draw.Image // use the draw interface
 	Config          RayTraceConfig
 	Objects         []obj.Object
-	Lights          []obj.Light
+	Lights          []obj.Light // emissive lights must also be in Objects to be seen
+	Medium          *obj.Medium // fills the whole world when set
+	Volumes         []*obj.Volume
+	VoxelVolumes    []*obj.VoxelVolume
+	Rand            *rand.Rand // drives the sampling of voxel volumes and lights
 	RefractiveIndex float64
 	Stats           CollisionStats
+	incidents       []obj.IncidentFunc // built from incidentLights by incidentFuncs
+	incidentLights  []obj.Light
 }
 
 func NewWorld() *World {
@@ -56,6 +68,7 @@ func NewWorld() *World {
 	world.Config = RayTraceConfig{true, true, false, 3}
 	world.Img = image.NewRGBA(image.Rect(0, 0, world.Cam.Width, world.Cam.Height))
 	world.RefractiveIndex = 1
+	world.Rand = rand.New(rand.NewSource(1))
 	world.Stats = CollisionStats{0, 0}
 	return world
 }
@@ -65,8 +78,8 @@ func (w *World) MakeObjects() {
 	/*
 		center1 := vec.NewVec3(0, 0.5, -4)
 		center2 := vec.NewVec3(3, 0, -7)
-		sphere1 := obj.Sphere{"Sphere1", *center1, 1, color.RGBA{0, 0, 255, 1}, 1, 1.2}
-		sphere2 := obj.Sphere{"Sphere2", *center2, 1, color.RGBA{0, 255, 0, 1}, 1, 1.2}
+		sphere1 := obj.Sphere{"Sphere1", *center1, 1, color.RGBA{0, 0, 255, 1}, 1.2}
+		sphere2 := obj.Sphere{"Sphere2", *center2, 1, color.RGBA{0, 255, 0, 1}, 1.2}
 	*/
 
 	// triangles
@@ -74,42 +87,143 @@ func (w *World) MakeObjects() {
 		v0 := vec.NewVec3(0, -1, -3)
 		v1 := vec.NewVec3(1, 1, -3)
 		v2 := vec.NewVec3(-1, 1, -3)
-		triangle1 := obj.NewTriangle("Tri1", *v0, *v1, *v2, color.RGBA{255, 0, 0, 1}, 1, 1, false)
+		triangle1 := obj.NewTriangle("Tri1", *v0, *v1, *v2, color.RGBA{255, 0, 0, 1}, 1, false)
 	*/
+	// ground plane
+	/*
+		ground := obj.NewPlane("Ground", *vec.NewVec3(0, -1, 0), *vec.NewVec3(0, 1, 0), color.RGBA{128, 128, 128, 1}, 1)
+	*/
+	// signed distance field
+	/*
+		blob := obj.SDFSmoothUnion(
+			obj.SDFSphere(*vec.NewVec3(-0.5, 0, -4), 0.7),
+			obj.SDFTorus(*vec.NewVec3(0.5, 0, -4), 0.6, 0.2),
+			0.3)
+		blobBounds := obj.NewAABB(*vec.NewVec3(-1.5, -1, -5), *vec.NewVec3(1.5, 1, -3))
+		sdf := obj.NewSDF("Blob", blob, blobBounds, color.RGBA{0, 0, 255, 1}, 1)
+	*/
+	// bezier patches
+	/*
+		patches, err := files.ReadBezierFile(BPT_FILE_PATH)
+		if err != nil {
+			fmt.Println(err)
+		}
+		segments := obj.BezierSegments(patches, 0.01)
+		patchPoly := obj.TessellateBezierPatches(patches, segments)
+		patchMesh := obj.NewTriangleMesh("Patches", patchPoly, color.RGBA{255, 255, 0, 1}, 1, false)
+	*/
+	// heightfield terrain
+	/*
+		heights, err := files.ReadHeightMap(PGM_FILE_PATH)
+		if err != nil {
+			fmt.Println(err)
+		}
+		terrain, err := obj.NewHeightfield("Terrain", heights, *vec.NewVec3(-2, -1, -6), *vec.NewVec3(4, 1, 4), color.RGBA{0, 128, 0, 1}, 1)
+		if err != nil {
+			fmt.Println(err)
+		}
+	*/
+	// curves
+	/*
+		curves, err := files.ReadCurveFile(CRV_FILE_PATH, color.RGBA{64, 64, 64, 1}, 1)
+		if err != nil {
+			fmt.Println(err)
+		}
+		wires := obj.NewBVH("Wires", curves)
+	*/
+	// point cloud
+	/*
+		points := new(obj.PointSet)
+		err := files.ReadXYZFile(XYZ_FILE_PATH, points)
+		if err != nil {
+			fmt.Println(err)
+		}
+		cloud, err := obj.NewPointCloud("Cloud", points, 0.15, obj.PointDisk, color.RGBA{255, 255, 255, 1}, 1)
+		if err != nil {
+			fmt.Println(err)
+		}
+	*/
+	// fog filling the world and smoke inside a sphere
+	/*
+		w.Medium = obj.NewMedium([3]float64{0.01, 0.01, 0.01}, [3]float64{0.05, 0.05, 0.05}, 0.3)
+		smoke := obj.Sphere{"Smoke", *vec.NewVec3(-2, 0, -6), 1, color.RGBA{0, 0, 0, 1}, 1}
+		smokeMedium := obj.NewMedium([3]float64{0.5, 0.5, 0.5}, [3]float64{1, 1, 1}, 0)
+		w.Volumes = append(w.Volumes, obj.NewVolume("Smoke", smoke, smokeMedium))
+	*/
+	// simulated smoke from a density grid
+	/*
+		grid, err := files.ReadVoxelGrid(VGRID_FILE_PATH)
+		if err != nil {
+			fmt.Println(err)
+		}
+		puffBox := obj.NewAABB(*vec.NewVec3(-1, -1, -5), *vec.NewVec3(1, 1, -3))
+		puff := obj.NewVoxelVolume("Puff", puffBox, grid, 4, [3]float64{0.9, 0.9, 0.9}, 0.2)
+		w.VoxelVolumes = append(w.VoxelVolumes, puff)
+	*/
+	// transformed objects
+	/*
+		unit := obj.Sphere{"Ellipsoid", *vec.NewVec3(0, 0, 0), 1, color.RGBA{0, 255, 0, 1}, 1}
+		ellipsoid, err := obj.NewTransformed(unit, *vec.NewVec3(2, 0, -6), *vec.NewVec3(0, 0, 1), math.Pi/4, *vec.NewVec3(1, 2, 1))
+		if err != nil {
+			fmt.Println(err)
+		}
+	*/
+
 	poly := obj.MakePolygonMesh()
 	//err := files.ReadMeshFile(MESH_FILE_PATH, poly)
 	err := files.ReadWavFile(WAV_FILE_PATH, poly)
 	if err != nil {
 		fmt.Println(err)
 	}
-	// NOTE: This currently runs faster than the parallel version
-	// possibly due to overhead costs of cross-thread communication
-	// Check at higher volumes if this is still the case
-	triangles := poly.ConvertPolygonSerial()
-	//triangles := poly.ConvertPolygonParallel()
-
-	// Slice of objects, 0 values, 3 capacity
-	capacity := len(triangles)
-	w.Objects = make([]obj.Object, 0, capacity)
+	// Scanned or exported meshes can be welded and repaired first
+	//poly, report := poly.Clean(1e-6)
+	//fmt.Print(report)
+	// Low-poly cages can be refined before rendering
+	//poly = poly.Subdivide(2)
+	// The mesh keeps the shared vertex buffer instead of converting every
+	// face into a separate Triangle with its own edges and normals
+	//triangles := poly.ConvertPolygonSerial(color.RGBA{255, 0, 0, 1}, 1)
+	//triangles := poly.ConvertPolygonParallel(color.RGBA{255, 0, 0, 1}, 1)
+	mesh := obj.NewTriangleMesh("Mesh1", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+	// Meshes of curved surfaces without vertex normals can be smoothed
+	//mesh.SmoothNormals()
+
+	// Slice of objects, 0 values, 1 capacity
+	w.Objects = make([]obj.Object, 0, 1)
 
 	//w.Objects = append(w.Objects, obj.Object(sphere1))
 	//w.Objects = append(w.Objects, obj.Object(sphere2))
+	//w.Objects = append(w.Objects, obj.Object(ground))
+	//w.Objects = append(w.Objects, obj.Object(sdf))
+	//w.Objects = append(w.Objects, obj.Object(ellipsoid))
+	//w.Objects = append(w.Objects, obj.Object(patchMesh))
 
-	// This may be a very costly waste
-	// One way to optimize is to use pure Triangle type, instead of
-	// casting to Objects
-	for i, _ := range triangles {
-		w.Objects = append(w.Objects, obj.Object(&triangles[i]))
-	}
+	w.Objects = append(w.Objects, obj.Object(mesh))
 	//w.Objects = append(w.Objects, obj.Object(triangle1))
 }
 
 func (w *World) MakeLights() {
 	center := vec.NewVec3(0, 5, -2)
-	light := obj.Light{"light1", *center, 1, 1, color.RGBA{255, 255, 255, 1}}
+	light := obj.NewPointLight("light1", *center, [3]float64{40, 40, 40})
 
 	w.Lights = make([]obj.Light, 0, 1)
 	w.Lights = append(w.Lights, light)
+
+	// sun, spot light and the visible spherical light of older scenes
+	/*
+		sun := obj.NewDirectionalLight("Sun", *vec.NewVec3(1, -2, -1), [3]float64{1, 1, 1})
+		spot := obj.NewSpotLight("Spot", *vec.NewVec3(0, 4, -4), *vec.NewVec3(0, -1, 0), [3]float64{30, 30, 30}, math.Pi/6, math.Pi/24)
+		bulb := obj.NewSphereLight("Bulb", *vec.NewVec3(0, 5, -2), 1, color.RGBA{255, 255, 255, 1})
+		w.Lights = append(w.Lights, sun, spot, bulb)
+	*/
+
+	// light panel overhead, lighting the scene by its area
+	/*
+		panel := obj.NewDisk("Panel", *vec.NewVec3(0, 4, -4), *vec.NewVec3(0, -1, 0), 1, 0, color.RGBA{255, 255, 255, 1}, 1)
+		lamp := obj.NewEmissive(panel, [3]float64{8, 8, 8})
+		w.Lights = append(w.Lights, lamp)
+		w.Objects = append(w.Objects, lamp)
+	*/
 }
 
 func (w World) NewCameraRay(x, y int) *cam.Ray {
@@ -117,7 +231,7 @@ func (w World) NewCameraRay(x, y int) *cam.Ray {
 	origin := vec.NewVec3(0, 0, 0)
 	dir := vec.NewVec3(px, py, -1)
 	dir.Normalize()
-	ray := cam.NewRay("", "camera", origin, dir)
+	ray := cam.NewRay(0, "camera", origin, dir)
 	return ray
 }
 
@@ -141,39 +255,47 @@ func NewRefractionVector(l, n vec.Vec3, ref_index1, ref_index2 float64) vec.Vec3
 	return vr
 }
 
-func (w World) NewTransmittedRay(ray *cam.Ray, hit, n vec.Vec3, object obj.Object) (*cam.Ray, bool) {
+func (w World) NewTransmittedRay(ray *cam.Ray, rec obj.HitRecord) (*cam.Ray, bool) {
 	// TODO:
 	// Deal with total internal refraction. Total internal reflection occurs
 	// when n2 < n1, so we can ignore this for now
+	object := rec.Object
 	external_ref_index := w.RefractiveIndex
 	internal_ref_index := object.GetRefractiveIndex()
 
-	irv := NewRefractionVector(ray.Direction, n, external_ref_index, internal_ref_index)
+	irv := NewRefractionVector(ray.Direction, rec.Normal, external_ref_index, internal_ref_index)
 	irv.Normalize()
-	internal_ray := cam.NewRay("", "refraction", &hit, &irv)
-	is_hit2, hit2, n2, _, _ := object.Intersects(internal_ray)
-	invn2 := vec.Invert(n2)
+	internal_org := obj.OffsetRayOrigin(rec, irv)
+	internal_ray := cam.NewChildRay(ray, "refraction", &internal_org, &irv)
+	rec2, is_hit2 := object.Intersects(internal_ray)
+	invn2 := vec.Invert(rec2.Normal)
 	if is_hit2 {
 		erv := NewRefractionVector(irv, invn2, internal_ref_index, external_ref_index)
 		erv.Normalize()
-		return cam.NewRay("", "transmission", &hit2, &erv), true
+		external_org := obj.OffsetRayOrigin(rec2, erv)
+		return cam.NewChildRay(internal_ray, "transmission", &external_org, &erv), true
 	} else {
 		fmt.Println("Did not hit object internally")
-		return cam.NewRay("noid", "", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 0)), false
+		return new(cam.Ray), false
 	}
 }
 
-func (w *World) NewShadowRay(incident *cam.Ray, n, hit vec.Vec3) *cam.Ray {
+func (w *World) NewShadowRay(incident *cam.Ray, rec obj.HitRecord) *cam.Ray {
 	// Creates specular shadow ray
-	reflected_dir := vec.Reflect(incident.Direction, n)
-	return cam.NewRay("", "shadow", &hit, &reflected_dir)
+	reflected_dir := vec.Reflect(incident.Direction, rec.Normal)
+	org := obj.OffsetRayOrigin(rec, reflected_dir)
+	return cam.NewChildRay(incident, "shadow", &org, &reflected_dir)
 }
 
 func (w *World) intersectLightsOld(ray *cam.Ray) (color.RGBA, bool) {
 	hit_light := false
 	hit_dist := INF_DIST
 	hit_color := color.RGBA{0, 0, 0, 0}
-	for _, v := range w.Lights {
+	for _, l := range w.Lights {
+		v, is_sphere := l.(*obj.SphereLight)
+		if !is_sphere {
+			continue
+		}
 		if hit, dist := v.Intersects(ray); hit && dist < hit_dist {
 			hit_light = true
 			hit_dist = dist
@@ -183,7 +305,7 @@ func (w *World) intersectLightsOld(ray *cam.Ray) (color.RGBA, bool) {
 
 	if hit_light {
 		for _, v := range w.Objects {
-			if is_hit, _, _, t0, _ := v.Intersects(ray); is_hit && math.Abs(t0) < hit_dist {
+			if rec, is_hit := v.Intersects(ray); is_hit && math.Abs(rec.T0) < hit_dist {
 				fmt.Println("hit an object")
 				return hit_color, false
 			}
@@ -192,36 +314,39 @@ func (w *World) intersectLightsOld(ray *cam.Ray) (color.RGBA, bool) {
 	return hit_color, hit_light
 }
 
-func (w *World) intersectLights(ray *cam.Ray, dist float64) (*obj.Light, float64) {
+// intersectLights returns the closest of the lights that rays can hit,
+// which are only sphere lights
+func (w *World) intersectLights(ray *cam.Ray, dist float64) (*obj.SphereLight, float64) {
 	closest_dist := dist
-	var closest_light *obj.Light
+	var closest_light *obj.SphereLight
 	closest_light = nil
-	for i, v := range w.Lights {
+	for _, l := range w.Lights {
+		v, is_sphere := l.(*obj.SphereLight)
+		if !is_sphere {
+			continue
+		}
 		is_hit, new_dist := v.Intersects(ray)
 		if is_hit && (new_dist < closest_dist) {
 			closest_dist = new_dist
-			closest_light = &w.Lights[i]
+			closest_light = v
 		}
 	}
 	return closest_light, closest_dist
 }
 
-func (w *World) intersectObjects(ray *cam.Ray, dist float64) (*obj.Object, vec.Vec3, vec.Vec3, float64) {
+func (w *World) intersectObjects(ray *cam.Ray, dist float64) (obj.HitRecord, bool) {
 	closest_dist := dist
-	closest_hit_location := *vec.NewVec3(0, 0, 0)
-	closest_n_vector := *vec.NewVec3(0, 0, 0)
-	var closest_obj *obj.Object
-	closest_obj = nil
-	for i, obj := range w.Objects {
-		is_hit, hit, n, t0, _ := obj.Intersects(ray)
-		if new_dist := math.Abs(t0); is_hit && (new_dist < closest_dist) {
+	var closest_rec obj.HitRecord
+	did_hit := false
+	for _, obj := range w.Objects {
+		rec, is_hit := obj.Intersects(ray)
+		if new_dist := math.Abs(rec.T0); is_hit && (new_dist < closest_dist) {
 			closest_dist = new_dist
-			closest_obj = &w.Objects[i]
-			closest_hit_location = hit
-			closest_n_vector = n
+			closest_rec = rec
+			did_hit = true
 		}
 	}
-	return closest_obj, closest_hit_location, closest_n_vector, closest_dist
+	return closest_rec, did_hit
 }
 
 // TODO: Figure out whether this should return obj pointer or color val
@@ -235,15 +360,16 @@ func (w *World) TraceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
 
 	closest_dist := INF_DIST
 
-	hit_obj, hit, n, dist := w.intersectObjects(ray, closest_dist)
-	if hit_obj != nil {
-		closest_dist = dist
+	rec, did_hit := w.intersectObjects(ray, closest_dist)
+	if did_hit {
+		closest_dist = math.Abs(rec.T0)
 	}
 
 	// Smack into some lights
-	var light *obj.Light
+	var light *obj.SphereLight
+	light_dist := closest_dist
 	if w.Config.UseLight {
-		light, _ = w.intersectLights(ray, closest_dist)
+		light, light_dist = w.intersectLights(ray, closest_dist)
 	}
 
 	// If light is the closest thing we hit, return light
@@ -251,21 +377,24 @@ func (w *World) TraceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
 	var trans_color color.RGBA
 	var reflected_color color.RGBA
 	if light != nil && ray.Type != "camera" {
-		return light.Col, true
-	} else if hit_obj != nil {
-		current_color = (*hit_obj).GetColor()
+		return w.applyMedia(ray, light.Col, light_dist), true
+	} else if did_hit {
+		current_color = w.shadeSurface(ray, rec)
 
 		// transmitted ray
 		trans_hit := false
 		if w.Config.UseRefraction {
-			trans_ray, _ := w.NewTransmittedRay(ray, hit, n, *hit_obj)
+			trans_ray, transmitted := w.NewTransmittedRay(ray, rec)
 			trans_color, trans_hit = w.TraceRay(trans_ray, reflection)
+			if trans_hit && transmitted {
+				trans_color = w.applyInterior(rec, trans_ray, trans_color)
+			}
 		}
 
 		// shadow ray
 		reflect_hit := false
 		if w.Config.UseShadows {
-			reflected_ray := w.NewShadowRay(ray, n, hit)
+			reflected_ray := w.NewShadowRay(ray, rec)
 			reflected_color, reflect_hit = w.TraceRay(reflected_ray, reflection)
 		}
 
@@ -277,10 +406,238 @@ func (w *World) TraceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
 			current_color = BlendColors(current_color, reflected_color, 0.5)
 		}
 
-		return current_color, true
+		return w.applyMedia(ray, current_color, closest_dist), true
+	}
+
+	return w.applyMedia(ray, current_color, closest_dist), false
+}
+
+// mediaSegment is a stretch of a ray through one medium
+type mediaSegment struct {
+	medium *obj.Medium
+	t0     float64
+	t1     float64
+}
+
+// mediaSegments returns the stretches of the ray through the world medium
+// and the volumes, up to dist
+func (w *World) mediaSegments(ray *cam.Ray, dist float64) []mediaSegment {
+	segments := make([]mediaSegment, 0)
+	if w.Medium != nil {
+		segments = append(segments, mediaSegment{w.Medium, 0, dist})
+	}
+	for _, v := range w.Volumes {
+		for _, s := range v.Segments(ray, dist) {
+			segments = append(segments, mediaSegment{v.Medium, s[0], s[1]})
+		}
+	}
+	return segments
+}
+
+// transmittance returns the fraction of red, green and blue light that
+// gets through the segments between the ray origin and dist
+func transmittance(segments []mediaSegment, dist float64) [3]float64 {
+	total := [3]float64{1, 1, 1}
+	for _, s := range segments {
+		length := math.Min(s.t1, dist) - s.t0
+		if length <= 0 {
+			continue
+		}
+		t := s.medium.Transmittance(length)
+		for c := range total {
+			total[c] *= t[c]
+		}
+	}
+	return total
+}
+
+// lightPath returns the fraction of red, green and blue light that gets
+// from dist along the normalized direction to_light back to p. Objects in
+// between block it and media attenuate it. The shadow ray is a child of
+// parent
+func (w *World) lightPath(parent *cam.Ray, p, to_light vec.Vec3, dist float64) [3]float64 {
+	shadow_ray := cam.NewChildRay(parent, "shadow", &p, &to_light)
+	if _, is_hit := w.intersectObjects(shadow_ray, dist); is_hit {
+		return [3]float64{}
+	}
+	t := transmittance(w.mediaSegments(shadow_ray, dist), dist)
+	voxel_t := w.voxelTransmittance(shadow_ray, dist)
+	for c := range t {
+		t[c] *= voxel_t
+	}
+	return t
+}
+
+// incidentLight returns the light arriving at points from a sample of
+// light, which is blocked by objects and attenuated by media on the way.
+// The shadow ray stops just short of the sampled point so that it does
+// not hit an emissive light itself
+func (w *World) incidentLight(light obj.Light) obj.IncidentFunc {
+	return func(p vec.Vec3, parent *cam.Ray) (vec.Vec3, [3]float64) {
+		s, ok := light.Sample(p, w.Rand.Float64(), w.Rand.Float64())
+		if !ok {
+			return s.Direction, [3]float64{}
+		}
+		t := w.lightPath(parent, p, s.Direction, s.Distance*(1-1e-6))
+		radiance := light.Evaluate(p, s)
+		for c := range radiance {
+			radiance[c] *= t[c]
+		}
+		return s.Direction, radiance
+	}
+}
+
+// incidentFuncs returns the incident light of every light, once for each
+// of its samples with the light divided among them. They are built once
+// and kept until Lights changes or a new Trace starts
+func (w *World) incidentFuncs() []obj.IncidentFunc {
+	changed := w.incidents == nil || len(w.incidentLights) != len(w.Lights)
+	for i := 0; !changed && i < len(w.Lights); i++ {
+		changed = w.incidentLights[i] != w.Lights[i]
+	}
+	if !changed {
+		return w.incidents
+	}
+
+	incidents := make([]obj.IncidentFunc, 0, len(w.Lights))
+	for _, l := range w.Lights {
+		incident := w.incidentLight(l)
+		share := 1 / float64(l.NumSamples())
+		for i := 0; i < l.NumSamples(); i++ {
+			incidents = append(incidents, func(p vec.Vec3, parent *cam.Ray) (vec.Vec3, [3]float64) {
+				to_light, radiance := incident(p, parent)
+				for c := range radiance {
+					radiance[c] *= share
+				}
+				return to_light, radiance
+			})
+		}
 	}
+	w.incidents = incidents
+	w.incidentLights = append([]obj.Light(nil), w.Lights...)
+	return incidents
+}
 
-	return current_color, false
+// shadeSurface returns the color of the surface at rec, where ray hit it.
+// With lights in use the surface color is taken as a diffuse albedo lit
+// by the light sampled from them; without, it is kept flat. Light the
+// surface gives off itself is added on top
+func (w *World) shadeSurface(ray *cam.Ray, rec obj.HitRecord) color.RGBA {
+	col := rec.Object.GetColor()
+	emitter, is_emitter := rec.Object.(obj.Emitter)
+	use_light := w.Config.UseLight && len(w.Lights) > 0
+	if !use_light && !is_emitter {
+		return col
+	}
+
+	rgb := ColorToRGB(col)
+	if use_light {
+		var direct [3]float64
+		n := rec.Normal
+		org := obj.OffsetRayOrigin(rec, n)
+		incidents := w.incidentFuncs()
+		for _, incident := range incidents {
+			to_light, radiance := incident(org, ray)
+			cos := vec.Dot(n, to_light)
+			if cos <= 0 {
+				continue
+			}
+			for c := range direct {
+				direct[c] += radiance[c] * cos / math.Pi
+			}
+		}
+		for c := range rgb {
+			rgb[c] *= direct[c]
+		}
+	}
+	if is_emitter {
+		emission := emitter.Emission()
+		for c := range rgb {
+			rgb[c] += emission[c]
+		}
+	}
+	return RGBToColor(rgb, col.A)
+}
+
+// voxelTransmittance estimates the fraction of light that gets through
+// the voxel volumes along the ray up to dist
+func (w *World) voxelTransmittance(ray *cam.Ray, dist float64) float64 {
+	total := 1.0
+	for _, v := range w.VoxelVolumes {
+		total *= v.Transmittance(ray, dist, w.Rand)
+	}
+	return total
+}
+
+// applyMedia attenuates col, seen at dist along the ray, by the media in
+// between and adds the light they scatter toward the ray origin.
+// Overlapping media attenuate each other's scattered light only from
+// where each stretch begins
+func (w *World) applyMedia(ray *cam.Ray, col color.RGBA, dist float64) color.RGBA {
+	segments := w.mediaSegments(ray, dist)
+	if len(segments) == 0 && len(w.VoxelVolumes) == 0 {
+		return col
+	}
+	dir := ray.Direction
+	dir.Normalize()
+
+	rgb := ColorToRGB(col)
+	total := transmittance(segments, dist)
+	voxel_total := w.voxelTransmittance(ray, dist)
+	for c := range rgb {
+		rgb[c] *= total[c] * voxel_total
+	}
+	incidents := w.incidentFuncs()
+	for _, v := range w.VoxelVolumes {
+		inv_dir := *vec.NewVec3(1/dir.X, 1/dir.Y, 1/dir.Z)
+		_, entry, _ := v.Bounds().IntersectsRay(ray.Origin, inv_dir, dist)
+		before := transmittance(segments, entry)
+		for _, incident := range incidents {
+			scattered := v.InScatter(ray, dist, incident, w.Rand)
+			for c := range rgb {
+				rgb[c] += before[c] * scattered[c]
+			}
+		}
+	}
+	for _, s := range segments {
+		before := transmittance(segments, s.t0)
+		for _, incident := range incidents {
+			scattered := s.medium.InScatter(ray.Origin, dir, s.t0, s.t1, ray, incident)
+			for c := range rgb {
+				rgb[c] += before[c] * scattered[c]
+			}
+		}
+	}
+	return RGBToColor(rgb, col.A)
+}
+
+// applyInterior tints col, the light coming out the far side of a
+// transparent object, by a volume filling the object
+func (w *World) applyInterior(rec obj.HitRecord, trans_ray *cam.Ray, col color.RGBA) color.RGBA {
+	for _, v := range w.Volumes {
+		if v.Boundary.GetID() != rec.Object.GetID() {
+			continue
+		}
+		path := vec.Subtract(trans_ray.Origin, rec.Point)
+		if path.Magnitude == 0 {
+			return col
+		}
+		dir := vec.Divide(path, path.Magnitude)
+
+		rgb := ColorToRGB(col)
+		t := v.Medium.Transmittance(path.Magnitude)
+		for c := range rgb {
+			rgb[c] *= t[c]
+		}
+		for _, incident := range w.incidentFuncs() {
+			scattered := v.Medium.InScatter(rec.Point, dir, 0, path.Magnitude, trans_ray, incident)
+			for c := range rgb {
+				rgb[c] += scattered[c]
+			}
+		}
+		return RGBToColor(rgb, col.A)
+	}
+	return col
 }
 
 func (w *World) traceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
@@ -295,18 +652,18 @@ func (w *World) traceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
 	did_hit := false
 
 	for _, obj := range w.Objects {
-		isHit, hit, n, t0, _ := obj.Intersects(ray)
+		rec, isHit := obj.Intersects(ray)
 		if isHit {
 			did_hit = true
-			new_dist := math.Abs(t0)
+			new_dist := math.Abs(rec.T0)
 			if new_dist < closest_dist {
 				closest_dist = new_dist
-				pixel_color = obj.GetColor()
+				pixel_color = rec.Object.GetColor()
 
 				if w.Config.UseLight {
 					// Gather up direct lights
 					// Create shadow ray
-					shadow_ray := w.NewShadowRay(ray, n, hit)
+					shadow_ray := w.NewShadowRay(ray, rec)
 					light_color, did_hit_light := w.intersectLightsOld(shadow_ray)
 
 					if did_hit_light {
@@ -316,11 +673,11 @@ func (w *World) traceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
 				}
 
 				if w.Config.UseRefraction {
-					trans_ray, success := w.NewTransmittedRay(ray, hit, n, obj)
+					trans_ray, success := w.NewTransmittedRay(ray, rec)
 					if success {
 						ref_color, hit := w.traceRay(trans_ray, reflection)
 						if hit {
-							pixel_color = BlendColors(obj.GetColor(), ref_color, 0.5)
+							pixel_color = BlendColors(rec.Object.GetColor(), ref_color, 0.5)
 							w.Stats.Successes += 1
 						} else {
 							if trans_ray.Origin.Z > -9.0 {
@@ -337,6 +694,7 @@ func (w *World) traceRay(ray *cam.Ray, reflection uint) (color.RGBA, bool) {
 }
 
 func (w *World) Trace() {
+	w.incidents = nil
 	b := w.Img.Bounds()
 	for y := b.Min.Y; y < b.Max.Y; y++ {
 		for x := b.Min.X; x < b.Max.X; x++ {
@@ -363,6 +721,20 @@ func (w World) ShowStats() {
 	fmt.Printf("Ratio %v\n", ratio)
 }
 
+// ColorToRGB converts a color into red, green and blue from 0 to 1
+func ColorToRGB(c color.RGBA) [3]float64 {
+	return [3]float64{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+}
+
+// RGBToColor converts red, green and blue from 0 to 1 into a color,
+// clamping values outside that range
+func RGBToColor(rgb [3]float64, alpha uint8) color.RGBA {
+	channel := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Round(v*255))))
+	}
+	return color.RGBA{channel(rgb[0]), channel(rgb[1]), channel(rgb[2]), alpha}
+}
+
 func BlendColors(c1, c2 color.RGBA, t float64) color.RGBA {
 	c3 := color.RGBA{0, 0, 0, 0}
 	// TODO: Use bitwise manipulation here instead
@@ -6,6 +6,7 @@ import (
 	"github.com/agdt3/goray/obj"
 	"github.com/agdt3/goray/vec"
 	"image/color"
+	"math"
 	"testing"
 )
 
@@ -24,23 +25,23 @@ func TestCameraRayIntersection1D(t *testing.T) {
 	t.Parallel()
 
 	center := vec.NewVec3(0, 0, -3)
-	sphere := obj.Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1, 1.0}
-	ray := cam.NewRay("A", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	isHit, hit, n, t0, t1 := sphere.Intersects(ray)
+	sphere := obj.Sphere{ID: "sphere1", Center: *center, Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1.0}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, isHit := sphere.Intersects(ray)
 
 	if !isHit {
 		t.Error("Ray did not hit")
 	}
 
-	if !vec.IsEqual(hit, *vec.NewVec3(0, 0, -2)) {
+	if !vec.IsEqual(rec.Point, *vec.NewVec3(0, 0, -2)) {
 		t.Error("Hit vector is not correct")
 	}
 
-	if !vec.IsEqual(n, *vec.NewVec3(0, 0, 1)) {
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) {
 		t.Error("N vector is not correct")
 	}
 
-	if t0 != 2 || t1 != 4 {
+	if rec.T0 != 2 || rec.T1 != 4 {
 		t.Error("Distance is incorrect")
 	}
 }
@@ -50,9 +51,10 @@ func TestTransmissionRayIntersection1DHeadOn(t *testing.T) {
 
 	world := NewWorld()
 	center := vec.NewVec3(0, 0, -3)
-	sphere := obj.Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1, 1.2}
-	ray := cam.NewRay("A", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	_, hit, n, _, _ := sphere.Intersects(ray)
+	sphere := obj.Sphere{ID: "sphere1", Center: *center, Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1.2}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, _ := sphere.Intersects(ray)
+	hit, n := rec.Point, rec.Normal
 
 	internal_dir := NewRefractionVector(
 		ray.Direction,
@@ -64,8 +66,9 @@ func TestTransmissionRayIntersection1DHeadOn(t *testing.T) {
 		t.Error("Internal refracted direction is incorrect")
 	}
 
-	ref_ray := cam.NewRay("B", "refraction", &hit, &internal_dir)
-	isHit2, hit2, n2, _, _ := sphere.Intersects(ref_ray)
+	ref_ray := cam.NewRay(2, "refraction", &hit, &internal_dir)
+	rec2, isHit2 := sphere.Intersects(ref_ray)
+	hit2, n2 := rec2.Point, rec2.Normal
 
 	if !isHit2 {
 		t.Error("Internal ray should intersec with sphere")
@@ -85,8 +88,9 @@ func TestTransmissionRayIntersection1DHeadOn(t *testing.T) {
 		t.Error("External refracted direction is incorrect")
 	}
 
-	trans_ray := cam.NewRay("C", "transmission", &hit2, &external_dir)
-	world_trans_ray, _ := world.NewTransmittedRay(ray, hit, n, sphere)
+	trans_org := obj.OffsetRayOrigin(rec2, external_dir)
+	trans_ray := cam.NewRay(3, "transmission", &trans_org, &external_dir)
+	world_trans_ray, _ := world.NewTransmittedRay(ray, rec)
 
 	if !cam.IsEqual(trans_ray, world_trans_ray) {
 		t.Error("World function incorrectly constructed transmission ray")
@@ -99,9 +103,10 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 
 	world := NewWorld()
 	center := vec.NewVec3(0.5, 0, -3)
-	sphere := obj.Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1, 1.2}
-	ray := cam.NewRay("A", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	_, hit, n, _, _ := sphere.Intersects(ray)
+	sphere := obj.Sphere{ID: "sphere1", Center: *center, Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1.2}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, _ := sphere.Intersects(ray)
+	hit, n := rec.Point, rec.Normal
 
 	internal_dir := NewRefractionVector(
 		ray.Direction,
@@ -113,8 +118,9 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 		t.Error("Internal refracted direction is incorrect")
 	}
 
-	ref_ray := cam.NewRay("B", "refraction", &hit, &internal_dir)
-	isHit2, hit2, n2, _, _ := sphere.Intersects(ref_ray)
+	ref_ray := cam.NewRay(2, "refraction", &hit, &internal_dir)
+	rec2, isHit2 := sphere.Intersects(ref_ray)
+	hit2, n2 := rec2.Point, rec2.Normal
 
 	if !isHit2 {
 		t.Error("Internal ray should intersec with sphere")
@@ -134,8 +140,8 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 		t.Error("External refracted direction is incorrect")
 	}
 
-	trans_ray := cam.Ray{"C", "transmitted", hit2, external_dir}
-	world_trans_ray, _ := world.NewTransmittedRay(ray, hit, n, sphere)
+	trans_ray := cam.Ray{ID: 3, Type: "transmitted", Origin: hit2, Direction: external_dir}
+	world_trans_ray, _ := world.NewTransmittedRay(ray, rec)
 	fmt.Println(ray)
 	fmt.Println(ref_ray)
 	fmt.Println(trans_ray)
@@ -150,3 +156,141 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 	*/
 	//no-op
 }
+
+func TestFogAttenuation(t *testing.T) {
+	t.Parallel()
+
+	world := NewWorld()
+	world.Config = RayTraceConfig{false, false, false, 3}
+	world.Objects = []obj.Object{obj.Sphere{ID: "sphere1", Center: *vec.NewVec3(0, 0, -3), Radius: 1, Col: color.RGBA{200, 100, 0, 1}, RefractiveIndex: 1}}
+	world.Medium = obj.NewMedium([3]float64{0.5, 0.5, 0.5}, [3]float64{0, 0, 0}, 0)
+
+	// Through 2 units of fog each channel keeps exp(-1) of its light
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	c, is_hit := world.TraceRay(ray, 0)
+	if !is_hit || c.R != 74 || c.G != 37 || c.B != 0 {
+		t.Error("Fog did not attenuate the sphere correctly", c)
+	}
+
+	// Scattering fog glows where the light reaches it
+	world.Medium = obj.NewMedium([3]float64{0, 0, 0}, [3]float64{0.5, 0.5, 0.5}, 0)
+	world.Lights = []obj.Light{obj.NewSphereLight("light1", *vec.NewVec3(0, 5, -1), 1, color.RGBA{255, 255, 255, 1})}
+	glow, _ := world.TraceRay(ray, 0)
+	if glow.R <= c.R || glow.B == 0 {
+		t.Error("Fog should scatter light toward the camera", glow)
+	}
+}
+
+func TestEmissiveAreaLight(t *testing.T) {
+	t.Parallel()
+
+	world := NewWorld()
+	world.Config = RayTraceConfig{true, false, false, 3}
+	floor := obj.NewPlane("floor1", *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), color.RGBA{200, 200, 200, 1}, 1)
+	disk := obj.NewDisk("panel1", *vec.NewVec3(0, 0, -4), *vec.NewVec3(0, 0, -1), 1, 0, color.RGBA{0, 0, 0, 1}, 1)
+	panel := obj.NewEmissive(disk, [3]float64{1, 1, 1})
+	panel.Samples = 4096
+	world.Objects = []obj.Object{floor, panel}
+	world.Lights = []obj.Light{panel}
+
+	// The panel glows where it is seen
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	c, is_hit := world.TraceRay(ray, 0)
+	if !is_hit || c.R != 255 || c.G != 255 || c.B != 255 {
+		t.Error("Emissive panel should be seen at its radiance", c)
+	}
+
+	// Right below it the floor gets half of the radiance, as a disk of
+	// radius 1 one unit away covers half of the cosine weighted hemisphere
+	ray = cam.NewRay(2, "camera", vec.NewVec3(0, 0, -4.5), vec.NewVec3(0, 0, -1))
+	lit, is_hit := world.TraceRay(ray, 0)
+	if !is_hit || lit.R < 97 || lit.R > 103 || lit.R != lit.G || lit.G != lit.B {
+		t.Error("Floor was not lit correctly by the panel", lit)
+	}
+}
+
+func TestLightTypes(t *testing.T) {
+	t.Parallel()
+
+	world := NewWorld()
+	world.Config = RayTraceConfig{true, false, false, 3}
+	floor := obj.NewPlane("floor1", *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), color.RGBA{255, 255, 255, 1}, 1)
+	world.Objects = []obj.Object{floor}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+
+	// Each light sends 1 straight down onto the floor, which a white
+	// diffuse surface reflects as 1 / pi
+	var tests = []struct {
+		light obj.Light
+		value uint8
+	}{
+		{obj.NewPointLight("point1", *vec.NewVec3(0, 0, -3), [3]float64{4 * math.Pi, 4 * math.Pi, 4 * math.Pi}), 255},
+		{obj.NewDirectionalLight("sun1", *vec.NewVec3(0, 0, -1), [3]float64{math.Pi, math.Pi, math.Pi}), 255},
+		{obj.NewSpotLight("spot1", *vec.NewVec3(0, 0, -3), *vec.NewVec3(0, 0, -1), [3]float64{4 * math.Pi, 4 * math.Pi, 4 * math.Pi}, 0.5, 0.1), 255},
+		{obj.NewSpotLight("spot2", *vec.NewVec3(0, 0, -3), *vec.NewVec3(1, 0, 0), [3]float64{4 * math.Pi, 4 * math.Pi, 4 * math.Pi}, 0.5, 0.1), 0},
+		{obj.NewDirectionalLight("sun2", *vec.NewVec3(0, 0, 1), [3]float64{math.Pi, math.Pi, math.Pi}), 0},
+	}
+	for _, test := range tests {
+		world.Lights = []obj.Light{test.light}
+		c, is_hit := world.TraceRay(ray, 0)
+		if !is_hit || c.R != test.value || c.G != test.value || c.B != test.value {
+			t.Error("Floor was not lit correctly by", test.light.GetID(), c)
+		}
+	}
+}
+
+// rayRecorder is an object that keeps every ray tested against it
+type rayRecorder struct {
+	obj.Object
+	rays []*cam.Ray
+}
+
+func (r *rayRecorder) Intersects(ray *cam.Ray) (obj.HitRecord, bool) {
+	r.rays = append(r.rays, ray)
+	return r.Object.Intersects(ray)
+}
+
+func TestShadowRayParent(t *testing.T) {
+	t.Parallel()
+
+	world := NewWorld()
+	world.Config = RayTraceConfig{true, false, false, 3}
+	floor := &rayRecorder{Object: obj.NewPlane("floor1", *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), color.RGBA{255, 255, 255, 1}, 1)}
+	world.Objects = []obj.Object{floor}
+	world.Lights = []obj.Light{obj.NewPointLight("point1", *vec.NewVec3(0, 0, -3), [3]float64{1, 1, 1})}
+	world.Medium = obj.NewMedium([3]float64{0.01, 0.01, 0.01}, [3]float64{0.01, 0.01, 0.01}, 0)
+	world.Medium.Steps = 2
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	world.TraceRay(ray, 0)
+	shadow_rays := 0
+	for _, r := range floor.rays {
+		if r.Type != "shadow" {
+			continue
+		}
+		shadow_rays++
+		if r.ParentID != ray.ID {
+			t.Error("Shadow ray should be a child of the camera ray", r.ParentID, ray.ID)
+		}
+	}
+	if shadow_rays != 3 {
+		t.Error("Surface and medium should each cast shadow rays", shadow_rays)
+	}
+}
+
+func TestIncidentFuncsCached(t *testing.T) {
+	t.Parallel()
+
+	world := NewWorld()
+	bulb := obj.NewPointLight("point1", *vec.NewVec3(0, 0, -3), [3]float64{1, 1, 1})
+	world.Lights = []obj.Light{bulb}
+	first := world.incidentFuncs()
+	if second := world.incidentFuncs(); len(first) != 1 || &second[0] != &first[0] {
+		t.Error("Incident light should be built once for the same lights")
+	}
+
+	world.Lights = []obj.Light{bulb, obj.NewDirectionalLight("sun1", *vec.NewVec3(0, 0, -1), [3]float64{1, 1, 1})}
+	if changed := world.incidentFuncs(); len(changed) != 2 {
+		t.Error("Incident light should be rebuilt when the lights change", len(changed))
+	}
+}
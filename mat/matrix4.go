@@ -0,0 +1,286 @@
+package mat
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// machineEpsilon bounds the relative rounding error of a float64 operation
+const machineEpsilon = 0x1p-53
+
+// Matrix4 is a fixed size 4x4 matrix, stored row major.
+// It is used to represent affine transforms of points and vectors
+type Matrix4 struct {
+	values [16]float64
+}
+
+// NewMatrix4 is a constructor for a 4x4 matrix. Values are read row major
+// and any missing values are left at 0
+func NewMatrix4(val []float64) *Matrix4 {
+	m := new(Matrix4)
+	for k, v := range val {
+		if k >= 16 {
+			break
+		}
+		m.values[k] = v
+	}
+	return m
+}
+
+// NewIdentity4 creates a 4x4 identity matrix
+func NewIdentity4() *Matrix4 {
+	return NewMatrix4([]float64{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1})
+}
+
+// NewTranslation4 creates a matrix that translates points by (x, y, z)
+func NewTranslation4(x, y, z float64) *Matrix4 {
+	return NewMatrix4([]float64{
+		1, 0, 0, x,
+		0, 1, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1})
+}
+
+// NewScale4 creates a matrix that scales along each axis by (x, y, z)
+func NewScale4(x, y, z float64) *Matrix4 {
+	return NewMatrix4([]float64{
+		x, 0, 0, 0,
+		0, y, 0, 0,
+		0, 0, z, 0,
+		0, 0, 0, 1})
+}
+
+// NewRotationX4 creates a matrix that rotates by angle radians around the
+// x axis, counterclockwise when looking down the axis towards the origin
+func NewRotationX4(angle float64) *Matrix4 {
+	c, s := math.Cos(angle), math.Sin(angle)
+	return NewMatrix4([]float64{
+		1, 0, 0, 0,
+		0, c, -s, 0,
+		0, s, c, 0,
+		0, 0, 0, 1})
+}
+
+// NewRotationY4 creates a matrix that rotates by angle radians around the
+// y axis
+func NewRotationY4(angle float64) *Matrix4 {
+	c, s := math.Cos(angle), math.Sin(angle)
+	return NewMatrix4([]float64{
+		c, 0, s, 0,
+		0, 1, 0, 0,
+		-s, 0, c, 0,
+		0, 0, 0, 1})
+}
+
+// NewRotationZ4 creates a matrix that rotates by angle radians around the
+// z axis
+func NewRotationZ4(angle float64) *Matrix4 {
+	c, s := math.Cos(angle), math.Sin(angle)
+	return NewMatrix4([]float64{
+		c, -s, 0, 0,
+		s, c, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1})
+}
+
+// NewRotation4 creates a matrix that rotates by angle radians around an
+// arbitrary axis through the origin. The axis does not need to be
+// normalized
+func NewRotation4(axis vec.Vec3, angle float64) *Matrix4 {
+	if axis.Magnitude == 0 {
+		return NewIdentity4()
+	}
+	a := vec.Divide(axis, axis.Magnitude)
+	c, s := math.Cos(angle), math.Sin(angle)
+	t := 1 - c
+	return NewMatrix4([]float64{
+		t*a.X*a.X + c, t*a.X*a.Y - s*a.Z, t*a.X*a.Z + s*a.Y, 0,
+		t*a.X*a.Y + s*a.Z, t*a.Y*a.Y + c, t*a.Y*a.Z - s*a.X, 0,
+		t*a.X*a.Z - s*a.Y, t*a.Y*a.Z + s*a.X, t*a.Z*a.Z + c, 0,
+		0, 0, 0, 1})
+}
+
+// NewTRS4 creates a matrix that scales, then rotates by angle radians
+// around axis, then translates, which is the usual way to place an object
+func NewTRS4(translation, axis vec.Vec3, angle float64, scale vec.Vec3) *Matrix4 {
+	m := NewScale4(scale.X, scale.Y, scale.Z)
+	m = Multiply4(NewRotation4(axis, angle), m)
+	return Multiply4(NewTranslation4(translation.X, translation.Y, translation.Z), m)
+}
+
+// Get returns value of matrix at row i, column j (indexed at 0)
+func (m *Matrix4) Get(i, j int) (float64, error) {
+	if i < 0 || i >= 4 || j < 0 || j >= 4 {
+		return 0, errors.New("rows or columns out of bounds")
+	}
+	return m.values[i*4+j], nil
+}
+
+// Set changes values of matrix at row i, column j (indexed at 0)
+func (m *Matrix4) Set(i, j int, v float64) error {
+	if i < 0 || i >= 4 || j < 0 || j >= 4 {
+		return errors.New("rows or columns out of bounds")
+	}
+	m.values[i*4+j] = v
+	return nil
+}
+
+// Multiply4 multiplies two 4x4 matricies and returns a third.
+// The result applies m2 first, then m1
+func Multiply4(m1, m2 *Matrix4) *Matrix4 {
+	m3 := new(Matrix4)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			sum := 0.0
+			for k := 0; k < 4; k++ {
+				sum += m1.values[i*4+k] * m2.values[k*4+j]
+			}
+			m3.values[i*4+j] = sum
+		}
+	}
+	return m3
+}
+
+// Transpose returns the transpose of the matrix
+func (m *Matrix4) Transpose() *Matrix4 {
+	t := new(Matrix4)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			t.values[j*4+i] = m.values[i*4+j]
+		}
+	}
+	return t
+}
+
+// Inverse returns the inverse of the matrix using Gauss-Jordan elimination
+// with partial pivoting. Singular matricies return an error
+func (m *Matrix4) Inverse() (*Matrix4, error) {
+	a := m.values
+	inv := NewIdentity4().values
+
+	for c := 0; c < 4; c++ {
+		// Find the largest pivot in this column
+		pivot := c
+		for r := c + 1; r < 4; r++ {
+			if math.Abs(a[r*4+c]) > math.Abs(a[pivot*4+c]) {
+				pivot = r
+			}
+		}
+
+		if a[pivot*4+c] == 0 {
+			return nil, errors.New("Matrix is singular and cannot be inverted")
+		}
+
+		if pivot != c {
+			for k := 0; k < 4; k++ {
+				a[c*4+k], a[pivot*4+k] = a[pivot*4+k], a[c*4+k]
+				inv[c*4+k], inv[pivot*4+k] = inv[pivot*4+k], inv[c*4+k]
+			}
+		}
+
+		d := 1 / a[c*4+c]
+		for k := 0; k < 4; k++ {
+			a[c*4+k] *= d
+			inv[c*4+k] *= d
+		}
+
+		for r := 0; r < 4; r++ {
+			if r == c {
+				continue
+			}
+			f := a[r*4+c]
+			if f == 0 {
+				continue
+			}
+			for k := 0; k < 4; k++ {
+				a[r*4+k] -= f * a[c*4+k]
+				inv[r*4+k] -= f * inv[c*4+k]
+			}
+		}
+	}
+
+	return &Matrix4{inv}, nil
+}
+
+// TransformPoint applies the matrix to a point (w = 1)
+func TransformPoint(m *Matrix4, p vec.Vec3) vec.Vec3 {
+	v := m.values
+	x := v[0]*p.X + v[1]*p.Y + v[2]*p.Z + v[3]
+	y := v[4]*p.X + v[5]*p.Y + v[6]*p.Z + v[7]
+	z := v[8]*p.X + v[9]*p.Y + v[10]*p.Z + v[11]
+	w := v[12]*p.X + v[13]*p.Y + v[14]*p.Z + v[15]
+	if w != 1 && w != 0 {
+		x /= w
+		y /= w
+		z /= w
+	}
+	return *vec.NewVec3(x, y, z)
+}
+
+// TransformDirection applies the matrix to a direction (w = 0), which
+// ignores any translation
+func TransformDirection(m *Matrix4, d vec.Vec3) vec.Vec3 {
+	v := m.values
+	x := v[0]*d.X + v[1]*d.Y + v[2]*d.Z
+	y := v[4]*d.X + v[5]*d.Y + v[6]*d.Z
+	z := v[8]*d.X + v[9]*d.Y + v[10]*d.Z
+	return *vec.NewVec3(x, y, z)
+}
+
+// TransformNormal transforms a surface normal given the inverse of the
+// matrix that transforms the surface. Normals transform by the inverse
+// transpose so they stay perpendicular under non-uniform scaling
+func TransformNormal(inverse *Matrix4, n vec.Vec3) vec.Vec3 {
+	v := inverse.values
+	x := v[0]*n.X + v[4]*n.Y + v[8]*n.Z
+	y := v[1]*n.X + v[5]*n.Y + v[9]*n.Z
+	z := v[2]*n.X + v[6]*n.Y + v[10]*n.Z
+	return *vec.NewVec3(x, y, z)
+}
+
+// String returns a semi-formatted representation of the matrix
+func (m Matrix4) String() string {
+	var str string
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			str += strconv.FormatFloat(m.values[i*4+j], 'f', -1, 64)
+			str += " "
+		}
+		str += "\n"
+	}
+	return str
+}
+
+// IsEqual4 compares two 4x4 matricies value by value within tolerance
+func IsEqual4(m1, m2 *Matrix4, tolerance float64) bool {
+	for k := range m1.values {
+		if math.Abs(m1.values[k]-m2.values[k]) >= tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// TransformPointError bounds the floating point error of TransformPoint
+// applied to a point p that already carries an error bound err, following
+// Physically Based Rendering. The matrix is assumed to be affine
+func TransformPointError(m *Matrix4, p, err vec.Vec3) vec.Vec3 {
+	g := 3 * machineEpsilon / (1 - 3*machineEpsilon)
+	v := m.values
+	bound := [3]float64{}
+	for i := 0; i < 3; i++ {
+		a := math.Abs(v[i*4])
+		b := math.Abs(v[i*4+1])
+		c := math.Abs(v[i*4+2])
+		bound[i] = (g+1)*(a*err.X+b*err.Y+c*err.Z) +
+			g*(math.Abs(v[i*4]*p.X)+math.Abs(v[i*4+1]*p.Y)+math.Abs(v[i*4+2]*p.Z)+math.Abs(v[i*4+3]))
+	}
+	return *vec.NewVec3(bound[0], bound[1], bound[2])
+}
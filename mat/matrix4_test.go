@@ -0,0 +1,99 @@
+package mat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/vec"
+)
+
+func TestMatrix4Inverse(t *testing.T) {
+	t.Parallel()
+
+	m := Multiply4(NewTranslation4(1, 2, 3), NewScale4(2, 4, 0.5))
+	inv, err := m.Inverse()
+	if err != nil {
+		t.Error("Matrix should be invertible")
+	}
+
+	if !IsEqual4(Multiply4(m, inv), NewIdentity4(), 0.0001) {
+		t.Error("Matrix multiplied by its inverse should be identity")
+	}
+}
+
+func TestMatrix4InverseSingular(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewScale4(1, 0, 1).Inverse()
+	if err == nil {
+		t.Error("Singular matrix should not be invertible")
+	}
+}
+
+func TestMatrix4Transform(t *testing.T) {
+	t.Parallel()
+
+	m := Multiply4(NewTranslation4(1, 2, 3), NewScale4(2, 2, 2))
+	p := TransformPoint(m, *vec.NewVec3(1, 1, 1))
+	d := TransformDirection(m, *vec.NewVec3(1, 1, 1))
+
+	if p.X != 3 || p.Y != 4 || p.Z != 5 {
+		t.Error("Point was not transformed correctly")
+	}
+
+	if d.X != 2 || d.Y != 2 || d.Z != 2 {
+		t.Error("Direction should be scaled but not translated")
+	}
+}
+
+func TestMatrix4TransformNormal(t *testing.T) {
+	t.Parallel()
+
+	// Plane x + y = 0 squashed along x keeps its normal perpendicular
+	m := NewScale4(0.5, 1, 1)
+	inv, _ := m.Inverse()
+	n := TransformNormal(inv, *vec.NewVec3(1, 1, 0))
+	tangent := TransformDirection(m, *vec.NewVec3(1, -1, 0))
+
+	if math.Abs(vec.Dot(n, tangent)) > 0.0001 {
+		t.Error("Transformed normal is not perpendicular to the surface")
+	}
+}
+
+func TestMatrix4Rotation(t *testing.T) {
+	t.Parallel()
+
+	p := *vec.NewVec3(1, 0, 0)
+	r := TransformPoint(NewRotationZ4(math.Pi/2), p)
+	if math.Abs(r.X) > 1e-12 || math.Abs(r.Y-1) > 1e-12 {
+		t.Error("Rotation around z not correct", r)
+	}
+
+	// Rotating around a principal axis matches the dedicated builders
+	axes := []vec.Vec3{*vec.NewVec3(1, 0, 0), *vec.NewVec3(0, 2, 0), *vec.NewVec3(0, 0, 1)}
+	builders := []*Matrix4{NewRotationX4(0.3), NewRotationY4(0.3), NewRotationZ4(0.3)}
+	for k, axis := range axes {
+		if !IsEqual4(NewRotation4(axis, 0.3), builders[k], 1e-12) {
+			t.Error("Axis-angle rotation does not match", axis)
+		}
+	}
+
+	// Rotations are orthonormal, so their inverse is their transpose
+	m := NewRotation4(*vec.NewVec3(1, 2, 3), 1.1)
+	inv, _ := m.Inverse()
+	if !IsEqual4(inv, m.Transpose(), 1e-12) {
+		t.Error("Rotation inverse should be its transpose")
+	}
+}
+
+func TestMatrix4TRS(t *testing.T) {
+	t.Parallel()
+
+	m := NewTRS4(*vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 1, 0), math.Pi/2, *vec.NewVec3(2, 1, 1))
+	p := TransformPoint(m, *vec.NewVec3(1, 0, 0))
+
+	// Scaled to x = 2, rotated onto -z, then moved back
+	if math.Abs(p.X) > 1e-12 || math.Abs(p.Z+7) > 1e-12 {
+		t.Error("Transform order not correct", p)
+	}
+}
@@ -0,0 +1,181 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// BezierPatch is a bicubic Bezier surface defined by a 4x4 grid of
+// control points. Like PolygonMesh it is a container for geometry and is
+// rendered by tessellating it into triangles. Control points are stored
+// row by row: Control[i*4+j] is the jth point along u of the ith row
+// along v
+type BezierPatch struct {
+	Control [16]vec.Vec3
+}
+
+// bernstein returns the cubic Bernstein basis functions at t
+func bernstein(t float64) [4]float64 {
+	s := 1 - t
+	return [4]float64{s * s * s, 3 * t * s * s, 3 * t * t * s, t * t * t}
+}
+
+// bernsteinDerivative returns the derivatives of the cubic Bernstein
+// basis functions at t
+func bernsteinDerivative(t float64) [4]float64 {
+	s := 1 - t
+	return [4]float64{-3 * s * s, 3*s*s - 6*t*s, 6*t*s - 3*t*t, 3 * t * t}
+}
+
+// combine sums the control points weighted by bu along u and bv along v
+func (b *BezierPatch) combine(bu, bv [4]float64) vec.Vec3 {
+	var x, y, z float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			w := bv[i] * bu[j]
+			c := b.Control[i*4+j]
+			x += w * c.X
+			y += w * c.Y
+			z += w * c.Z
+		}
+	}
+	return *vec.NewVec3(x, y, z)
+}
+
+// Evaluate returns the point on the patch at (u, v)
+func (b *BezierPatch) Evaluate(u, v float64) vec.Vec3 {
+	return b.combine(bernstein(u), bernstein(v))
+}
+
+// Derivatives returns the partial derivatives of the patch along u and v
+// at (u, v)
+func (b *BezierPatch) Derivatives(u, v float64) (vec.Vec3, vec.Vec3) {
+	dpdu := b.combine(bernsteinDerivative(u), bernstein(v))
+	dpdv := b.combine(bernstein(u), bernsteinDerivative(v))
+	return dpdu, dpdv
+}
+
+// Normal returns the unit normal of the patch at (u, v), the cross product
+// of its derivatives. Where control points collapse, as at the top of the
+// teapot lid, a derivative vanishes and the normal is taken from just
+// inside the patch instead
+func (b *BezierPatch) Normal(u, v float64) vec.Vec3 {
+	for _, nudge := range []float64{0, 1e-6, 1e-4, 1e-2} {
+		dpdu, dpdv := b.Derivatives(u+(0.5-u)*nudge, v+(0.5-v)*nudge)
+		n := vec.Cross(dpdu, dpdv)
+		if n.Magnitude > 1e-12*dpdu.Magnitude*dpdv.Magnitude {
+			return vec.Divide(n, n.Magnitude)
+		}
+	}
+	return *vec.NewVec3(0, 0, 0)
+}
+
+// flatness bounds how far the patch bends. It returns the largest second
+// difference of the control net along u or v, and the largest mixed
+// difference across a cell of the net
+func (b *BezierPatch) flatness() (float64, float64) {
+	second := 0.0
+	mixed := 0.0
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 2; j++ {
+			// Along u within row i, and along v within column i
+			du := vec.Add(vec.Subtract(b.Control[i*4+j], vec.Multiply(b.Control[i*4+j+1], 2)), b.Control[i*4+j+2])
+			dv := vec.Add(vec.Subtract(b.Control[j*4+i], vec.Multiply(b.Control[(j+1)*4+i], 2)), b.Control[(j+2)*4+i])
+			second = math.Max(second, math.Max(du.Magnitude, dv.Magnitude))
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			d := vec.Subtract(
+				vec.Add(b.Control[i*4+j], b.Control[(i+1)*4+j+1]),
+				vec.Add(b.Control[i*4+j+1], b.Control[(i+1)*4+j]))
+			mixed = math.Max(mixed, d.Magnitude)
+		}
+	}
+	return second, mixed
+}
+
+// BezierSegments returns the number of segments along u and v needed for
+// the triangles of every patch to stay within tolerance of the surface.
+// Linear interpolation over a cell of size h is off by at most
+// h^2 / 8 * (|Puu| + 2|Puv| + |Pvv|), and the derivatives of a bicubic
+// patch are bounded by its control net differences.
+// The tessellation is uniform: one count is returned for all patches, set
+// by the most curved one, so flat patches get as many triangles as curved
+// ones. Patches are not told which of them share edges, and a single
+// count is what keeps those edges free of cracks
+func BezierSegments(patches []BezierPatch, tolerance float64) int {
+	bound := 0.0
+	for i := range patches {
+		second, mixed := patches[i].flatness()
+		bound = math.Max(bound, 1.5*second+2.25*mixed)
+	}
+	if tolerance <= 0 || bound == 0 {
+		return 1
+	}
+	return int(math.Max(1, math.Ceil(math.Sqrt(bound/tolerance))))
+}
+
+// TessellateBezierPatches evaluates every patch on a grid of segments x
+// segments quads and returns them as a PolygonMesh of triangles, with
+// analytic vertex normals and the patch parameters as texture
+// coordinates. All patches use the same grid so neighbouring patches meet
+// without cracks
+func TessellateBezierPatches(patches []BezierPatch, segments int) *PolygonMesh {
+	if segments < 1 {
+		segments = 1
+	}
+	side := segments + 1
+
+	poly := MakePolygonMesh()
+	for k := range patches {
+		b := &patches[k]
+		base := len(poly.Verticies) / 3
+
+		for i := 0; i < side; i++ {
+			v := float64(i) / float64(segments)
+			for j := 0; j < side; j++ {
+				u := float64(j) / float64(segments)
+				p := b.Evaluate(u, v)
+				n := b.Normal(u, v)
+				poly.Verticies = append(poly.Verticies, p.X, p.Y, p.Z)
+				poly.VertexNormals = append(poly.VertexNormals, n.X, n.Y, n.Z)
+				poly.TextureVertecies = append(poly.TextureVertecies, u, v)
+			}
+		}
+
+		for i := 0; i < segments; i++ {
+			for j := 0; j < segments; j++ {
+				a := base + i*side + j
+				quad := [6]int{a, a + 1, a + side + 1, a, a + side + 1, a + side}
+				for t := 0; t < 6; t += 3 {
+					tri := quad[t : t+3]
+					if degenerateTriangle(poly.Verticies, tri) {
+						// Collapsed control points leave zero area slivers
+						continue
+					}
+					poly.VertexIndecies = append(poly.VertexIndecies, tri...)
+					poly.NormalIndecies = append(poly.NormalIndecies, tri...)
+					poly.TextureIndecies = append(poly.TextureIndecies, tri...)
+					poly.NumVerticies = append(poly.NumVerticies, 3)
+					poly.NumFaces[0]++
+				}
+			}
+		}
+	}
+	return poly
+}
+
+// degenerateTriangle reports whether the triangle with the given vertex
+// indecies has collapsed to a line or a point
+func degenerateTriangle(verticies []float64, tri []int) bool {
+	vertex := func(i int) vec.Vec3 {
+		return *vec.NewVec3(verticies[i*3], verticies[i*3+1], verticies[i*3+2])
+	}
+	v0, v1, v2 := vertex(tri[0]), vertex(tri[1]), vertex(tri[2])
+	e0 := vec.Subtract(v1, v0).Magnitude
+	e1 := vec.Subtract(v2, v1).Magnitude
+	e2 := vec.Subtract(v0, v2).Magnitude
+	return math.Min(e0, math.Min(e1, e2)) <= 1e-9*(e0+e1+e2)
+}
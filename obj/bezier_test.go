@@ -0,0 +1,102 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// makeBumpPatch returns a patch over the unit square whose middle control
+// points are raised, so x = u and y = v exactly
+func makeBumpPatch() BezierPatch {
+	var b BezierPatch
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			z := 0.0
+			if i > 0 && i < 3 && j > 0 && j < 3 {
+				z = 1
+			}
+			b.Control[i*4+j] = *vec.NewVec3(float64(j)/3, float64(i)/3, z)
+		}
+	}
+	return b
+}
+
+func TestBezierPatchEvaluate(t *testing.T) {
+	t.Parallel()
+
+	b := makeBumpPatch()
+	p := b.Evaluate(0.5, 0.5)
+
+	// The middle basis functions sum to 3/4 at t = 1/2
+	if math.Abs(p.X-0.5) > 1e-12 || math.Abs(p.Y-0.5) > 1e-12 || math.Abs(p.Z-0.5625) > 1e-12 {
+		t.Error("Patch point not correct", p)
+	}
+
+	// The normal matches finite differences of the surface
+	u, v, h := 0.3, 0.6, 1e-6
+	dpdu := vec.Divide(vec.Subtract(b.Evaluate(u+h, v), b.Evaluate(u-h, v)), 2*h)
+	dpdv := vec.Divide(vec.Subtract(b.Evaluate(u, v+h), b.Evaluate(u, v-h)), 2*h)
+	expected := vec.Cross(dpdu, dpdv)
+	expected = vec.Divide(expected, expected.Magnitude)
+	if vec.Subtract(b.Normal(u, v), expected).Magnitude > 1e-6 {
+		t.Error("N vector not correct", b.Normal(u, v), expected)
+	}
+}
+
+func TestBezierPatchCollapsedEdge(t *testing.T) {
+	t.Parallel()
+
+	// Collapse the first row into a single point, like the top of a lid
+	b := makeBumpPatch()
+	for j := 0; j < 4; j++ {
+		b.Control[j] = *vec.NewVec3(0.5, 0, 0)
+	}
+
+	n := b.Normal(0.5, 0)
+	if math.Abs(n.Magnitude-1) > 1e-9 {
+		t.Error("Collapsed edge should still have a normal", n)
+	}
+
+	poly := TessellateBezierPatches([]BezierPatch{b}, 4)
+	if len(poly.NumVerticies) != 4*4*2-4 {
+		t.Error("Triangles along the collapsed edge should be dropped", len(poly.NumVerticies))
+	}
+}
+
+func TestTessellateBezierPatches(t *testing.T) {
+	t.Parallel()
+
+	b := makeBumpPatch()
+	tolerance := 0.01
+	segments := BezierSegments([]BezierPatch{b}, tolerance)
+	poly := TessellateBezierPatches([]BezierPatch{b}, segments)
+	mesh := NewTriangleMesh("bump", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+
+	if mesh.NumTriangles() != segments*segments*2 {
+		t.Fatal("Wrong number of triangles", mesh.NumTriangles())
+	}
+
+	for _, xy := range [][2]float64{{0.5, 0.5}, {0.21, 0.77}, {0.9, 0.1}} {
+		ray := cam.NewRay(0, "camera", vec.NewVec3(xy[0], xy[1], 5), vec.NewVec3(0, 0, -1))
+		rec, is_hit := mesh.Intersects(ray)
+		if !is_hit {
+			t.Fatal("Tessellated patch was not hit")
+		}
+
+		// x = u and y = v on this patch
+		surface := b.Evaluate(xy[0], xy[1])
+		if math.Abs(rec.Point.Z-surface.Z) > tolerance {
+			t.Error("Tessellation is not within tolerance", rec.Point.Z, surface.Z)
+		}
+		if math.Abs(rec.U-xy[0]) > 1e-9 || math.Abs(rec.V-xy[1]) > 1e-9 {
+			t.Error("Texture coordinates should be the patch parameters", rec.U, rec.V)
+		}
+		if vec.Subtract(rec.Normal, b.Normal(xy[0], xy[1])).Magnitude > 0.05 {
+			t.Error("Shading normal should follow the analytic normal", rec.Normal)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Box is a rectangular solid. Axis-aligned boxes are created with NewBox
+// and oriented boxes with NewOrientedBox; both are intersected in the
+// box's own frame, centered on Center
+type Box struct {
+	ID              string
+	Center          vec.Vec3
+	Size            vec.Vec3 // full extent along the local x, y and z axes
+	Col             color.RGBA
+	RefractiveIndex float64
+	frame           frame
+}
+
+// NewBox is a constructor for axis-aligned boxes spanning two opposite
+// corners
+func NewBox(id string, p0, p1 vec.Vec3, col color.RGBA, refractive float64) *Box {
+	b := NewAABB(p0, p1)
+	return NewOrientedBox(
+		id,
+		b.Centroid(),
+		vec.Subtract(b.Max, b.Min),
+		*vec.NewVec3(0, 0, 1),
+		*vec.NewVec3(1, 0, 0),
+		col,
+		refractive)
+}
+
+// NewOrientedBox is a constructor for boxes of the given size centered on
+// center. The box's local z axis points along axis and its local x axis
+// along xAxis, projected perpendicular to axis
+func NewOrientedBox(id string, center, size, axis, xAxis vec.Vec3, col color.RGBA, refractive float64) *Box {
+	b := new(Box)
+	b.ID = id
+	b.Center = center
+	b.Size = size
+	b.Col = col
+	b.RefractiveIndex = refractive
+	b.frame = newAlignedFrame(center, axis, xAxis)
+	return b
+}
+
+// GetID is the object specific method to return the ID of the box
+func (b *Box) GetID() string {
+	return b.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (b *Box) GetColor() color.RGBA {
+	return b.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (b *Box) GetRefractiveIndex() float64 {
+	return b.RefractiveIndex
+}
+
+// halfSize returns half of the box's extent along each local axis
+func (b *Box) halfSize() [3]float64 {
+	return [3]float64{b.Size.X * 0.5, b.Size.Y * 0.5, b.Size.Z * 0.5}
+}
+
+// Bounds returns the axis-aligned bounding box of the box
+func (b *Box) Bounds() AABB {
+	h := b.halfSize()
+	return b.frame.bounds(AABB{*vec.NewVec3(-h[0], -h[1], -h[2]), *vec.NewVec3(h[0], h[1], h[2])})
+}
+
+// slabs intersects the ray with the box in its local frame. It returns
+// the entry and exit distances and the local axis crossed at each. The
+// entry is negative when the ray starts inside the box
+func (b *Box) slabs(o, d vec.Vec3) (bool, float64, float64, int, int) {
+	h := b.halfSize()
+	org := [3]float64{o.X, o.Y, o.Z}
+	dir := [3]float64{d.X, d.Y, d.Z}
+
+	tNear := math.Inf(-1)
+	tFar := math.Inf(1)
+	nearAxis, farAxis := 0, 0
+	for i := 0; i < 3; i++ {
+		if dir[i] == 0 {
+			if org[i] < -h[i] || org[i] > h[i] {
+				return false, 0, 0, 0, 0
+			}
+			continue
+		}
+		inv := 1 / dir[i]
+		t0 := (-h[i] - org[i]) * inv
+		t1 := (h[i] - org[i]) * inv
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tNear {
+			tNear = t0
+			nearAxis = i
+		}
+		if t1 < tFar {
+			tFar = t1
+			farAxis = i
+		}
+		if tNear > tFar {
+			return false, 0, 0, 0, 0
+		}
+	}
+	return tFar > 0, tNear, tFar, nearAxis, farAxis
+}
+
+// faceHit builds the hit record for a point on the face perpendicular to
+// the given local axis
+func (b *Box) faceHit(o, d, worldDir vec.Vec3, t, t1 float64, axis int) HitRecord {
+	h := b.halfSize()
+	p := vec.Add(o, vec.Multiply(d, t))
+	coords := [3]float64{p.X, p.Y, p.Z}
+
+	// Snap onto the face and keep the rest inside the box
+	side := 1.0
+	if coords[axis] < 0 {
+		side = -1
+	}
+	coords[axis] = side * h[axis]
+	for i := range coords {
+		coords[i] = math.Max(-h[i], math.Min(h[i], coords[i]))
+	}
+	p = *vec.NewVec3(coords[0], coords[1], coords[2])
+
+	var normal, dpdu [3]float64
+	normal[axis] = side
+	ua := (axis + 1) % 3
+	va := (axis + 2) % 3
+	dpdu[ua] = 1
+
+	// Face UVs run from 0 to 1 across each face
+	u := 0.5
+	v := 0.5
+	if h[ua] > 0 {
+		u = (coords[ua] + h[ua]) / (2 * h[ua])
+	}
+	if h[va] > 0 {
+		v = (coords[va] + h[va]) / (2 * h[va])
+	}
+
+	return b.frame.hitRecord(
+		b,
+		worldDir,
+		p,
+		*vec.NewVec3(normal[0], normal[1], normal[2]),
+		*vec.NewVec3(dpdu[0], dpdu[1], dpdu[2]),
+		localError(p, 3),
+		t,
+		t1,
+		u,
+		v)
+}
+
+// Intersects checks for intersections between a ray and the box. When the
+// ray starts inside the box the exit point is returned, like Sphere
+func (b *Box) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	o, d := b.frame.localRay(ray)
+	isHit, tNear, tFar, nearAxis, farAxis := b.slabs(o, d)
+	if !isHit {
+		return FalseObject()
+	}
+
+	worldDir := ray.Direction
+	worldDir.Normalize()
+
+	if tNear > 0 {
+		return b.faceHit(o, d, worldDir, tNear, tFar, nearAxis), true
+	}
+	return b.faceHit(o, d, worldDir, tFar, tFar, farAxis), true
+}
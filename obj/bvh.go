@@ -0,0 +1,313 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Maximum number of primitives stored in a single BVH leaf
+const bvhLeafSize = 4
+
+// AABB is an axis-aligned bounding box
+type AABB struct {
+	Min vec.Vec3
+	Max vec.Vec3
+}
+
+// EmptyAABB returns an inverted box that any Extend or Union will replace
+func EmptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{
+		*vec.NewVec3(inf, inf, inf),
+		*vec.NewVec3(-inf, -inf, -inf)}
+}
+
+// NewAABB creates a bounding box from any two opposite corners
+func NewAABB(p0, p1 vec.Vec3) AABB {
+	return AABB{
+		*vec.NewVec3(math.Min(p0.X, p1.X), math.Min(p0.Y, p1.Y), math.Min(p0.Z, p1.Z)),
+		*vec.NewVec3(math.Max(p0.X, p1.X), math.Max(p0.Y, p1.Y), math.Max(p0.Z, p1.Z))}
+}
+
+// Extend grows the box to contain the point p
+func (b AABB) Extend(p vec.Vec3) AABB {
+	return AABB{
+		*vec.NewVec3(math.Min(b.Min.X, p.X), math.Min(b.Min.Y, p.Y), math.Min(b.Min.Z, p.Z)),
+		*vec.NewVec3(math.Max(b.Max.X, p.X), math.Max(b.Max.Y, p.Y), math.Max(b.Max.Z, p.Z))}
+}
+
+// Union returns the smallest box containing both boxes
+func Union(b1, b2 AABB) AABB {
+	return b1.Extend(b2.Min).Extend(b2.Max)
+}
+
+// Centroid returns the center point of the box
+func (b AABB) Centroid() vec.Vec3 {
+	return *vec.NewVec3(
+		(b.Min.X+b.Max.X)*0.5,
+		(b.Min.Y+b.Max.Y)*0.5,
+		(b.Min.Z+b.Max.Z)*0.5)
+}
+
+// Corners returns the eight corners of the box
+func (b AABB) Corners() [8]vec.Vec3 {
+	var corners [8]vec.Vec3
+	for i := 0; i < 8; i++ {
+		x, y, z := b.Min.X, b.Min.Y, b.Min.Z
+		if i&1 != 0 {
+			x = b.Max.X
+		}
+		if i&2 != 0 {
+			y = b.Max.Y
+		}
+		if i&4 != 0 {
+			z = b.Max.Z
+		}
+		corners[i] = *vec.NewVec3(x, y, z)
+	}
+	return corners
+}
+
+// IsFinite reports whether every coordinate of the box is finite
+func (b AABB) IsFinite() bool {
+	for _, v := range []float64{b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z} {
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsRay uses the slab method to test the box against a ray with
+// origin org and inverse direction invDir. It returns the entry and exit
+// distances of the overlap with [0, maxDist]
+func (b AABB) IntersectsRay(org, invDir vec.Vec3, maxDist float64) (bool, float64, float64) {
+	tmin := 0.0
+	tmax := maxDist
+
+	slabs := [3][4]float64{
+		{org.X, invDir.X, b.Min.X, b.Max.X},
+		{org.Y, invDir.Y, b.Min.Y, b.Max.Y},
+		{org.Z, invDir.Z, b.Min.Z, b.Max.Z}}
+
+	for _, s := range slabs {
+		t0 := (s[2] - s[0]) * s[1]
+		t1 := (s[3] - s[0]) * s[1]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		// NaN comparisons fail here which keeps the current bounds
+		if t0 > tmin {
+			tmin = t0
+		}
+		if t1 < tmax {
+			tmax = t1
+		}
+		if tmin > tmax {
+			return false, 0, 0
+		}
+	}
+	return true, tmin, tmax
+}
+
+// bvhNode is one node of a flattened bounding volume hierarchy.
+// Leaves have count > 0 and reference indices[start:start+count],
+// interior nodes reference their children by node index
+type bvhNode struct {
+	bounds AABB
+	left   int
+	right  int
+	start  int
+	count  int
+}
+
+// bvhTree is a bounding volume hierarchy over primitive indices. It knows
+// nothing about the primitives themselves, so it can be shared by objects
+// that store their primitives differently (objects, triangle indices, ...)
+type bvhTree struct {
+	nodes   []bvhNode
+	indices []int
+}
+
+// newBVHTree builds a hierarchy over a list of primitive bounds by
+// splitting on the median centroid of the longest axis
+func newBVHTree(bounds []AABB) *bvhTree {
+	tree := new(bvhTree)
+	tree.indices = make([]int, len(bounds), len(bounds))
+	for i := range tree.indices {
+		tree.indices[i] = i
+	}
+
+	if len(bounds) == 0 {
+		return tree
+	}
+
+	centroids := make([]vec.Vec3, len(bounds), len(bounds))
+	for i, b := range bounds {
+		centroids[i] = b.Centroid()
+	}
+
+	tree.nodes = make([]bvhNode, 0, 2*len(bounds)/bvhLeafSize+1)
+	tree.build(bounds, centroids, 0, len(bounds))
+	return tree
+}
+
+func (tree *bvhTree) build(bounds []AABB, centroids []vec.Vec3, start, end int) int {
+	nodeIndex := len(tree.nodes)
+	tree.nodes = append(tree.nodes, bvhNode{})
+
+	box := EmptyAABB()
+	centroidBox := EmptyAABB()
+	for _, i := range tree.indices[start:end] {
+		box = Union(box, bounds[i])
+		centroidBox = centroidBox.Extend(centroids[i])
+	}
+
+	if end-start <= bvhLeafSize {
+		tree.nodes[nodeIndex] = bvhNode{box, -1, -1, start, end - start}
+		return nodeIndex
+	}
+
+	// Split along the longest axis of the centroid bounds
+	extent := vec.Subtract(centroidBox.Max, centroidBox.Min)
+	axis := func(v vec.Vec3) float64 { return v.X }
+	if extent.Y > extent.X && extent.Y >= extent.Z {
+		axis = func(v vec.Vec3) float64 { return v.Y }
+	} else if extent.Z > extent.X && extent.Z > extent.Y {
+		axis = func(v vec.Vec3) float64 { return v.Z }
+	}
+
+	span := tree.indices[start:end]
+	sort.Slice(span, func(a, b int) bool {
+		return axis(centroids[span[a]]) < axis(centroids[span[b]])
+	})
+
+	mid := start + (end-start)/2
+	left := tree.build(bounds, centroids, start, mid)
+	right := tree.build(bounds, centroids, mid, end)
+	tree.nodes[nodeIndex] = bvhNode{box, left, right, 0, 0}
+	return nodeIndex
+}
+
+// bounds returns the bounding box of the whole hierarchy
+func (tree *bvhTree) bounds() AABB {
+	if len(tree.nodes) == 0 {
+		return EmptyAABB()
+	}
+	return tree.nodes[0].bounds
+}
+
+// traverse walks every leaf the ray could touch closer than maxDist and
+// calls intersect for each primitive index. intersect returns the distance
+// of a hit and whether it was closer than the distance it was given, which
+// lets the traversal shrink its search as closer hits are found
+func (tree *bvhTree) traverse(ray *cam.Ray, maxDist float64, intersect func(i int, maxDist float64) (float64, bool)) (int, float64) {
+	if len(tree.nodes) == 0 {
+		return -1, maxDist
+	}
+
+	dir := ray.Direction
+	dir.Normalize()
+	invDir := *vec.NewVec3(1/dir.X, 1/dir.Y, 1/dir.Z)
+
+	closest := -1
+	stack := make([]int, 1, 64)
+	stack[0] = 0
+	for len(stack) > 0 {
+		node := &tree.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+
+		if hit, _, _ := node.bounds.IntersectsRay(ray.Origin, invDir, maxDist); !hit {
+			continue
+		}
+
+		if node.count > 0 {
+			for _, i := range tree.indices[node.start : node.start+node.count] {
+				if dist, closer := intersect(i, maxDist); closer {
+					maxDist = dist
+					closest = i
+				}
+			}
+		} else {
+			stack = append(stack, node.left, node.right)
+		}
+	}
+	return closest, maxDist
+}
+
+// BVH is a bounding volume hierarchy over a collection of objects.
+// It implements Object so a whole mesh can be added to a scene, or shared
+// between several Instances, as a single object
+type BVH struct {
+	ID              string
+	Objects         []Object
+	Col             color.RGBA
+	RefractiveIndex float64
+	tree            *bvhTree
+}
+
+// NewBVH builds a hierarchy over the objects. Hits refer to the contained
+// object, so each keeps its own material. The color and refractive index
+// of the BVH itself are taken from the first object
+func NewBVH(id string, objects []Object) *BVH {
+	b := new(BVH)
+	b.ID = id
+	b.Objects = objects
+
+	bounds := make([]AABB, len(objects), len(objects))
+	for i, o := range objects {
+		bounds[i] = o.Bounds()
+	}
+	b.tree = newBVHTree(bounds)
+
+	if len(objects) > 0 {
+		b.Col = objects[0].GetColor()
+		b.RefractiveIndex = objects[0].GetRefractiveIndex()
+	}
+	return b
+}
+
+// GetID is the object specific method to return the ID of the BVH
+func (b *BVH) GetID() string {
+	return b.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (b *BVH) GetColor() color.RGBA {
+	return b.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (b *BVH) GetRefractiveIndex() float64 {
+	return b.RefractiveIndex
+}
+
+// Bounds returns the bounding box around all contained objects
+func (b *BVH) Bounds() AABB {
+	return b.tree.bounds()
+}
+
+// Intersects returns the closest intersection with any contained object.
+// The record refers to the contained object that was hit
+func (b *BVH) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	var rec HitRecord
+	closest, _ := b.tree.traverse(ray, math.Inf(1), func(i int, maxDist float64) (float64, bool) {
+		r, isHit := b.Objects[i].Intersects(ray)
+		if !isHit || r.T0 < 0 || r.T0 >= maxDist {
+			return 0, false
+		}
+		rec = r
+		return r.T0, true
+	})
+
+	if closest < 0 {
+		return FalseObject()
+	}
+	return rec, true
+}
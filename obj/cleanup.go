@@ -0,0 +1,400 @@
+package obj
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// MeshReport summarizes the problems Clean found in a PolygonMesh and
+// what it did about them
+type MeshReport struct {
+	InvalidFaces           int // faces with too few or out of range verticies, removed
+	InvalidIndecies        int // out of range vertex indecies in those faces
+	InvalidNormalIndecies  int // out of range normal indecies, cleared
+	InvalidTextureIndecies int // out of range texture indecies, cleared
+	WeldedVerticies        int // verticies merged into another within the tolerance
+	RemovedVerticies       int // verticies no face uses after welding, removed
+	DegenerateFaces        int // faces with repeated verticies or no area, removed
+	FlippedFaces           int // faces reversed to match their neighbours
+	NonManifoldEdges       int // edges shared by more than two faces, left alone
+	Faces                  int // faces in the cleaned mesh
+	Verticies              int // verticies in the cleaned mesh
+}
+
+// Changed reports whether Clean had to fix anything
+func (r MeshReport) Changed() bool {
+	return r.InvalidFaces > 0 || r.InvalidNormalIndecies > 0 || r.InvalidTextureIndecies > 0 ||
+		r.WeldedVerticies > 0 || r.RemovedVerticies > 0 || r.DegenerateFaces > 0 || r.FlippedFaces > 0
+}
+
+// String stringifies the report
+func (r MeshReport) String() string {
+	return fmt.Sprintf(
+		"Faces: %v, Verticies: %v\n"+
+			"Invalid faces: %v (%v bad vertex indecies)\n"+
+			"Invalid normal indecies: %v, invalid texture indecies: %v\n"+
+			"Welded verticies: %v, removed verticies: %v\n"+
+			"Degenerate faces: %v, flipped faces: %v, non-manifold edges: %v\n",
+		r.Faces, r.Verticies,
+		r.InvalidFaces, r.InvalidIndecies,
+		r.InvalidNormalIndecies, r.InvalidTextureIndecies,
+		r.WeldedVerticies, r.RemovedVerticies,
+		r.DegenerateFaces, r.FlippedFaces, r.NonManifoldEdges)
+}
+
+// meshCorner is one corner of a face with its vertex, normal and texture
+// indecies. Missing normals and texture coordinates are -1
+type meshCorner struct {
+	v int
+	n int
+	t int
+}
+
+// Validate reports the problems Clean would fix without changing the mesh
+func (p *PolygonMesh) Validate(tolerance float64) MeshReport {
+	_, report := p.Clean(tolerance)
+	return report
+}
+
+// Clean returns a repaired copy of the mesh and a report of what was
+// wrong with it. Faces with out of range vertex indecies are dropped and
+// out of range normal and texture indecies cleared. Verticies closer than
+// tolerance are welded, after which faces that repeat a vertex or have no
+// area are dropped and verticies no face uses are removed. Finally faces
+// are turned to wind the same way as their neighbours, and closed parts
+// of the mesh are turned to face outward
+func (p *PolygonMesh) Clean(tolerance float64) (*PolygonMesh, MeshReport) {
+	var report MeshReport
+	faces := p.cornerFaces(&report)
+	verticies := p.verticiesVec3()
+
+	remap, welded := weldVerticies(verticies, tolerance)
+	report.WeldedVerticies = welded
+	for _, face := range faces {
+		for k := range face {
+			face[k].v = remap[face[k].v]
+		}
+	}
+
+	kept := faces[:0]
+	for _, face := range faces {
+		face = collapseCorners(face)
+		if len(face) < 3 || zeroArea(verticies, face) {
+			report.DegenerateFaces++
+			continue
+		}
+		kept = append(kept, face)
+	}
+	faces = kept
+
+	report.FlippedFaces, report.NonManifoldEdges = orientFaces(verticies, faces)
+
+	poly := p.packFaces(verticies, remap, faces)
+	report.RemovedVerticies = len(verticies) - len(poly.Verticies)/3 - welded
+	report.Faces = len(faces)
+	report.Verticies = len(poly.Verticies) / 3
+	return poly, report
+}
+
+// cornerFaces returns the corners of every face that only uses verticies
+// in the vertex buffer, recording the faces and indecies it had to drop
+func (p *PolygonMesh) cornerFaces(report *MeshReport) [][]meshCorner {
+	numVerticies := len(p.Verticies) / 3
+	numNormals := len(p.VertexNormals) / 3
+	numTextures := len(p.TextureVertecies) / 2
+
+	attribute := func(indecies []int, c, numValues int, invalid *int) int {
+		if len(indecies) != len(p.VertexIndecies) || indecies[c] == -1 {
+			return -1
+		}
+		if indecies[c] < 0 || indecies[c] >= numValues {
+			*invalid++
+			return -1
+		}
+		return indecies[c]
+	}
+
+	// Faces read from .mesh files are already split into triangles
+	totalVerticies := 0
+	totalTriangles := 0
+	for _, n := range p.NumVerticies {
+		totalVerticies += n
+		if n > 2 {
+			totalTriangles += n - 2
+		}
+	}
+	spans := make([][2]int, 0, len(p.NumVerticies))
+	if len(p.VertexIndecies) != totalVerticies && len(p.VertexIndecies) == totalTriangles*3 {
+		for i := 0; i+2 < len(p.VertexIndecies); i += 3 {
+			spans = append(spans, [2]int{i, 3})
+		}
+	} else {
+		start := 0
+		for _, n := range p.NumVerticies {
+			if n < 3 || start+n > len(p.VertexIndecies) {
+				report.InvalidFaces++
+			} else {
+				spans = append(spans, [2]int{start, n})
+			}
+			start += int(math.Max(0, float64(n)))
+		}
+	}
+
+	faces := make([][]meshCorner, 0, len(spans))
+	for _, span := range spans {
+		face := make([]meshCorner, span[1])
+		bad := 0
+		for k := range face {
+			c := span[0] + k
+			v := p.VertexIndecies[c]
+			if v < 0 || v >= numVerticies {
+				bad++
+				continue
+			}
+			face[k] = meshCorner{v,
+				attribute(p.NormalIndecies, c, numNormals, &report.InvalidNormalIndecies),
+				attribute(p.TextureIndecies, c, numTextures, &report.InvalidTextureIndecies)}
+		}
+		if bad > 0 {
+			report.InvalidFaces++
+			report.InvalidIndecies += bad
+			continue
+		}
+		faces = append(faces, face)
+	}
+	return faces
+}
+
+// weldVerticies maps every vertex to the first vertex within tolerance
+// of it, found through a hash grid with cells the size of the tolerance.
+// It returns the mapping and how many verticies were merged
+func weldVerticies(verticies []vec.Vec3, tolerance float64) ([]int, int) {
+	type cell [3]int64
+	cellOf := func(v vec.Vec3) cell {
+		if tolerance <= 0 {
+			return cell{int64(math.Float64bits(v.X)), int64(math.Float64bits(v.Y)), int64(math.Float64bits(v.Z))}
+		}
+		return cell{int64(math.Floor(v.X / tolerance)), int64(math.Floor(v.Y / tolerance)), int64(math.Floor(v.Z / tolerance))}
+	}
+
+	grid := make(map[cell][]int)
+	remap := make([]int, len(verticies))
+	welded := 0
+	for i, v := range verticies {
+		home := cellOf(v)
+		remap[i] = i
+		if tolerance <= 0 {
+			if others := grid[home]; len(others) > 0 {
+				remap[i] = others[0]
+				welded++
+				continue
+			}
+		} else {
+		search:
+			for dx := int64(-1); dx <= 1; dx++ {
+				for dy := int64(-1); dy <= 1; dy++ {
+					for dz := int64(-1); dz <= 1; dz++ {
+						for _, j := range grid[cell{home[0] + dx, home[1] + dy, home[2] + dz}] {
+							if vec.Subtract(v, verticies[j]).Magnitude <= tolerance {
+								remap[i] = j
+								break search
+							}
+						}
+					}
+				}
+			}
+			if remap[i] != i {
+				welded++
+				continue
+			}
+		}
+		grid[home] = append(grid[home], i)
+	}
+	return remap, welded
+}
+
+// collapseCorners removes corners that repeat the vertex before them,
+// which welding leaves behind
+func collapseCorners(face []meshCorner) []meshCorner {
+	collapsed := make([]meshCorner, 0, len(face))
+	for k, c := range face {
+		if c.v != face[(k+len(face)-1)%len(face)].v || len(face) == 1 {
+			collapsed = append(collapsed, c)
+		}
+	}
+	return collapsed
+}
+
+// faceNormal returns Newell's normal of the face, whose length is twice
+// its area
+func faceNormal(verticies []vec.Vec3, face []meshCorner) vec.Vec3 {
+	points := make([]vec.Vec3, len(face))
+	for k, c := range face {
+		points[k] = verticies[c.v]
+	}
+	return polygonNormal(points)
+}
+
+// zeroArea reports whether the face has no area relative to its size,
+// or repeats a vertex
+func zeroArea(verticies []vec.Vec3, face []meshCorner) bool {
+	seen := make(map[int]bool, len(face))
+	longest := 0.0
+	for k := range face {
+		if seen[face[k].v] {
+			return true
+		}
+		seen[face[k].v] = true
+		e := vec.Subtract(verticies[face[k].v], verticies[face[(k+1)%len(face)].v])
+		longest = math.Max(longest, e.Magnitude)
+	}
+	return faceNormal(verticies, face).Magnitude <= 1e-10*longest*longest
+}
+
+// faceEdge is one use of an edge by a face. forward is set when the face
+// runs along the edge from its smaller vertex to its larger one
+type faceEdge struct {
+	face    int
+	forward bool
+}
+
+// orientFaces reverses faces so that neighbours run along their shared
+// edges in opposite directions. Each connected part keeps the winding of
+// most of its faces, or, if it is closed, the winding that encloses a
+// positive volume. Edges shared by more than two faces do not connect
+// their faces. It returns how many faces were reversed and how many edges
+// were not manifold
+func orientFaces(verticies []vec.Vec3, faces [][]meshCorner) (int, int) {
+	edges := make(map[edgeKey][]faceEdge)
+	for f, face := range faces {
+		for k := range face {
+			a, b := face[k].v, face[(k+1)%len(face)].v
+			key := newEdgeKey(a, b)
+			edges[key] = append(edges[key], faceEdge{f, a < b})
+		}
+	}
+	nonManifold := 0
+	for _, uses := range edges {
+		if len(uses) > 2 {
+			nonManifold++
+		}
+	}
+
+	flip := make([]bool, len(faces))
+	visited := make([]bool, len(faces))
+	flipped := 0
+	for start := range faces {
+		if visited[start] {
+			continue
+		}
+
+		// Walk the part, choosing each neighbour's orientation from the
+		// face it was reached from
+		component := []int{start}
+		visited[start] = true
+		closed := true
+		for i := 0; i < len(component); i++ {
+			f := component[i]
+			face := faces[f]
+			for k := range face {
+				a, b := face[k].v, face[(k+1)%len(face)].v
+				uses := edges[newEdgeKey(a, b)]
+				if len(uses) != 2 {
+					closed = false
+					continue
+				}
+				ahead := (a < b) != flip[f]
+				for _, use := range uses {
+					if use.face == f || visited[use.face] {
+						continue
+					}
+					visited[use.face] = true
+					flip[use.face] = use.forward == ahead
+					component = append(component, use.face)
+				}
+			}
+		}
+
+		count := 0
+		for _, f := range component {
+			if flip[f] {
+				count++
+			}
+		}
+		reverse := 2*count > len(component)
+		if closed {
+			volume := 0.0
+			for _, f := range component {
+				n := faceNormal(verticies, faces[f])
+				if flip[f] {
+					n = vec.Invert(n)
+				}
+				volume += vec.Dot(n, verticies[faces[f][0].v])
+			}
+			reverse = volume < 0
+		}
+		for _, f := range component {
+			if flip[f] != reverse {
+				flipped++
+				face := faces[f]
+				for l, r := 0, len(face)-1; l < r; l, r = l+1, r-1 {
+					face[l], face[r] = face[r], face[l]
+				}
+			}
+		}
+	}
+	return flipped, nonManifold
+}
+
+// packFaces builds a mesh from the faces, keeping only the verticies
+// they use. Normals and texture coordinates keep their buffers, and
+// creases follow their verticies through the welding in remap
+func (p *PolygonMesh) packFaces(verticies []vec.Vec3, remap []int, faces [][]meshCorner) *PolygonMesh {
+	index := make([]int, len(verticies))
+	for i := range index {
+		index[i] = -1
+	}
+
+	poly := MakePolygonMesh()
+	poly.NumFaces[0] = len(faces)
+	hasNormals, hasTextures := false, false
+	for _, face := range faces {
+		poly.NumVerticies = append(poly.NumVerticies, len(face))
+		for _, c := range face {
+			if index[c.v] < 0 {
+				index[c.v] = len(poly.Verticies) / 3
+				v := verticies[c.v]
+				poly.Verticies = append(poly.Verticies, v.X, v.Y, v.Z)
+			}
+			poly.VertexIndecies = append(poly.VertexIndecies, index[c.v])
+			poly.NormalIndecies = append(poly.NormalIndecies, c.n)
+			poly.TextureIndecies = append(poly.TextureIndecies, c.t)
+			hasNormals = hasNormals || c.n >= 0
+			hasTextures = hasTextures || c.t >= 0
+		}
+	}
+
+	if hasNormals {
+		poly.VertexNormals = p.VertexNormals
+	} else {
+		poly.NormalIndecies = nil
+	}
+	if hasTextures {
+		poly.TextureVertecies = p.TextureVertecies
+	} else {
+		poly.TextureIndecies = nil
+	}
+
+	for _, c := range p.Creases {
+		if c.V0 < 0 || c.V1 < 0 || c.V0 >= len(index) || c.V1 >= len(index) {
+			continue
+		}
+		v0, v1 := index[remap[c.V0]], index[remap[c.V1]]
+		if v0 >= 0 && v1 >= 0 && v0 != v1 {
+			poly.Creases = append(poly.Creases, Crease{v0, v1, c.Sharpness})
+		}
+	}
+	return poly
+}
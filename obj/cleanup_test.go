@@ -0,0 +1,110 @@
+package obj
+
+import (
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// makeBrokenCube returns a cube with a separate copy of each corner for
+// every face, one face wound the wrong way, a face with no area, a face
+// with a vertex past the end of the buffer and a bad normal index
+func makeBrokenCube() *PolygonMesh {
+	cube := makeCubeMesh()
+	poly := MakePolygonMesh()
+	for i, v := range cube.VertexIndecies {
+		poly.Verticies = append(poly.Verticies, cube.Verticies[v*3]+1e-9, cube.Verticies[v*3+1], cube.Verticies[v*3+2])
+		poly.VertexIndecies = append(poly.VertexIndecies, i)
+		poly.NormalIndecies = append(poly.NormalIndecies, -1)
+	}
+	poly.NumVerticies = []int{4, 4, 4, 4, 4, 4}
+
+	// Turn the top face around
+	top := poly.VertexIndecies[12:16]
+	top[0], top[1], top[2], top[3] = top[3], top[2], top[1], top[0]
+
+	// A sliver along an edge of the cube and a face off the end
+	poly.Verticies = append(poly.Verticies, -1+1e-9, 0, -1)
+	poly.VertexIndecies = append(poly.VertexIndecies, 0, 24, 1, 0, 1, 99)
+	poly.NormalIndecies = append(poly.NormalIndecies, -1, -1, 5, -1, -1, -1)
+	poly.NumVerticies = append(poly.NumVerticies, 3, 3)
+	poly.NumFaces[0] = len(poly.NumVerticies)
+	return poly
+}
+
+func TestMeshClean(t *testing.T) {
+	t.Parallel()
+
+	broken := makeBrokenCube()
+	report := broken.Validate(1e-6)
+	if !report.Changed() {
+		t.Fatal("Broken mesh should need cleaning")
+	}
+
+	clean, report := broken.Clean(1e-6)
+	expected := MeshReport{
+		InvalidFaces:          1,
+		InvalidIndecies:       1,
+		InvalidNormalIndecies: 1,
+		WeldedVerticies:       16,
+		RemovedVerticies:      1,
+		DegenerateFaces:       1,
+		FlippedFaces:          1,
+		Faces:                 6,
+		Verticies:             8}
+	if report != expected {
+		t.Errorf("Report not correct\n%v", report)
+	}
+	if clean.NormalIndecies != nil {
+		t.Error("Mesh without valid normals should not keep normal indecies")
+	}
+
+	// Every face of the cleaned cube faces outward
+	verticies := clean.verticiesVec3()
+	start := 0
+	for _, n := range clean.NumVerticies {
+		face := make([]meshCorner, n)
+		center := *vec.NewVec3(0, 0, 0)
+		for k := range face {
+			face[k].v = clean.VertexIndecies[start+k]
+			center = vec.Add(center, verticies[face[k].v])
+		}
+		if vec.Dot(faceNormal(verticies, face), center) <= 0 {
+			t.Error("Face does not face outward", clean.VertexIndecies[start:start+n])
+		}
+		start += n
+	}
+
+	// The cleaned mesh converts without problems
+	if indecies := clean.Triangulate(); len(indecies) != 12*3 {
+		t.Error("Cleaned cube should have 12 triangles", len(indecies)/3)
+	}
+	if again := clean.Validate(1e-6); again.Changed() {
+		t.Errorf("Cleaned mesh should not need cleaning\n%v", again)
+	}
+}
+
+func TestMeshCleanOpenSurface(t *testing.T) {
+	t.Parallel()
+
+	// Two triangles of a square with inconsistent winding; the open
+	// surface keeps the winding of the first
+	poly := MakePolygonMesh()
+	poly.Verticies = []float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}
+	poly.VertexIndecies = []int{0, 1, 2, 0, 3, 2}
+	poly.NumVerticies = []int{3, 3}
+	poly.NumFaces[0] = 2
+
+	clean, report := poly.Clean(0)
+	if report.FlippedFaces != 1 || report.WeldedVerticies != 0 {
+		t.Errorf("Report not correct\n%v", report)
+	}
+	verticies := clean.verticiesVec3()
+	for f := 0; f < 2; f++ {
+		face := []meshCorner{{clean.VertexIndecies[f*3], -1, -1}, {clean.VertexIndecies[f*3+1], -1, -1}, {clean.VertexIndecies[f*3+2], -1, -1}}
+		if n := faceNormal(verticies, face); math.Abs(n.Z-1) > 1e-12 {
+			t.Error("Face should wind like the first one", n)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Cone is a closed cone whose base of Radius is centered on Base and whose
+// apex is Height along Axis. The base is capped
+type Cone struct {
+	ID              string
+	Base            vec.Vec3
+	Axis            vec.Vec3
+	Radius          float64
+	Height          float64
+	Col             color.RGBA
+	RefractiveIndex float64
+	frame           frame
+}
+
+// NewCone is a constructor for capped Cones
+func NewCone(id string, base, axis vec.Vec3, radius, height float64, col color.RGBA, refractive float64) *Cone {
+	c := new(Cone)
+	c.ID = id
+	c.Base = base
+	c.Axis = vec.Divide(axis, axis.Magnitude)
+	c.Radius = radius
+	c.Height = height
+	c.Col = col
+	c.RefractiveIndex = refractive
+	c.frame = newFrame(base, axis)
+	return c
+}
+
+// GetID is the object specific method to return the ID of the cone
+func (c *Cone) GetID() string {
+	return c.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (c *Cone) GetColor() color.RGBA {
+	return c.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (c *Cone) GetRefractiveIndex() float64 {
+	return c.RefractiveIndex
+}
+
+// Bounds returns the axis-aligned bounding box of the cone
+func (c *Cone) Bounds() AABB {
+	r := c.Radius
+	return c.frame.bounds(AABB{*vec.NewVec3(-r, -r, 0), *vec.NewVec3(r, r, c.Height)})
+}
+
+// localHits returns every intersection of the ray with the side and base
+func (c *Cone) localHits(ray *cam.Ray) []localHit {
+	hits := make([]localHit, 0, 3)
+	o, d := c.frame.localRay(ray)
+
+	// Side: x^2 + y^2 = k * (h - z)^2
+	k := (c.Radius / c.Height) * (c.Radius / c.Height)
+	hz := c.Height - o.Z
+	a := d.X*d.X + d.Y*d.Y - k*d.Z*d.Z
+	b := 2 * (d.X*o.X + d.Y*o.Y + k*hz*d.Z)
+	cc := o.X*o.X + o.Y*o.Y - k*hz*hz
+	if ok, t0, t1 := solveQuadratic(a, b, cc); ok {
+		for _, t := range []float64{t0, t1} {
+			p := vec.Add(o, vec.Multiply(d, t))
+			if p.Z < 0 || p.Z > c.Height {
+				continue
+			}
+
+			// Reproject onto the side
+			radius := c.Radius * (c.Height - p.Z) / c.Height
+			if dist := math.Sqrt(p.X*p.X + p.Y*p.Y); dist > 0 {
+				p = *vec.NewVec3(p.X*radius/dist, p.Y*radius/dist, p.Z)
+			}
+
+			n := *vec.NewVec3(p.X, p.Y, k*(c.Height-p.Z))
+			if n.Magnitude == 0 {
+				// Hit the apex exactly
+				n = *vec.NewVec3(0, 0, 1)
+			}
+			n = vec.Divide(n, n.Magnitude)
+			err := *vec.NewVec3(gamma(7)*math.Abs(p.X), gamma(7)*math.Abs(p.Y), gamma(7)*(math.Abs(p.Z)+math.Abs(o.Z)))
+			u := sphericalAngle(p.X, p.Y) / (2 * math.Pi)
+			hits = append(hits, localHit{t, p, n, *vec.NewVec3(-p.Y, p.X, 0), err, u, p.Z / c.Height})
+		}
+	}
+
+	if ok, h := capHit(c.frame, ray, 0, c.Radius, false); ok {
+		hits = append(hits, h)
+	}
+	return hits
+}
+
+// Intersects checks for intersections between a ray and the cone.
+// On the side U runs around the axis and V from the base to the apex;
+// on the base U runs around the axis and V out from the center
+func (c *Cone) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	found, h, far := nearestLocalHit(c.localHits(ray))
+	if !found {
+		return FalseObject()
+	}
+
+	dir := ray.Direction
+	dir.Normalize()
+	return c.frame.hitRecord(c, dir, h.p, h.n, h.dpdu, h.err, h.t, far, h.u, h.v), true
+}
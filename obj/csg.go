@@ -0,0 +1,248 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// maxIntervalHits limits how many surfaces are crossed when finding the
+// intervals of an object that does not implement Solid
+const maxIntervalHits = 64
+
+// Interval is a span of a ray that lies inside a solid. Enter and Exit
+// are the hits where the ray crosses into and out of it, with T0 set to
+// the distance along the ray. A ray that starts inside has an Enter with
+// T0 <= 0: behind its origin where the solid knows it, as for spheres, or
+// at negative infinity when it was only found by crossing the surface
+type Interval struct {
+	Enter HitRecord
+	Exit  HitRecord
+}
+
+// Solid is a closed object that can report every interval of a ray
+// inside it, in ascending order, including any the ray starts in
+type Solid interface {
+	Object
+	Intervals(*cam.Ray) []Interval
+}
+
+// SolidIntervals returns the intervals of the ray inside object. Objects
+// that do not implement Solid are treated as closed surfaces and crossed
+// one hit at a time, using FrontFace to tell entries from exits
+func SolidIntervals(object Object, ray *cam.Ray) []Interval {
+	if s, ok := object.(Solid); ok {
+		return s.Intervals(ray)
+	}
+
+	dir := ray.Direction
+	dir.Normalize()
+	next := *ray
+
+	intervals := make([]Interval, 0, 1)
+	var current Interval
+	inside := false
+	for i := 0; i < maxIntervalHits; i++ {
+		rec, isHit := object.Intersects(&next)
+		if !isHit {
+			break
+		}
+		rec.T0 = vec.Dot(vec.Subtract(rec.Point, ray.Origin), dir)
+		rec.T1 = rec.T0
+
+		if rec.FrontFace {
+			current = Interval{Enter: rec}
+			inside = true
+		} else {
+			if !inside {
+				// The ray started inside
+				current = Interval{Enter: HitRecord{T0: math.Inf(-1), T1: math.Inf(-1)}}
+			}
+			current.Exit = rec
+			intervals = append(intervals, current)
+			inside = false
+		}
+		next.Origin = OffsetRayOrigin(rec, dir)
+	}
+	return intervals
+}
+
+// Intervals returns the span of the ray inside the sphere
+func (s Sphere) Intervals(ray *cam.Ray) []Interval {
+	isHit, rd, t0, t1 := s.roots(ray)
+	if !isHit || t1 <= 0 {
+		return nil
+	}
+
+	enter := s.hitRecord(ray, rd, t0, t0)
+	exit := s.hitRecord(ray, rd, t1, t1)
+	return []Interval{{enter, exit}}
+}
+
+// CSGOperation is the boolean operation a CSG applies to its operands
+type CSGOperation int
+
+const (
+	// CSGUnion keeps everything inside either operand
+	CSGUnion CSGOperation = iota
+	// CSGIntersection keeps only what is inside both operands
+	CSGIntersection
+	// CSGDifference keeps what is inside Left but not Right
+	CSGDifference
+)
+
+// contains reports whether a point inside or outside of each operand is
+// inside the result
+func (op CSGOperation) contains(inLeft, inRight bool) bool {
+	switch op {
+	case CSGIntersection:
+		return inLeft && inRight
+	case CSGDifference:
+		return inLeft && !inRight
+	default:
+		return inLeft || inRight
+	}
+}
+
+// CSG combines two closed solids with a boolean operation. Each operand
+// may be any closed Object, including another CSG. Hits report the CSG as
+// their object so its color and refractive index apply to the whole solid
+type CSG struct {
+	ID              string
+	Operation       CSGOperation
+	Left            Object
+	Right           Object
+	Col             color.RGBA
+	RefractiveIndex float64
+}
+
+// NewCSG is a constructor for CSG solids
+func NewCSG(id string, op CSGOperation, left, right Object, col color.RGBA, refractive float64) *CSG {
+	c := new(CSG)
+	c.ID = id
+	c.Operation = op
+	c.Left = left
+	c.Right = right
+	c.Col = col
+	c.RefractiveIndex = refractive
+	return c
+}
+
+// GetID is the object specific method to return the ID of the CSG
+func (c *CSG) GetID() string {
+	return c.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (c *CSG) GetColor() color.RGBA {
+	return c.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (c *CSG) GetRefractiveIndex() float64 {
+	return c.RefractiveIndex
+}
+
+// Bounds returns the bounding box of the result, which never extends
+// beyond the operands
+func (c *CSG) Bounds() AABB {
+	left := c.Left.Bounds()
+	switch c.Operation {
+	case CSGDifference:
+		return left
+	case CSGIntersection:
+		right := c.Right.Bounds()
+		return AABB{
+			*vec.NewVec3(math.Max(left.Min.X, right.Min.X), math.Max(left.Min.Y, right.Min.Y), math.Max(left.Min.Z, right.Min.Z)),
+			*vec.NewVec3(math.Min(left.Max.X, right.Max.X), math.Min(left.Max.Y, right.Max.Y), math.Min(left.Max.Z, right.Max.Z))}
+	default:
+		return Union(left, c.Right.Bounds())
+	}
+}
+
+// csgEvent is a ray crossing the surface of one operand
+type csgEvent struct {
+	rec   HitRecord
+	left  bool
+	enter bool
+}
+
+// Intervals merges the intervals of both operands according to the
+// operation
+func (c *CSG) Intervals(ray *cam.Ray) []Interval {
+	events := make([]csgEvent, 0, 4)
+	for _, i := range SolidIntervals(c.Left, ray) {
+		events = append(events, csgEvent{i.Enter, true, true}, csgEvent{i.Exit, true, false})
+	}
+	for _, i := range SolidIntervals(c.Right, ray) {
+		events = append(events, csgEvent{i.Enter, false, true}, csgEvent{i.Exit, false, false})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].rec.T0 < events[j].rec.T0
+	})
+
+	intervals := make([]Interval, 0, 1)
+	var current Interval
+	inLeft, inRight := false, false
+	for _, e := range events {
+		before := c.Operation.contains(inLeft, inRight)
+		if e.left {
+			inLeft = e.enter
+		} else {
+			inRight = e.enter
+		}
+		after := c.Operation.contains(inLeft, inRight)
+		if before == after {
+			continue
+		}
+
+		rec := e.rec
+		if !e.left && c.Operation == CSGDifference {
+			// The inside of Right is the outside of the result
+			rec = flipHitRecord(rec)
+		}
+		if !math.IsInf(rec.T0, 0) {
+			rec.Object = c
+		}
+
+		if after {
+			current = Interval{Enter: rec}
+		} else {
+			current.Exit = rec
+			intervals = append(intervals, current)
+		}
+	}
+	return intervals
+}
+
+// flipHitRecord turns a hit around so its normals face the other way
+func flipHitRecord(rec HitRecord) HitRecord {
+	rec.Normal = vec.Invert(rec.Normal)
+	rec.GeometricNormal = vec.Invert(rec.GeometricNormal)
+	rec.Bitangent = vec.Invert(rec.Bitangent)
+	rec.FrontFace = !rec.FrontFace
+	return rec
+}
+
+// Intersects returns the first boundary of the result in front of the
+// ray. T1 is where the ray leaves the interval it hit
+func (c *CSG) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	for _, i := range c.Intervals(ray) {
+		if i.Enter.T0 > 0 {
+			rec := i.Enter
+			rec.T1 = i.Exit.T0
+			return rec, true
+		}
+		if i.Exit.T0 > 0 {
+			rec := i.Exit
+			rec.T1 = rec.T0
+			return rec, true
+		}
+	}
+	return FalseObject()
+}
@@ -0,0 +1,116 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestCSGDifference(t *testing.T) {
+	t.Parallel()
+
+	a := Sphere{"a", *vec.NewVec3(0, 0, -5), 1, color.RGBA{255, 0, 0, 1}, 1}
+	b := Sphere{"b", *vec.NewVec3(0, 0, -4), 0.5, color.RGBA{0, 255, 0, 1}, 1}
+	bitten := NewCSG("bitten", CSGDifference, a, b, color.RGBA{0, 0, 255, 1}, 1)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := bitten.Intersects(ray)
+	if !is_hit {
+		t.Fatal("CSG was not hit")
+	}
+	if math.Abs(rec.T0-4.5) > 1e-9 || math.Abs(rec.T1-6) > 1e-9 {
+		t.Error("Hit distances not correct", rec.T0, rec.T1)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) || !rec.FrontFace {
+		t.Error("Carved surface should face out of the result", rec.Normal)
+	}
+	if rec.Object != bitten {
+		t.Error("Hit should report the CSG as its object")
+	}
+
+	// Beside the bite the ray hits the original sphere
+	side := cam.NewRay(0, "camera", vec.NewVec3(0.8, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit = bitten.Intersects(side)
+	if !is_hit || math.Abs(rec.T0-(5-0.6)) > 1e-9 {
+		t.Error("Uncut part of the sphere not hit correctly", rec.T0)
+	}
+}
+
+func TestCSGUnionAndIntersection(t *testing.T) {
+	t.Parallel()
+
+	a := Sphere{"a", *vec.NewVec3(0, 0, -5), 1, color.RGBA{255, 0, 0, 1}, 1}
+	b := Sphere{"b", *vec.NewVec3(0, 0, -6), 1, color.RGBA{0, 255, 0, 1}, 1}
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+
+	union := NewCSG("union", CSGUnion, a, b, color.RGBA{0, 0, 255, 1}, 1)
+	intervals := union.Intervals(ray)
+	if len(intervals) != 1 || math.Abs(intervals[0].Enter.T0-4) > 1e-9 || math.Abs(intervals[0].Exit.T0-7) > 1e-9 {
+		t.Error("Union intervals not correct", intervals)
+	}
+
+	lens := NewCSG("lens", CSGIntersection, a, b, color.RGBA{0, 0, 255, 1}, 1)
+	intervals = lens.Intervals(ray)
+	if len(intervals) != 1 || math.Abs(intervals[0].Enter.T0-5) > 1e-9 || math.Abs(intervals[0].Exit.T0-6) > 1e-9 {
+		t.Error("Intersection intervals not correct", intervals)
+	}
+
+	// Starting inside the lens the ray leaves through the back
+	inside := cam.NewRay(0, "camera", vec.NewVec3(0, 0, -5.5), vec.NewVec3(0, 0, -1))
+	rec, is_hit := lens.Intersects(inside)
+	if !is_hit || math.Abs(rec.T0-0.5) > 1e-9 || rec.FrontFace {
+		t.Error("Ray from inside should leave through the back of the lens", rec.T0)
+	}
+}
+
+func TestCSGDrilledBox(t *testing.T) {
+	t.Parallel()
+
+	box := NewBox("box", *vec.NewVec3(-1, -1, -6), *vec.NewVec3(1, 1, -4), color.RGBA{255, 0, 0, 1}, 1)
+	drill := NewCylinder("drill", *vec.NewVec3(0, -2, -5), *vec.NewVec3(0, 1, 0), 0.25, 4, color.RGBA{0, 255, 0, 1}, 1)
+	drilled := NewCSG("drilled", CSGDifference, box, drill, color.RGBA{0, 0, 255, 1}, 1)
+
+	// Straight down the hole
+	down := cam.NewRay(0, "camera", vec.NewVec3(0, 5, -5), vec.NewVec3(0, -1, 0))
+	if _, is_hit := drilled.Intersects(down); is_hit {
+		t.Error("Ray down the hole should not hit")
+	}
+
+	// Across the hole the ray passes through two pieces of the box
+	across := cam.NewRay(0, "camera", vec.NewVec3(5, 0, -5), vec.NewVec3(-1, 0, 0))
+	intervals := drilled.Intervals(across)
+	if len(intervals) != 2 {
+		t.Fatal("Expected two intervals, got", len(intervals))
+	}
+	expected := [][2]float64{{4, 4.75}, {5.25, 6}}
+	for k, i := range intervals {
+		if math.Abs(i.Enter.T0-expected[k][0]) > 1e-6 || math.Abs(i.Exit.T0-expected[k][1]) > 1e-6 {
+			t.Error("Interval not correct", i.Enter.T0, i.Exit.T0)
+		}
+	}
+	if !vec.IsEqual(intervals[0].Exit.Normal, *vec.NewVec3(-1, 0, 0)) {
+		t.Error("Wall of the hole should face into it", intervals[0].Exit.Normal)
+	}
+}
+
+func TestIntervalsFromInside(t *testing.T) {
+	t.Parallel()
+
+	// The sphere knows where the ray would have entered; a surface only
+	// crossed on the way out does not
+	s := Sphere{"s", *vec.NewVec3(0, 0, 0), 1, color.RGBA{255, 0, 0, 1}, 1}
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	intervals := s.Intervals(ray)
+	if len(intervals) != 1 || intervals[0].Enter.T0 != -1 || intervals[0].Exit.T0 != 1 {
+		t.Error("Sphere should enter behind the ray origin", intervals)
+	}
+
+	surface := struct{ Object }{s}
+	intervals = SolidIntervals(surface, ray)
+	if len(intervals) != 1 || !math.IsInf(intervals[0].Enter.T0, -1) || math.Abs(intervals[0].Exit.T0-1) > 1e-9 {
+		t.Error("Crossed surface should enter at negative infinity", intervals)
+	}
+}
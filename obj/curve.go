@@ -0,0 +1,324 @@
+package obj
+
+import (
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// CurveType is the cross section a curve is drawn with
+type CurveType int
+
+const (
+	// CurveRibbon is a flat strip that always faces the ray, which is
+	// cheap and suits thin hair and fur
+	CurveRibbon CurveType = iota
+	// CurveTube is a round tube with rounded ends, for cables and wires
+	CurveTube
+)
+
+// maxCurveSegments limits how finely a cubic curve is split into
+// straight segments
+const maxCurveSegments = 64
+
+// curveSegment is a straight piece of a curve between two points with
+// the radius and curve parameter at each end
+type curveSegment struct {
+	p0, p1 vec.Vec3
+	r0, r1 float64
+	u0, u1 float64
+	box    AABB
+}
+
+// Curve is a linear or cubic Bezier curve swept with a width that varies
+// linearly from Widths[0] at the start to Widths[1] at the end. Cubic
+// curves are split into straight segments finely enough that the error is
+// a small fraction of the width. Curves are separate objects so that many
+// of them can share a BVH with other geometry
+type Curve struct {
+	ID              string
+	Control         []vec.Vec3 // 2 points for a line, 4 for a cubic Bezier
+	Widths          [2]float64
+	Type            CurveType
+	Col             color.RGBA
+	RefractiveIndex float64
+	segments        []curveSegment
+	box             AABB
+}
+
+// NewCurve is a constructor for Curve objects. It returns an error if
+// there are not 2 or 4 control points
+func NewCurve(id string, control []vec.Vec3, widths [2]float64, curveType CurveType, col color.RGBA, refractive float64) (*Curve, error) {
+	if len(control) != 2 && len(control) != 4 {
+		return nil, errors.New("curves need 2 or 4 control points")
+	}
+
+	c := new(Curve)
+	c.ID = id
+	c.Control = control
+	c.Widths = widths
+	c.Type = curveType
+	c.Col = col
+	c.RefractiveIndex = refractive
+
+	n := 1
+	if len(control) == 4 {
+		n = curveSegments(control, 0.05*math.Max(widths[0], widths[1])/2)
+	}
+
+	c.box = EmptyAABB()
+	points := make([]vec.Vec3, n+1)
+	for i := range points {
+		points[i] = c.Evaluate(float64(i) / float64(n))
+	}
+	for i := 0; i < n; i++ {
+		s := curveSegment{p0: points[i], p1: points[i+1]}
+		s.u0 = float64(i) / float64(n)
+		s.u1 = float64(i+1) / float64(n)
+		s.r0 = c.Width(s.u0) / 2
+		s.r1 = c.Width(s.u1) / 2
+
+		r := math.Max(s.r0, s.r1)
+		pad := *vec.NewVec3(r, r, r)
+		s.box = NewAABB(vec.Subtract(s.p0, pad), vec.Add(s.p0, pad))
+		s.box = Union(s.box, NewAABB(vec.Subtract(s.p1, pad), vec.Add(s.p1, pad)))
+		c.box = Union(c.box, s.box)
+		c.segments = append(c.segments, s)
+	}
+	return c, nil
+}
+
+// curveSegments returns how many straight segments keep a cubic Bezier
+// within tolerance. A chord over a span h of the parameter is off by at
+// most h^2 / 8 times the second derivative, which is at most 6 times the
+// largest second difference of the control points
+func curveSegments(control []vec.Vec3, tolerance float64) int {
+	second := 0.0
+	for i := 0; i < 2; i++ {
+		d := vec.Add(vec.Subtract(control[i], vec.Multiply(control[i+1], 2)), control[i+2])
+		second = math.Max(second, d.Magnitude)
+	}
+	if tolerance <= 0 || second == 0 {
+		return 1
+	}
+	n := math.Ceil(math.Sqrt(6 * second / (8 * tolerance)))
+	return int(math.Max(1, math.Min(maxCurveSegments, n)))
+}
+
+// GetID is the object specific method to return the ID of the curve
+func (c *Curve) GetID() string {
+	return c.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (c *Curve) GetColor() color.RGBA {
+	return c.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (c *Curve) GetRefractiveIndex() float64 {
+	return c.RefractiveIndex
+}
+
+// Bounds returns the box around every segment of the curve
+func (c *Curve) Bounds() AABB {
+	return c.box
+}
+
+// Evaluate returns the point on the center line of the curve at u
+func (c *Curve) Evaluate(u float64) vec.Vec3 {
+	if len(c.Control) == 2 {
+		return lerpVec3(c.Control[0], c.Control[1], u)
+	}
+	b := bernstein(u)
+	p := vec.Multiply(c.Control[0], b[0])
+	for i := 1; i < 4; i++ {
+		p = vec.Add(p, vec.Multiply(c.Control[i], b[i]))
+	}
+	return p
+}
+
+// Width returns the width of the curve at u
+func (c *Curve) Width(u float64) float64 {
+	return c.Widths[0] + (c.Widths[1]-c.Widths[0])*u
+}
+
+// Intersects checks for intersections between a ray and the segments of
+// the curve. U runs along the curve. V runs across a ribbon, or around a
+// tube starting from an arbitrary direction
+func (c *Curve) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	dir := ray.Direction
+	dir.Normalize()
+	invDir := *vec.NewVec3(1/dir.X, 1/dir.Y, 1/dir.Z)
+
+	if isHit, _, _ := c.box.IntersectsRay(ray.Origin, invDir, math.Inf(1)); !isHit {
+		return FalseObject()
+	}
+
+	hits := make([]localHit, 0, 4)
+	for i := range c.segments {
+		s := &c.segments[i]
+		if isHit, _, _ := s.box.IntersectsRay(ray.Origin, invDir, math.Inf(1)); !isHit {
+			continue
+		}
+		if c.Type == CurveRibbon {
+			hits = append(hits, s.ribbonHits(ray.Origin, dir)...)
+		} else {
+			hits = append(hits, s.tubeHits(ray.Origin, dir)...)
+		}
+	}
+
+	found, h, far := nearestLocalHit(hits)
+	if !found {
+		return FalseObject()
+	}
+	if c.Type == CurveRibbon {
+		// Ribbons have no inside
+		far = h.t
+	}
+
+	rec := newHitRecord(c, dir, h.p, h.n, h.t, far)
+	rec.U = h.u
+	rec.V = h.v
+	rec.Tangent, rec.Bitangent = alignTangentFrame(h.n, h.dpdu)
+	rec.Error = h.err
+	return rec, true
+}
+
+// axis returns the unit direction and length of the segment
+func (s *curveSegment) axis() (vec.Vec3, float64) {
+	a := vec.Subtract(s.p1, s.p0)
+	if a.Magnitude == 0 {
+		return a, 0
+	}
+	return vec.Divide(a, a.Magnitude), a.Magnitude
+}
+
+// ribbonHits intersects the segment as a flat strip turned to face the
+// ray. The strip lies in the plane through the axis that is perpendicular
+// to the ray, so the ray keeps a constant distance from the axis across
+// it and that distance decides whether the strip is wide enough to hit
+func (s *curveSegment) ribbonHits(org, dir vec.Vec3) []localHit {
+	a, length := s.axis()
+	if length == 0 {
+		return nil
+	}
+	across := vec.Cross(a, dir)
+	if across.Magnitude < 1e-12 {
+		// Looking straight down the segment
+		return nil
+	}
+	across = vec.Divide(across, across.Magnitude)
+	n := vec.Cross(a, across)
+
+	dn := vec.Dot(dir, n)
+	if dn == 0 {
+		return nil
+	}
+	t := vec.Dot(vec.Subtract(s.p0, org), n) / dn
+	q := vec.Subtract(vec.Add(org, vec.Multiply(dir, t)), s.p0)
+	along := vec.Dot(q, a) / length
+	if along < 0 || along > 1 {
+		return nil
+	}
+	offset := vec.Dot(q, across)
+	r := s.r0 + (s.r1-s.r0)*along
+	if r <= 0 || math.Abs(offset) > r {
+		return nil
+	}
+
+	// Rebuild the point on the strip and face it back at the ray
+	p := vec.Add(s.p0, vec.Add(vec.Multiply(a, along*length), vec.Multiply(across, offset)))
+	if dn > 0 {
+		n = vec.Invert(n)
+	}
+	u := s.u0 + (s.u1-s.u0)*along
+	v := 0.5 + offset/(2*r)
+	return []localHit{{t, p, n, a, localError(p, 7), u, v}}
+}
+
+// tubeHits intersects the segment as a tapered tube with a sphere at
+// each end. The spheres round off the ends of the curve and fill the gaps
+// where segments meet at an angle
+func (s *curveSegment) tubeHits(org, dir vec.Vec3) []localHit {
+	a, length := s.axis()
+	if length == 0 {
+		// A point still has its end spheres, around any axis
+		a = *vec.NewVec3(0, 0, 1)
+	}
+	ref, _ := tangentFrame(a)
+
+	// angle returns V for a radial direction around the axis
+	angle := func(radial vec.Vec3) float64 {
+		x := vec.Dot(radial, ref)
+		y := vec.Dot(radial, vec.Cross(a, ref))
+		return sphericalAngle(x, y) / (2 * math.Pi)
+	}
+
+	hits := make([]localHit, 0, 6)
+	for _, end := range [2]struct {
+		center vec.Vec3
+		r, u   float64
+	}{{s.p0, s.r0, s.u0}, {s.p1, s.r1, s.u1}} {
+		if end.r <= 0 {
+			continue
+		}
+		m := vec.Subtract(org, end.center)
+		ok, t0, t1 := solveQuadratic(1, 2*vec.Dot(m, dir), vec.Dot(m, m)-end.r*end.r)
+		if !ok {
+			continue
+		}
+		for _, t := range []float64{t0, t1} {
+			radial := vec.Add(m, vec.Multiply(dir, t))
+			if radial.Magnitude == 0 {
+				continue
+			}
+			n := vec.Divide(radial, radial.Magnitude)
+			p := vec.Add(end.center, vec.Multiply(n, end.r))
+			hits = append(hits, localHit{t, p, n, a, localError(p, 5), end.u, angle(n)})
+		}
+	}
+	if length == 0 {
+		return hits
+	}
+
+	// Side: the distance from the axis equals r0 + k*s at distance s
+	// along it
+	k := (s.r1 - s.r0) / length
+	m := vec.Subtract(org, s.p0)
+	sm := vec.Dot(m, a)
+	sd := vec.Dot(dir, a)
+	rm := s.r0 + k*sm
+	qa := 1 - sd*sd - k*k*sd*sd
+	qb := 2 * (vec.Dot(m, dir) - sm*sd - k*sd*rm)
+	qc := vec.Dot(m, m) - sm*sm - rm*rm
+	if ok, t0, t1 := solveQuadratic(qa, qb, qc); ok && qa != 0 {
+		for _, t := range []float64{t0, t1} {
+			q := vec.Add(m, vec.Multiply(dir, t))
+			along := vec.Dot(q, a)
+			r := s.r0 + k*along
+			if along < 0 || along > length || r <= 0 {
+				continue
+			}
+			radial := vec.Subtract(q, vec.Multiply(a, along))
+			if radial.Magnitude == 0 {
+				continue
+			}
+			radial = vec.Divide(radial, radial.Magnitude)
+
+			// Reproject onto the side, whose normal leans back along the
+			// axis as the tube narrows
+			p := vec.Add(s.p0, vec.Add(vec.Multiply(a, along), vec.Multiply(radial, r)))
+			n := vec.Subtract(radial, vec.Multiply(a, k))
+			n = vec.Divide(n, n.Magnitude)
+			u := s.u0 + (s.u1-s.u0)*along/length
+			hits = append(hits, localHit{t, p, n, a, localError(p, 7), u, angle(radial)})
+		}
+	}
+	return hits
+}
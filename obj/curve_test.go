@@ -0,0 +1,111 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestCurveTube(t *testing.T) {
+	t.Parallel()
+
+	line := []vec.Vec3{*vec.NewVec3(-1, 0, -5), *vec.NewVec3(1, 0, -5)}
+	tube, err := NewCurve("tube", line, [2]float64{1, 1}, CurveTube, color.RGBA{255, 0, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := tube.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-4.5) > 1e-9 || math.Abs(rec.T1-5.5) > 1e-9 {
+		t.Fatal("Tube not hit correctly", rec.T0, rec.T1)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) || math.Abs(rec.U-0.5) > 1e-9 {
+		t.Error("Tube hit not correct", rec.Normal, rec.U)
+	}
+
+	// Past the end the rounded cap is hit
+	end := cam.NewRay(0, "camera", vec.NewVec3(1.3, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit = tube.Intersects(end)
+	if !is_hit || math.Abs(rec.T0-4.6) > 1e-9 || rec.U != 1 {
+		t.Error("End of the tube not hit correctly", rec.T0, rec.U)
+	}
+
+	// A tapering tube leans its normal toward the narrow end
+	cone, _ := NewCurve("cone", line, [2]float64{1, 0}, CurveTube, color.RGBA{255, 0, 0, 1}, 1)
+	down := cam.NewRay(0, "camera", vec.NewVec3(0, 5, -5), vec.NewVec3(0, -1, 0))
+	rec, is_hit = cone.Intersects(down)
+	if !is_hit || math.Abs(rec.T0-4.75) > 1e-9 {
+		t.Fatal("Tapered tube not hit correctly", rec.T0)
+	}
+	expected := vec.Divide(*vec.NewVec3(0.25, 1, 0), math.Sqrt(1.0625))
+	if !vec.IsEqual(rec.Normal, expected) {
+		t.Error("Tapered tube normal not correct", rec.Normal)
+	}
+
+	if _, err := NewCurve("bad", line[:1], [2]float64{1, 1}, CurveTube, color.RGBA{255, 0, 0, 1}, 1); err == nil {
+		t.Error("Curve with one control point should fail")
+	}
+}
+
+func TestCurveRibbon(t *testing.T) {
+	t.Parallel()
+
+	line := []vec.Vec3{*vec.NewVec3(-1, 0, -5), *vec.NewVec3(1, 0, -5)}
+	ribbon, _ := NewCurve("ribbon", line, [2]float64{0.1, 0.1}, CurveRibbon, color.RGBA{255, 0, 0, 1}, 1)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0.01, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := ribbon.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-5) > 1e-9 || rec.T1 != rec.T0 {
+		t.Fatal("Ribbon not hit correctly", rec.T0, rec.T1)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) || !rec.FrontFace {
+		t.Error("Ribbon should face the ray", rec.Normal)
+	}
+	if math.Abs(rec.V-0.6) > 1e-9 {
+		t.Error("V across the ribbon not correct", rec.V)
+	}
+
+	// Seen from below the ribbon still faces the ray
+	below := cam.NewRay(0, "camera", vec.NewVec3(0, -5, -5), vec.NewVec3(0, 1, 0))
+	rec, is_hit = ribbon.Intersects(below)
+	if !is_hit || !vec.IsEqual(rec.Normal, *vec.NewVec3(0, -1, 0)) {
+		t.Error("Ribbon should turn to face the ray", rec.Normal)
+	}
+
+	wide := cam.NewRay(0, "camera", vec.NewVec3(0, 0.1, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := ribbon.Intersects(wide); is_hit {
+		t.Error("Ray beside the ribbon should miss")
+	}
+}
+
+func TestCurveCubic(t *testing.T) {
+	t.Parallel()
+
+	arc := []vec.Vec3{
+		*vec.NewVec3(-1, 0, -5),
+		*vec.NewVec3(-0.5, 1, -5),
+		*vec.NewVec3(0.5, 1, -5),
+		*vec.NewVec3(1, 0, -5)}
+	curve, _ := NewCurve("arc", arc, [2]float64{0.2, 0.2}, CurveTube, color.RGBA{255, 0, 0, 1}, 1)
+	if len(curve.segments) < 2 {
+		t.Error("Cubic curve should be split into segments", len(curve.segments))
+	}
+
+	// Over the top of the arc the tube is hit within the tolerance
+	top := curve.Evaluate(0.5)
+	ray := cam.NewRay(0, "camera", vec.NewVec3(top.X, top.Y, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := curve.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-4.9) > 0.05*0.1 {
+		t.Error("Cubic tube not hit correctly", rec.T0)
+	}
+
+	// The segments stay within the tolerance of the top at y = 0.75
+	b := curve.Bounds()
+	if b.Max.Y < 0.75+0.1-0.005 || b.Min.X > -1.1 {
+		t.Error("Bounds do not enclose the curve", b)
+	}
+}
@@ -0,0 +1,158 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// localHit is a candidate intersection with one part of a primitive, in
+// the primitive's frame
+type localHit struct {
+	t    float64
+	p    vec.Vec3
+	n    vec.Vec3
+	dpdu vec.Vec3
+	err  vec.Vec3
+	u    float64
+	v    float64
+}
+
+// nearestLocalHit returns the closest candidate in front of the ray and
+// the distance to the furthest one, which is where the ray leaves a
+// closed solid
+func nearestLocalHit(hits []localHit) (bool, localHit, float64) {
+	found := false
+	var nearest localHit
+	far := 0.0
+	for _, h := range hits {
+		if h.t <= 0 {
+			continue
+		}
+		if !found || h.t < nearest.t {
+			nearest = h
+			found = true
+		}
+		far = math.Max(far, h.t)
+	}
+	return found, nearest, far
+}
+
+// capHit is a candidate intersection with a flat cap at z = height,
+// facing up or down the axis
+func capHit(f frame, ray *cam.Ray, height, radius float64, up bool) (bool, localHit) {
+	isHit, p, t := intersectDisk(f, ray, height, radius, 0)
+	if !isHit {
+		return false, localHit{}
+	}
+
+	n := *vec.NewVec3(0, 0, 1)
+	if !up {
+		n = *vec.NewVec3(0, 0, -1)
+	}
+	u := sphericalAngle(p.X, p.Y) / (2 * math.Pi)
+	v := math.Sqrt(p.X*p.X+p.Y*p.Y) / radius
+	return true, localHit{t, p, n, *vec.NewVec3(-p.Y, p.X, 0), localError(p, 3), u, v}
+}
+
+// Cylinder is a closed cylinder of Radius whose base is centered on Base
+// and which extends Height along Axis. Both ends are capped
+type Cylinder struct {
+	ID              string
+	Base            vec.Vec3
+	Axis            vec.Vec3
+	Radius          float64
+	Height          float64
+	Col             color.RGBA
+	RefractiveIndex float64
+	frame           frame
+}
+
+// NewCylinder is a constructor for capped Cylinders
+func NewCylinder(id string, base, axis vec.Vec3, radius, height float64, col color.RGBA, refractive float64) *Cylinder {
+	c := new(Cylinder)
+	c.ID = id
+	c.Base = base
+	c.Axis = vec.Divide(axis, axis.Magnitude)
+	c.Radius = radius
+	c.Height = height
+	c.Col = col
+	c.RefractiveIndex = refractive
+	c.frame = newFrame(base, axis)
+	return c
+}
+
+// GetID is the object specific method to return the ID of the cylinder
+func (c *Cylinder) GetID() string {
+	return c.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (c *Cylinder) GetColor() color.RGBA {
+	return c.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (c *Cylinder) GetRefractiveIndex() float64 {
+	return c.RefractiveIndex
+}
+
+// Bounds returns the axis-aligned bounding box of the cylinder
+func (c *Cylinder) Bounds() AABB {
+	r := c.Radius
+	return c.frame.bounds(AABB{*vec.NewVec3(-r, -r, 0), *vec.NewVec3(r, r, c.Height)})
+}
+
+// localHits returns every intersection of the ray with the side and caps
+func (c *Cylinder) localHits(ray *cam.Ray) []localHit {
+	hits := make([]localHit, 0, 4)
+	o, d := c.frame.localRay(ray)
+
+	// Side: x^2 + y^2 = r^2
+	a := d.X*d.X + d.Y*d.Y
+	b := 2 * (d.X*o.X + d.Y*o.Y)
+	cc := o.X*o.X + o.Y*o.Y - c.Radius*c.Radius
+	if ok, t0, t1 := solveQuadratic(a, b, cc); ok && a != 0 {
+		for _, t := range []float64{t0, t1} {
+			p := vec.Add(o, vec.Multiply(d, t))
+			if p.Z < 0 || p.Z > c.Height {
+				continue
+			}
+
+			// Reproject onto the side
+			scale := c.Radius / math.Sqrt(p.X*p.X+p.Y*p.Y)
+			p = *vec.NewVec3(p.X*scale, p.Y*scale, p.Z)
+
+			n := *vec.NewVec3(p.X/c.Radius, p.Y/c.Radius, 0)
+			err := *vec.NewVec3(gamma(3)*math.Abs(p.X), gamma(3)*math.Abs(p.Y), gamma(7)*math.Abs(p.Z)+gamma(7)*math.Abs(o.Z))
+			u := sphericalAngle(p.X, p.Y) / (2 * math.Pi)
+			hits = append(hits, localHit{t, p, n, *vec.NewVec3(-p.Y, p.X, 0), err, u, p.Z / c.Height})
+		}
+	}
+
+	if ok, h := capHit(c.frame, ray, 0, c.Radius, false); ok {
+		hits = append(hits, h)
+	}
+	if ok, h := capHit(c.frame, ray, c.Height, c.Radius, true); ok {
+		hits = append(hits, h)
+	}
+	return hits
+}
+
+// Intersects checks for intersections between a ray and the cylinder.
+// On the side U runs around the axis and V up it; on the caps U runs
+// around the axis and V out from the center
+func (c *Cylinder) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	found, h, far := nearestLocalHit(c.localHits(ray))
+	if !found {
+		return FalseObject()
+	}
+
+	dir := ray.Direction
+	dir.Normalize()
+	return c.frame.hitRecord(c, dir, h.p, h.n, h.dpdu, h.err, h.t, far, h.u, h.v), true
+}
@@ -0,0 +1,110 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Disk is a flat circle of Radius around Center facing Normal. A non-zero
+// InnerRadius cuts a hole in the middle, making it an annulus
+type Disk struct {
+	ID              string
+	Center          vec.Vec3
+	Normal          vec.Vec3
+	Radius          float64
+	InnerRadius     float64
+	Col             color.RGBA
+	RefractiveIndex float64
+	frame           frame
+}
+
+// NewDisk is a constructor for Disks
+func NewDisk(id string, center, normal vec.Vec3, radius, innerRadius float64, col color.RGBA, refractive float64) *Disk {
+	d := new(Disk)
+	d.ID = id
+	d.Center = center
+	d.Normal = vec.Divide(normal, normal.Magnitude)
+	d.Radius = radius
+	d.InnerRadius = innerRadius
+	d.Col = col
+	d.RefractiveIndex = refractive
+	d.frame = newFrame(center, normal)
+	return d
+}
+
+// GetID is the object specific method to return the ID of the disk
+func (d *Disk) GetID() string {
+	return d.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (d *Disk) GetColor() color.RGBA {
+	return d.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (d *Disk) GetRefractiveIndex() float64 {
+	return d.RefractiveIndex
+}
+
+// Bounds returns the axis-aligned bounding box of the disk
+func (d *Disk) Bounds() AABB {
+	r := d.Radius
+	return d.frame.bounds(AABB{*vec.NewVec3(-r, -r, 0), *vec.NewVec3(r, r, 0)})
+}
+
+// Intersects checks for intersections between a ray and the disk.
+// U runs around the disk and V from the outer to the inner edge
+func (d *Disk) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	isHit, p, t0 := intersectDisk(d.frame, ray, 0, d.Radius, d.InnerRadius)
+	if !isHit {
+		return FalseObject()
+	}
+
+	dir := ray.Direction
+	dir.Normalize()
+
+	phi := sphericalAngle(p.X, p.Y)
+	dist := math.Sqrt(p.X*p.X + p.Y*p.Y)
+	u := phi / (2 * math.Pi)
+	v := 0.0
+	if width := d.Radius - d.InnerRadius; width > 0 {
+		// A ring with no width is only hit on its edge, at V = 0
+		v = (d.Radius - dist) / width
+	}
+	dpdu := *vec.NewVec3(-p.Y, p.X, 0)
+
+	rec := d.frame.hitRecord(d, dir, p, *vec.NewVec3(0, 0, 1), dpdu, localError(p, 3), t0, t0, u, v)
+	return rec, true
+}
+
+// intersectDisk intersects a ray with a disk in the plane z = height of
+// the frame, between radius and innerRadius. It is shared by Disk and the
+// caps of Cylinder and Cone. It returns the local hit point and distance
+func intersectDisk(f frame, ray *cam.Ray, height, radius, innerRadius float64) (bool, vec.Vec3, float64) {
+	o, d := f.localRay(ray)
+	if d.Z == 0 {
+		// Ray is parallel to the disk
+		return false, vec.Vec3{}, 0
+	}
+
+	t0 := (height - o.Z) / d.Z
+	if t0 <= 0 {
+		return false, vec.Vec3{}, 0
+	}
+
+	x := o.X + t0*d.X
+	y := o.Y + t0*d.Y
+	dist2 := x*x + y*y
+	if dist2 > radius*radius || dist2 < innerRadius*innerRadius {
+		return false, vec.Vec3{}, 0
+	}
+
+	// z is exact on the disk
+	return true, *vec.NewVec3(x, y, height), t0
+}
@@ -0,0 +1,327 @@
+package obj
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// DisplacementFunc returns how far to move the surface point p, with
+// texture coordinates (u, v), along its normal
+type DisplacementFunc func(p vec.Vec3, u, v float64) float64
+
+// HeightMap is a grayscale image used as a scalar height texture. Heights
+// run from 0 for black to 1 for white
+type HeightMap struct {
+	Width   int
+	Height  int
+	Heights []float64 // row major, top row first
+}
+
+// NewHeightMap converts an image into a height map by its luminance
+func NewHeightMap(img image.Image) *HeightMap {
+	b := img.Bounds()
+	h := new(HeightMap)
+	h.Width = b.Dx()
+	h.Height = b.Dy()
+	h.Heights = make([]float64, h.Width*h.Height)
+	for y := 0; y < h.Height; y++ {
+		for x := 0; x < h.Width; x++ {
+			gray := color.Gray16Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16)
+			h.Heights[y*h.Width+x] = float64(gray.Y) / 0xffff
+		}
+	}
+	return h
+}
+
+// At returns the height of pixel (x, y), wrapping around the edges
+func (h *HeightMap) At(x, y int) float64 {
+	x = ((x % h.Width) + h.Width) % h.Width
+	y = ((y % h.Height) + h.Height) % h.Height
+	return h.Heights[y*h.Width+x]
+}
+
+// Sample returns the bilinearly filtered height at texture coordinates
+// (u, v). The texture repeats outside of 0 to 1, and v runs up the image
+// as in OBJ files
+func (h *HeightMap) Sample(u, v float64) float64 {
+	if h.Width == 0 || h.Height == 0 {
+		return 0
+	}
+	x := u*float64(h.Width) - 0.5
+	y := (1-v)*float64(h.Height) - 0.5
+	x0 := math.Floor(x)
+	y0 := math.Floor(y)
+	fx := x - x0
+	fy := y - y0
+	ix := int(x0)
+	iy := int(y0)
+
+	top := h.At(ix, iy)*(1-fx) + h.At(ix+1, iy)*fx
+	bottom := h.At(ix, iy+1)*(1-fx) + h.At(ix+1, iy+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// Displacement returns a DisplacementFunc that looks up the height map by
+// texture coordinates and scales it
+func (h *HeightMap) Displacement(scale float64) DisplacementFunc {
+	return func(p vec.Vec3, u, v float64) float64 {
+		return h.Sample(u, v) * scale
+	}
+}
+
+// Displace tessellates the mesh until no edge is longer than edgeLength,
+// moves every vertex along its normal by displace and recomputes the
+// normals. Normals come from the mesh's vertex normals if it has them and
+// are computed otherwise. Triangles are only split as far as their own
+// edges need, and an edge is split at the same midpoint in both triangles
+// that share it, so the surface stays closed. Tessellation stops before a
+// pass would make more than maxDisplaceTriangles triangles, which leaves
+// edges longer than edgeLength when it is too small for the mesh
+func (p *PolygonMesh) Displace(edgeLength float64, displace DisplacementFunc) *PolygonMesh {
+	indecies := p.Triangulate()
+	verticies := p.verticiesVec3()
+
+	// Per-vertex normals, one for each position
+	normals := make([]vec.Vec3, len(verticies))
+	if normalIndecies := p.TriangulateNormals(); normalIndecies != nil {
+		for c, v := range indecies {
+			n := normalIndecies[c]
+			normals[v] = *vec.NewVec3(p.VertexNormals[n*3], p.VertexNormals[n*3+1], p.VertexNormals[n*3+2])
+		}
+	} else {
+		flat := AngleWeightedNormals(p.Verticies, indecies)
+		for v := range normals {
+			normals[v] = *vec.NewVec3(flat[v*3], flat[v*3+1], flat[v*3+2])
+		}
+	}
+
+	// Texture coordinates are indexed separately so seams survive
+	uvIndecies := p.TriangulateTextures()
+	var uvs [][2]float64
+	if uvIndecies != nil {
+		uvs = make([][2]float64, len(p.TextureVertecies)/2)
+		for i := range uvs {
+			uvs[i] = [2]float64{p.TextureVertecies[i*2], p.TextureVertecies[i*2+1]}
+		}
+	}
+
+	mesh := &tessellation{verticies, normals, indecies, uvs, uvIndecies}
+	if edgeLength > 0 {
+		for mesh.split(edgeLength, maxDisplaceTriangles) {
+		}
+	}
+	verticies, normals, indecies = mesh.verticies, mesh.normals, mesh.indecies
+	uvs, uvIndecies = mesh.uvs, mesh.uvIndecies
+
+	// Each position is displaced once, using the texture coordinates
+	// of the first corner that refers to it
+	displaced := make([]bool, len(verticies))
+	moved := make([]vec.Vec3, len(verticies))
+	copy(moved, verticies)
+	for c, v := range indecies {
+		if displaced[v] {
+			continue
+		}
+		displaced[v] = true
+		var u, w float64
+		if uvIndecies != nil {
+			u, w = uvs[uvIndecies[c]][0], uvs[uvIndecies[c]][1]
+		}
+		moved[v] = vec.Add(verticies[v], vec.Multiply(normals[v], displace(verticies[v], u, w)))
+	}
+
+	poly := MakePolygonMesh()
+	for _, v := range moved {
+		poly.Verticies = append(poly.Verticies, v.X, v.Y, v.Z)
+	}
+	poly.VertexIndecies = indecies
+	poly.NumVerticies = make([]int, len(indecies)/3)
+	for i := range poly.NumVerticies {
+		poly.NumVerticies[i] = 3
+	}
+	poly.NumFaces[0] = len(poly.NumVerticies)
+	poly.VertexNormals = AngleWeightedNormals(poly.Verticies, indecies)
+	poly.NormalIndecies = indecies
+	if uvIndecies != nil {
+		for _, uv := range uvs {
+			poly.TextureVertecies = append(poly.TextureVertecies, uv[0], uv[1])
+		}
+		poly.TextureIndecies = uvIndecies
+	}
+	return poly
+}
+
+// maxDisplaceTriangles bounds the triangles Displace makes. Each pass can
+// quadruple them, so a small edge length would otherwise run out of
+// memory long before the edges are short enough
+const maxDisplaceTriangles = 1 << 22
+
+// tessellation is a triangle mesh being split by Displace, with a normal
+// for every position and optionally separately indexed texture
+// coordinates
+type tessellation struct {
+	verticies  []vec.Vec3
+	normals    []vec.Vec3
+	indecies   []int
+	uvs        [][2]float64
+	uvIndecies []int
+}
+
+// split splits every edge longer than edgeLength at its midpoint and
+// splits each triangle to match: into two, three or four triangles for
+// one, two or three long edges. Midpoints are shared between the
+// triangles on either side of an edge, their normals are the normalized
+// average of the edge's ends, and texture coordinates are split in step.
+// Nothing is split if that would make more than budget triangles. It
+// reports whether any edge was split
+func (m *tessellation) split(edgeLength float64, budget int) bool {
+	midpoints := make(map[edgeKey]int)
+	midpoint := func(a, b int) int {
+		key := newEdgeKey(a, b)
+		if i, ok := midpoints[key]; ok {
+			return i
+		}
+		n := vec.Add(m.normals[a], m.normals[b])
+		if n.Magnitude > 0 {
+			n = vec.Divide(n, n.Magnitude)
+		}
+		midpoints[key] = len(m.verticies)
+		m.verticies = append(m.verticies, lerpVec3(m.verticies[a], m.verticies[b], 0.5))
+		m.normals = append(m.normals, n)
+		return midpoints[key]
+	}
+	uvMidpoints := make(map[edgeKey]int)
+	uvMidpoint := func(a, b int) int {
+		key := newEdgeKey(a, b)
+		if i, ok := uvMidpoints[key]; ok {
+			return i
+		}
+		uvMidpoints[key] = len(m.uvs)
+		m.uvs = append(m.uvs, [2]float64{(m.uvs[a][0] + m.uvs[b][0]) / 2, (m.uvs[a][1] + m.uvs[b][1]) / 2})
+		return uvMidpoints[key]
+	}
+	long := func(a, b int) bool {
+		return vec.Subtract(m.verticies[a], m.verticies[b]).Magnitude > edgeLength
+	}
+
+	// A triangle with n long edges becomes n + 1 triangles
+	total, changed := 0, false
+	for t := 0; t+2 < len(m.indecies); t += 3 {
+		a, b, c := m.indecies[t], m.indecies[t+1], m.indecies[t+2]
+		for _, mark := range [3]bool{long(a, b), long(b, c), long(c, a)} {
+			if mark {
+				total++
+				changed = true
+			}
+		}
+		total++
+	}
+	if !changed || total > budget {
+		return false
+	}
+
+	split := make([]int, 0, len(m.indecies))
+	var uvSplit []int
+	for t := 0; t+2 < len(m.indecies); t += 3 {
+		v := [3]int{m.indecies[t], m.indecies[t+1], m.indecies[t+2]}
+		var uv [3]int
+		if m.uvIndecies != nil {
+			uv = [3]int{m.uvIndecies[t], m.uvIndecies[t+1], m.uvIndecies[t+2]}
+		}
+		marked := [3]bool{long(v[0], v[1]), long(v[1], v[2]), long(v[2], v[0])}
+		count := 0
+		for _, mark := range marked {
+			if mark {
+				count++
+			}
+		}
+
+		// Turn the triangle so that a single long edge comes first, or
+		// the single short edge comes last
+		r := 0
+		for k := 0; k < 3; k++ {
+			if (count == 1 && marked[k]) || (count == 2 && !marked[(k+2)%3]) {
+				r = k
+			}
+		}
+
+		// Corners 0 to 2 are the turned verticies and 3 to 5 the
+		// midpoints of the turned edges starting at them
+		emit := func(corners ...int) {
+			for _, c := range corners {
+				k := (c%3 + r) % 3
+				if c < 3 {
+					split = append(split, v[k])
+					if m.uvIndecies != nil {
+						uvSplit = append(uvSplit, uv[k])
+					}
+					continue
+				}
+				split = append(split, midpoint(v[k], v[(k+1)%3]))
+				if m.uvIndecies != nil {
+					uvSplit = append(uvSplit, uvMidpoint(uv[k], uv[(k+1)%3]))
+				}
+			}
+		}
+
+		switch count {
+		case 0:
+			emit(0, 1, 2)
+		case 1:
+			emit(0, 3, 2, 3, 1, 2)
+		case 2:
+			// The remaining quad is split across its shorter diagonal
+			a, b, c := v[r], v[(r+1)%3], v[(r+2)%3]
+			ab := lerpVec3(m.verticies[a], m.verticies[b], 0.5)
+			bc := lerpVec3(m.verticies[b], m.verticies[c], 0.5)
+			emit(3, 1, 4)
+			if vec.Subtract(m.verticies[a], bc).Magnitude <= vec.Subtract(ab, m.verticies[c]).Magnitude {
+				emit(0, 3, 4, 0, 4, 2)
+			} else {
+				emit(0, 3, 2, 3, 4, 2)
+			}
+		case 3:
+			emit(0, 3, 5, 1, 4, 3, 2, 5, 4, 3, 4, 5)
+		}
+	}
+	m.indecies = split
+	if m.uvIndecies != nil {
+		m.uvIndecies = uvSplit
+	}
+	return true
+}
+
+// PolygonMesh returns the triangle as a single face mesh with its
+// texture coordinates, and its vertex normals if it is smooth
+func (t *Triangle) PolygonMesh() *PolygonMesh {
+	poly := MakePolygonMesh()
+	poly.NumFaces[0] = 1
+	poly.NumVerticies = []int{3}
+	poly.VertexIndecies = []int{0, 1, 2}
+	poly.Verticies = []float64{
+		t.V0.X, t.V0.Y, t.V0.Z,
+		t.V1.X, t.V1.Y, t.V1.Z,
+		t.V2.X, t.V2.Y, t.V2.Z}
+	poly.TextureVertecies = []float64{
+		t.UVs[0][0], t.UVs[0][1],
+		t.UVs[1][0], t.UVs[1][1],
+		t.UVs[2][0], t.UVs[2][1]}
+	poly.TextureIndecies = []int{0, 1, 2}
+	if t.Smooth {
+		poly.VertexNormals = []float64{
+			t.N0.X, t.N0.Y, t.N0.Z,
+			t.N1.X, t.N1.Y, t.N1.Z,
+			t.N2.X, t.N2.Y, t.N2.Z}
+		poly.NormalIndecies = []int{0, 1, 2}
+	}
+	return poly
+}
+
+// Displace tessellates and displaces the triangle like
+// PolygonMesh.Displace
+func (t *Triangle) Displace(edgeLength float64, displace DisplacementFunc) *PolygonMesh {
+	return t.PolygonMesh().Displace(edgeLength, displace)
+}
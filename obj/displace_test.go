@@ -0,0 +1,144 @@
+package obj
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/vec"
+)
+
+func TestDisplaceTessellation(t *testing.T) {
+	t.Parallel()
+
+	cube := makeCubeMesh()
+	flat := cube.Displace(0.6, func(p vec.Vec3, u, v float64) float64 { return 0 })
+
+	// Two levels split every edge, the face diagonals need a third and
+	// nothing else is split further
+	if flat.NumFaces[0] <= 12*16 || flat.NumFaces[0] >= 12*64 {
+		t.Error("Face count not correct", flat.NumFaces[0])
+	}
+	// Shared midpoints keep the surface closed, so every edge has two
+	// faces and V - E + F = 2
+	if len(flat.Verticies)/3 != flat.NumFaces[0]/2+2 {
+		t.Error("Tessellated cube is not closed", len(flat.Verticies)/3)
+	}
+	edges := make(map[edgeKey]int)
+	for i := 0; i+2 < len(flat.VertexIndecies); i += 3 {
+		for k := 0; k < 3; k++ {
+			edges[newEdgeKey(flat.VertexIndecies[i+k], flat.VertexIndecies[i+(k+1)%3])]++
+		}
+	}
+	for e, n := range edges {
+		if n != 2 {
+			t.Fatal("Edge does not have two faces", e, n)
+		}
+	}
+	verticies := flat.verticiesVec3()
+	for i := 0; i+2 < len(flat.VertexIndecies); i += 3 {
+		for k := 0; k < 3; k++ {
+			e := vec.Subtract(verticies[flat.VertexIndecies[i+k]], verticies[flat.VertexIndecies[i+(k+1)%3]])
+			if e.Magnitude > 0.6 {
+				t.Fatal("Edge longer than the target length", e.Magnitude)
+			}
+		}
+	}
+
+	// Constant displacement pushes every vertex outward
+	grown := cube.Displace(0.6, func(p vec.Vec3, u, v float64) float64 { return 0.5 })
+	for i, v := range grown.verticiesVec3() {
+		if v.Magnitude <= verticies[i].Magnitude {
+			t.Fatal("Vertex not displaced outward", i, v)
+		}
+	}
+	if len(grown.VertexNormals) != len(grown.Verticies) {
+		t.Error("Normals not recomputed")
+	}
+}
+
+func TestDisplaceAdaptive(t *testing.T) {
+	t.Parallel()
+
+	// A large triangle next to a small one; only the large one is split
+	poly := MakePolygonMesh()
+	poly.Verticies = []float64{
+		0, 0, 0, 10, 0, 0, 0, 10, 0,
+		20, 0, 0, 20.1, 0, 0, 20, 0.1, 0}
+	poly.VertexIndecies = []int{0, 1, 2, 3, 4, 5}
+	poly.NumVerticies = []int{3, 3}
+	poly.NumFaces[0] = 2
+	flat := poly.Displace(1, func(p vec.Vec3, u, v float64) float64 { return 0 })
+
+	small := 0
+	verticies := flat.verticiesVec3()
+	for i := 0; i+2 < len(flat.VertexIndecies); i += 3 {
+		if verticies[flat.VertexIndecies[i]].X >= 20 {
+			small++
+		}
+		for k := 0; k < 3; k++ {
+			e := vec.Subtract(verticies[flat.VertexIndecies[i+k]], verticies[flat.VertexIndecies[i+(k+1)%3]])
+			if e.Magnitude > 1 {
+				t.Fatal("Edge longer than the target length", e.Magnitude)
+			}
+		}
+	}
+	if small != 1 {
+		t.Error("Small triangle should not be split", small)
+	}
+}
+
+func TestDisplaceBudget(t *testing.T) {
+	t.Parallel()
+
+	// An edge length far below the size of the triangle would need
+	// millions of triangles; the budget stops splitting well before that
+	verticies := []vec.Vec3{*vec.NewVec3(0, 0, 0), *vec.NewVec3(10, 0, 0), *vec.NewVec3(0, 10, 0)}
+	up := *vec.NewVec3(0, 0, 1)
+	mesh := &tessellation{verticies, []vec.Vec3{up, up, up}, []int{0, 1, 2}, nil, nil}
+	passes := 0
+	for mesh.split(1e-3, 100) {
+		passes++
+	}
+	if passes != 3 || len(mesh.indecies)/3 != 64 {
+		t.Error("Splitting should stop before the budget is exceeded", passes, len(mesh.indecies)/3)
+	}
+}
+
+func TestDisplaceHeightMap(t *testing.T) {
+	t.Parallel()
+
+	// The left half of the texture is black and the right half white
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			img.SetGray(x, y, color.Gray{255})
+		}
+	}
+	heights := NewHeightMap(img)
+	if h := heights.Sample(0.125, 0.5); h != 0 {
+		t.Error("Black texel should have height 0", h)
+	}
+	if h := heights.Sample(0.625, 0.5); h != 1 {
+		t.Error("White texel should have height 1", h)
+	}
+	if h := heights.Sample(0.5, 0.5); math.Abs(h-0.5) > 1e-9 {
+		t.Error("Height between texels should be filtered", h)
+	}
+
+	// A unit square in the xz plane facing up, with matching UVs
+	tri := NewTriangle("tri", *vec.NewVec3(0, 0, 0), *vec.NewVec3(1, 0, 0), *vec.NewVec3(0, 0, -1), color.RGBA{255, 0, 0, 1}, 1, false)
+	tri.UVs = [3][2]float64{{0, 0}, {1, 0}, {0, 1}}
+	bumpy := tri.Displace(0.2, heights.Displacement(0.25))
+
+	uvs := bumpy.TextureVertecies
+	verticies := bumpy.verticiesVec3()
+	for c, v := range bumpy.VertexIndecies {
+		uv := bumpy.TextureIndecies[c]
+		expected := heights.Sample(uvs[uv*2], uvs[uv*2+1]) * 0.25
+		if math.Abs(verticies[v].Y-expected) > 1e-9 {
+			t.Fatal("Vertex not displaced by the height map", verticies[v], expected)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Emitter is an object that gives off light of its own
+type Emitter interface {
+	Emission() [3]float64
+}
+
+// Surface is an object whose surface can be sampled evenly by area, which
+// lets it light the scene when it is emissive
+type Surface interface {
+	Object
+	Area() float64
+	// SampleSurface maps u, v from 0 to 1 evenly onto the surface and
+	// returns the point there and its unit normal
+	SampleSurface(u, v float64) (vec.Vec3, vec.Vec3)
+}
+
+// Emissive gives off Radiance in red, green and blue from the whole
+// surface of Shape, on both sides. It is both an Object and a Light:
+// shapes that are also a Surface are sampled for direct light, taking
+// Samples points per shaded point; others only glow where they are seen
+type Emissive struct {
+	Shape    Object
+	Radiance [3]float64
+	Samples  int
+}
+
+// NewEmissive is a constructor for Emissive with default sampling
+func NewEmissive(shape Object, radiance [3]float64) *Emissive {
+	e := new(Emissive)
+	e.Shape = shape
+	e.Radiance = radiance
+	e.Samples = 4
+	return e
+}
+
+// GetID returns the ID of the shape
+func (e *Emissive) GetID() string {
+	return e.Shape.GetID()
+}
+
+// GetColor returns the color of the shape, which is how it reflects
+// light falling on it
+func (e *Emissive) GetColor() color.RGBA {
+	return e.Shape.GetColor()
+}
+
+// GetRefractiveIndex returns the refractive index of the shape
+func (e *Emissive) GetRefractiveIndex() float64 {
+	return e.Shape.GetRefractiveIndex()
+}
+
+// Bounds returns the bounding box of the shape
+func (e *Emissive) Bounds() AABB {
+	return e.Shape.Bounds()
+}
+
+// Intersects intersects the shape. The record refers to the Emissive, so
+// the hit gives off its Radiance
+func (e *Emissive) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	rec, isHit := e.Shape.Intersects(ray)
+	if !isHit {
+		return FalseObject()
+	}
+	rec.Object = e
+	return rec, true
+}
+
+// Emission returns the radiance given off by the shape
+func (e *Emissive) Emission() [3]float64 {
+	return e.Radiance
+}
+
+// Sampled reports whether the shape can be sampled for direct light
+func (e *Emissive) Sampled() bool {
+	_, ok := e.Shape.(Surface)
+	return ok && e.Samples > 0
+}
+
+// NumSamples returns Samples for shapes that can be sampled for direct
+// light, and 0 for others
+func (e *Emissive) NumSamples() int {
+	if !e.Sampled() {
+		return 0
+	}
+	return e.Samples
+}
+
+// Sample picks the point on the shape that u, v map to. It returns false
+// for shapes that cannot be sampled
+func (e *Emissive) Sample(p vec.Vec3, u, v float64) (LightSample, bool) {
+	surface, ok := e.Shape.(Surface)
+	if !ok {
+		return LightSample{}, false
+	}
+
+	q, n := surface.SampleSurface(u, v)
+	toLight := vec.Subtract(q, p)
+	dist := toLight.Magnitude
+	if dist == 0 {
+		return LightSample{}, false
+	}
+	return LightSample{vec.Divide(toLight, dist), dist, q, n}, true
+}
+
+// Evaluate returns the radiance scaled by the area of the shape and the
+// cosine at the sampled point over the squared distance. Averaged over
+// evenly spread samples this is the light arriving at p from the whole
+// shape
+func (e *Emissive) Evaluate(p vec.Vec3, s LightSample) [3]float64 {
+	var radiance [3]float64
+	surface, ok := e.Shape.(Surface)
+	if !ok {
+		return radiance
+	}
+	scale := surface.Area() * math.Abs(vec.Dot(s.Normal, s.Direction)) / (s.Distance * s.Distance)
+	for c := range radiance {
+		radiance[c] = e.Radiance[c] * scale
+	}
+	return radiance
+}
+
+// sampleTriangle maps u, v evenly onto the triangle v0, v1, v2
+func sampleTriangle(v0, v1, v2 vec.Vec3, u, v float64) vec.Vec3 {
+	su := math.Sqrt(u)
+	b1, b2 := su*(1-v), su*v
+	return vec.Add(v0, vec.Add(vec.Multiply(vec.Subtract(v1, v0), b1), vec.Multiply(vec.Subtract(v2, v0), b2)))
+}
+
+// Area returns the surface area of the sphere
+func (s Sphere) Area() float64 {
+	return 4 * math.Pi * s.Radius * s.Radius
+}
+
+// SampleSurface maps u, v evenly onto the sphere
+func (s Sphere) SampleSurface(u, v float64) (vec.Vec3, vec.Vec3) {
+	z := 1 - 2*u
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * v
+	n := *vec.NewVec3(r*math.Cos(phi), r*math.Sin(phi), z)
+	return vec.Add(s.Center, vec.Multiply(n, s.Radius)), n
+}
+
+// Area returns the area of the triangle
+func (t *Triangle) Area() float64 {
+	return vec.Cross(vec.Subtract(t.V1, t.V0), vec.Subtract(t.V2, t.V0)).Magnitude / 2
+}
+
+// SampleSurface maps u, v evenly onto the triangle
+func (t *Triangle) SampleSurface(u, v float64) (vec.Vec3, vec.Vec3) {
+	return sampleTriangle(t.V0, t.V1, t.V2, u, v), t.N
+}
+
+// Area returns the area of the disk between its inner and outer radius
+func (d *Disk) Area() float64 {
+	return math.Pi * (d.Radius*d.Radius - d.InnerRadius*d.InnerRadius)
+}
+
+// SampleSurface maps u, v evenly onto the disk
+func (d *Disk) SampleSurface(u, v float64) (vec.Vec3, vec.Vec3) {
+	inner := d.InnerRadius * d.InnerRadius
+	r := math.Sqrt(inner + u*(d.Radius*d.Radius-inner))
+	phi := 2 * math.Pi * v
+	return d.frame.toWorld(*vec.NewVec3(r*math.Cos(phi), r*math.Sin(phi), 0)), d.Normal
+}
+
+// triangleAreas returns the running total of the areas of the triangles,
+// building it on first use
+func (m *TriangleMesh) triangleAreas() []float64 {
+	if m.areas == nil {
+		m.areas = make([]float64, m.NumTriangles(), m.NumTriangles())
+		total := 0.0
+		for i := range m.areas {
+			v0, v1, v2 := m.TriangleVerticies(i)
+			total += vec.Cross(vec.Subtract(v1, v0), vec.Subtract(v2, v0)).Magnitude / 2
+			m.areas[i] = total
+		}
+	}
+	return m.areas
+}
+
+// Area returns the total area of the triangles of the mesh
+func (m *TriangleMesh) Area() float64 {
+	areas := m.triangleAreas()
+	if len(areas) == 0 {
+		return 0
+	}
+	return areas[len(areas)-1]
+}
+
+// SampleSurface maps u, v evenly onto the mesh. u first picks a triangle
+// with a chance in proportion to its area, and is then stretched back
+// over 0 to 1 to pick the point within it
+func (m *TriangleMesh) SampleSurface(u, v float64) (vec.Vec3, vec.Vec3) {
+	areas := m.triangleAreas()
+	if len(areas) == 0 {
+		return vec.Vec3{}, vec.Vec3{}
+	}
+	total := areas[len(areas)-1]
+	target := u * total
+	i := sort.SearchFloat64s(areas, target)
+	if i >= len(areas) {
+		i = len(areas) - 1
+	}
+
+	low := 0.0
+	if i > 0 {
+		low = areas[i-1]
+	}
+	if areas[i] > low {
+		u = math.Min(1, (target-low)/(areas[i]-low))
+	}
+
+	v0, v1, v2 := m.TriangleVerticies(i)
+	n := vec.Cross(vec.Subtract(v1, v0), vec.Subtract(v2, v0))
+	if n.Magnitude > 0 {
+		n = vec.Divide(n, n.Magnitude)
+	}
+	return sampleTriangle(v0, v1, v2, u, v), n
+}
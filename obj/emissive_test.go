@@ -0,0 +1,133 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestEmissiveSample(t *testing.T) {
+	t.Parallel()
+
+	// Averaged over the disk the samples add up to the radiance times
+	// the solid angle the disk covers
+	disk := NewDisk("disk1", *vec.NewVec3(0, 0, -1), *vec.NewVec3(0, 0, 1), 1, 0, color.RGBA{0, 0, 0, 1}, 1)
+	lamp := NewEmissive(disk, [3]float64{2, 1, 0})
+	p := *vec.NewVec3(0, 0, 0)
+
+	const n = 64
+	var sum [3]float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			s, ok := lamp.Sample(p, (float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			if !ok || s.Direction.Z >= 0 || s.Distance < 1 || s.Distance > math.Sqrt(2)+1e-9 {
+				t.Fatal("Sample not on the disk", s)
+			}
+			radiance := lamp.Evaluate(p, s)
+			for c := range sum {
+				sum[c] += radiance[c] / (n * n)
+			}
+		}
+	}
+	solidAngle := 2 * math.Pi * (1 - 1/math.Sqrt(2))
+	if math.Abs(sum[0]-2*solidAngle) > 1e-3 || math.Abs(sum[1]-solidAngle) > 1e-3 || sum[2] != 0 {
+		t.Error("Disk light does not add up to its solid angle", sum, solidAngle)
+	}
+
+	// Hits on the shape refer to the emitter
+	ray := cam.NewRay(1, "camera", &p, vec.NewVec3(0, 0, -1))
+	rec, isHit := lamp.Intersects(ray)
+	if !isHit || rec.Object != lamp || rec.Object.(Emitter).Emission() != lamp.Radiance {
+		t.Error("Hit should give off the radiance of the emitter")
+	}
+
+	// Shapes without a surface to sample only glow
+	box := NewEmissive(NewBVH("bvh1", []Object{disk}), [3]float64{1, 1, 1})
+	if box.Sampled() || box.NumSamples() != 0 {
+		t.Error("Shape without a surface to sample should not be sampled")
+	}
+}
+
+func TestTriangleMeshSampleSurface(t *testing.T) {
+	t.Parallel()
+
+	// Two triangles in the plane z = -2 with areas 1 and 3
+	poly := MakePolygonMesh()
+	poly.NumFaces[0] = 2
+	poly.NumVerticies = []int{3, 3}
+	poly.VertexIndecies = []int{0, 1, 2, 3, 4, 5}
+	poly.Verticies = []float64{
+		0, 0, -2, 2, 0, -2, 0, 1, -2,
+		10, 0, -2, 13, 0, -2, 10, 2, -2}
+	mesh := NewTriangleMesh("mesh1", poly, color.RGBA{0, 0, 0, 1}, 1, false)
+
+	if math.Abs(mesh.Area()-4) > 1e-12 {
+		t.Error("Mesh area not correct", mesh.Area())
+	}
+
+	inFirst := 0
+	const n = 32
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			p, normal := mesh.SampleSurface((float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			if p.Z != -2 || math.Abs(math.Abs(normal.Z)-1) > 1e-12 {
+				t.Fatal("Sample not on the mesh", p, normal)
+			}
+			if p.X < 5 {
+				inFirst++
+			}
+		}
+	}
+	if inFirst != n*n/4 {
+		t.Error("Triangles should be sampled in proportion to their area", inFirst)
+	}
+}
+
+func TestTransformedEmissive(t *testing.T) {
+	t.Parallel()
+
+	disk := NewDisk("disk1", *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 1), 1, 0, color.RGBA{0, 0, 0, 1}, 1)
+	lamp := NewEmissive(disk, [3]float64{1, 1, 1})
+	moved, err := NewTransformed(lamp, *vec.NewVec3(0, 0, -1), *vec.NewVec3(0, 0, 1), 0, *vec.NewVec3(2, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tinted, err := NewInstance("tinted", lamp, moved.Transform, color.RGBA{255, 0, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both still glow where they are hit
+	p := *vec.NewVec3(0, 0, 0)
+	ray := cam.NewRay(1, "camera", &p, vec.NewVec3(0, 0, -1))
+	for _, object := range []Object{moved, tinted} {
+		rec, isHit := object.Intersects(ray)
+		if !isHit {
+			t.Fatal("Transformed disk was not hit")
+		}
+		emitter, ok := rec.Object.(Emitter)
+		if !ok || emitter.Emission() != lamp.Radiance {
+			t.Error("Transformed emissive disk should still glow", rec.Object.GetID())
+		}
+	}
+
+	// As a light the instance covers the solid angle of a disk of radius 2
+	const n = 64
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			s, ok := moved.Sample(p, (float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			if !ok || math.Abs(s.Point.Z+1) > 1e-12 || math.Abs(math.Abs(s.Normal.Z)-1) > 1e-12 {
+				t.Fatal("Sample not on the transformed disk", s)
+			}
+			sum += moved.Evaluate(p, s)[0] / (n * n)
+		}
+	}
+	solidAngle := 2 * math.Pi * (1 - 1/math.Sqrt(5))
+	if moved.NumSamples() != lamp.Samples || math.Abs(sum-solidAngle) > 1e-3 {
+		t.Error("Transformed disk light does not add up to its solid angle", sum, solidAngle)
+	}
+}
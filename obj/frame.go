@@ -0,0 +1,131 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// frame is an orthonormal coordinate system. The analytic primitives
+// intersect rays in a local space where their axis is z, and use a frame
+// to move rays in and hits out. Since the axes are orthonormal, distances
+// along the ray are the same in both spaces
+type frame struct {
+	Origin vec.Vec3
+	U      vec.Vec3
+	V      vec.Vec3
+	W      vec.Vec3
+}
+
+// newFrame creates a frame at origin whose z axis points along axis
+func newFrame(origin, axis vec.Vec3) frame {
+	w := vec.Divide(axis, axis.Magnitude)
+	u, v := tangentFrame(w)
+	return frame{origin, u, v, w}
+}
+
+// newAlignedFrame creates a frame at origin whose z axis points along axis
+// and whose x axis points along xAxis projected perpendicular to it
+func newAlignedFrame(origin, axis, xAxis vec.Vec3) frame {
+	w := vec.Divide(axis, axis.Magnitude)
+	u, v := alignTangentFrame(w, xAxis)
+	return frame{origin, u, v, w}
+}
+
+// toLocal moves a world space point into the frame
+func (f frame) toLocal(p vec.Vec3) vec.Vec3 {
+	d := vec.Subtract(p, f.Origin)
+	return f.dirToLocal(d)
+}
+
+// dirToLocal moves a world space direction into the frame
+func (f frame) dirToLocal(d vec.Vec3) vec.Vec3 {
+	return *vec.NewVec3(vec.Dot(d, f.U), vec.Dot(d, f.V), vec.Dot(d, f.W))
+}
+
+// toWorld moves a local point out of the frame
+func (f frame) toWorld(p vec.Vec3) vec.Vec3 {
+	return vec.Add(f.Origin, f.dirToWorld(p))
+}
+
+// dirToWorld moves a local direction out of the frame
+func (f frame) dirToWorld(d vec.Vec3) vec.Vec3 {
+	return *vec.NewVec3(
+		f.U.X*d.X+f.V.X*d.Y+f.W.X*d.Z,
+		f.U.Y*d.X+f.V.Y*d.Y+f.W.Y*d.Z,
+		f.U.Z*d.X+f.V.Z*d.Y+f.W.Z*d.Z)
+}
+
+// localRay returns the origin and normalized direction of the ray in
+// the frame
+func (f frame) localRay(ray *cam.Ray) (vec.Vec3, vec.Vec3) {
+	dir := ray.Direction
+	dir.Normalize()
+	return f.toLocal(ray.Origin), f.dirToLocal(dir)
+}
+
+// pointError bounds the error of a world space point moved out of the
+// frame from local point p, which itself has error bound err
+func (f frame) pointError(p, err vec.Vec3) vec.Vec3 {
+	axes := [3]vec.Vec3{f.U, f.V, f.W}
+	local := [3]float64{p.X, p.Y, p.Z}
+	localErr := [3]float64{err.X, err.Y, err.Z}
+	origin := [3]float64{f.Origin.X, f.Origin.Y, f.Origin.Z}
+
+	var bound [3]float64
+	for i := 0; i < 3; i++ {
+		sum := math.Abs(origin[i])
+		propagated := 0.0
+		for k, a := range axes {
+			c := [3]float64{a.X, a.Y, a.Z}[i]
+			sum += math.Abs(c * local[k])
+			propagated += math.Abs(c) * localErr[k]
+		}
+		bound[i] = gamma(5)*sum + (1+gamma(5))*propagated
+	}
+	return *vec.NewVec3(bound[0], bound[1], bound[2])
+}
+
+// bounds returns the world space bounding box of a local bounding box
+func (f frame) bounds(local AABB) AABB {
+	b := EmptyAABB()
+	for _, c := range local.Corners() {
+		b = b.Extend(f.toWorld(c))
+	}
+	return b
+}
+
+// hitRecord builds a world space hit record from a hit computed in the
+// frame. dir is the world space ray direction, p, n and dpdu the local
+// hit point, outward normal and derivative along u, and err the error
+// bound of p
+func (f frame) hitRecord(object Object, dir, p, n, dpdu, err vec.Vec3, t0, t1, u, v float64) HitRecord {
+	worldN := f.dirToWorld(n)
+	worldN = vec.Divide(worldN, worldN.Magnitude)
+
+	rec := newHitRecord(object, dir, f.toWorld(p), worldN, t0, t1)
+	rec.U = u
+	rec.V = v
+	rec.Tangent, rec.Bitangent = alignTangentFrame(worldN, f.dirToWorld(dpdu))
+	rec.Error = f.pointError(p, err)
+	return rec
+}
+
+// localError bounds the error of a local hit point that was reprojected
+// onto the surface, relative to its own coordinates
+func localError(p vec.Vec3, n float64) vec.Vec3 {
+	return *vec.NewVec3(
+		gamma(n)*math.Abs(p.X),
+		gamma(n)*math.Abs(p.Y),
+		gamma(n)*math.Abs(p.Z))
+}
+
+// sphericalAngle returns the angle of (x, y) around the z axis in [0, 2pi)
+func sphericalAngle(x, y float64) float64 {
+	phi := math.Atan2(y, x)
+	if phi < 0 {
+		phi += 2 * math.Pi
+	}
+	return phi
+}
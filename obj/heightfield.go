@@ -0,0 +1,221 @@
+package obj
+
+import (
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Heightfield is terrain defined by a grid of elevation samples. Each
+// pixel of Heights is a vertex of the grid, laid out along +x by column
+// and along +z by row starting at Origin, and the quads between them are
+// split into two triangles. Rays walk the grid cell by cell so the
+// triangles never have to be stored
+type Heightfield struct {
+	ID              string
+	Heights         *HeightMap
+	Origin          vec.Vec3 // corner of the first sample at height 0
+	Size            vec.Vec3 // extent along x and z, and the height of white
+	Col             color.RGBA
+	RefractiveIndex float64
+	normals         []vec.Vec3
+	box             AABB
+}
+
+// NewHeightfield is a constructor for Heightfield objects. The height map
+// needs at least 2 x 2 samples
+func NewHeightfield(id string, heights *HeightMap, origin, size vec.Vec3, col color.RGBA, refractive float64) (*Heightfield, error) {
+	if heights.Width < 2 || heights.Height < 2 {
+		return nil, errors.New("height map needs at least 2 x 2 samples")
+	}
+	if len(heights.Heights) != heights.Width*heights.Height {
+		return nil, errors.New("height map size does not match its heights")
+	}
+
+	h := new(Heightfield)
+	h.ID = id
+	h.Heights = heights
+	h.Origin = origin
+	h.Size = size
+	h.Col = col
+	h.RefractiveIndex = refractive
+
+	h.box = EmptyAABB()
+	for j := 0; j < heights.Height; j++ {
+		for i := 0; i < heights.Width; i++ {
+			h.box = h.box.Extend(h.vertex(i, j))
+		}
+	}
+	h.computeNormals()
+	return h, nil
+}
+
+// GetID is the object specific method to return the ID of the heightfield
+func (h *Heightfield) GetID() string {
+	return h.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (h *Heightfield) GetColor() color.RGBA {
+	return h.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (h *Heightfield) GetRefractiveIndex() float64 {
+	return h.RefractiveIndex
+}
+
+// Bounds returns the box around every sample of the heightfield
+func (h *Heightfield) Bounds() AABB {
+	return h.box
+}
+
+// cellSize returns the spacing of the grid along x and z
+func (h *Heightfield) cellSize() (float64, float64) {
+	return h.Size.X / float64(h.Heights.Width-1), h.Size.Z / float64(h.Heights.Height-1)
+}
+
+// vertex returns the position of sample (i, j)
+func (h *Heightfield) vertex(i, j int) vec.Vec3 {
+	dx, dz := h.cellSize()
+	return *vec.NewVec3(
+		h.Origin.X+float64(i)*dx,
+		h.Origin.Y+h.Heights.Heights[j*h.Heights.Width+i]*h.Size.Y,
+		h.Origin.Z+float64(j)*dz)
+}
+
+// uv returns the texture coordinates of sample (i, j), which match
+// HeightMap.Sample
+func (h *Heightfield) uv(i, j int) [2]float64 {
+	return [2]float64{
+		float64(i) / float64(h.Heights.Width-1),
+		1 - float64(j)/float64(h.Heights.Height-1)}
+}
+
+// computeNormals finds the vertex normals from central differences of the
+// heights, or one sided differences along the edges of the grid
+func (h *Heightfield) computeNormals() {
+	w, d := h.Heights.Width, h.Heights.Height
+	h.normals = make([]vec.Vec3, w*d)
+	for j := 0; j < d; j++ {
+		for i := 0; i < w; i++ {
+			i0, i1 := int(math.Max(float64(i-1), 0)), int(math.Min(float64(i+1), float64(w-1)))
+			j0, j1 := int(math.Max(float64(j-1), 0)), int(math.Min(float64(j+1), float64(d-1)))
+			dx := vec.Subtract(h.vertex(i1, j), h.vertex(i0, j))
+			dz := vec.Subtract(h.vertex(i, j1), h.vertex(i, j0))
+			n := vec.Cross(dz, dx)
+			h.normals[j*w+i] = vec.Divide(n, n.Magnitude)
+		}
+	}
+}
+
+// Intersects walks the cells of the grid under the ray with a 2D DDA and
+// tests the two triangles of each cell it crosses, skipping cells the ray
+// passes above or below. The first hit found is the closest
+func (h *Heightfield) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	dir := ray.Direction
+	dir.Normalize()
+	invDir := *vec.NewVec3(1/dir.X, 1/dir.Y, 1/dir.Z)
+
+	isHit, tMin, tMax := h.box.IntersectsRay(ray.Origin, invDir, math.Inf(1))
+	if !isHit {
+		return FalseObject()
+	}
+
+	dx, dz := h.cellSize()
+	cellsX, cellsZ := h.Heights.Width-1, h.Heights.Height-1
+	start := vec.Add(ray.Origin, vec.Multiply(dir, tMin))
+	i := int(math.Max(0, math.Min(float64(cellsX-1), math.Floor((start.X-h.Origin.X)/dx))))
+	j := int(math.Max(0, math.Min(float64(cellsZ-1), math.Floor((start.Z-h.Origin.Z)/dz))))
+
+	// Distance along the ray to the next cell boundary along each axis,
+	// and between boundaries
+	step := func(d, org, origin, size float64, cell int) (int, float64, float64) {
+		switch {
+		case d > 0:
+			return 1, (origin + float64(cell+1)*size - org) / d, size / d
+		case d < 0:
+			return -1, (origin + float64(cell)*size - org) / d, -size / d
+		}
+		return 0, math.Inf(1), math.Inf(1)
+	}
+	stepI, nextX, deltaX := step(dir.X, ray.Origin.X, h.Origin.X, dx, i)
+	stepJ, nextZ, deltaZ := step(dir.Z, ray.Origin.Z, h.Origin.Z, dz, j)
+
+	tEnter := tMin
+	for i >= 0 && i < cellsX && j >= 0 && j < cellsZ && tEnter <= tMax {
+		tExit := math.Min(tMax, math.Min(nextX, nextZ))
+		if rec, isHit := h.intersectCell(ray, dir, i, j, tEnter, tExit); isHit {
+			return rec, true
+		}
+		if tExit >= tMax {
+			break
+		}
+
+		tEnter = tExit
+		if nextX < nextZ {
+			i += stepI
+			nextX += deltaX
+		} else {
+			j += stepJ
+			nextZ += deltaZ
+		}
+	}
+	return FalseObject()
+}
+
+// intersectCell tests the two triangles of cell (i, j), which the ray
+// crosses between tEnter and tExit
+func (h *Heightfield) intersectCell(ray *cam.Ray, dir vec.Vec3, i, j int, tEnter, tExit float64) (HitRecord, bool) {
+	w := h.Heights.Width
+	corners := [4][2]int{{i, j}, {i + 1, j}, {i, j + 1}, {i + 1, j + 1}}
+	var verticies [4]vec.Vec3
+	low, high := math.Inf(1), math.Inf(-1)
+	for k, c := range corners {
+		verticies[k] = h.vertex(c[0], c[1])
+		low = math.Min(low, verticies[k].Y)
+		high = math.Max(high, verticies[k].Y)
+	}
+
+	// Skip the cell if the ray stays above or below all of its corners.
+	// The heights along the ray carry rounding error, which matters when
+	// the cell is flat and the ray only grazes its height
+	y0 := ray.Origin.Y + dir.Y*tEnter
+	y1 := ray.Origin.Y + dir.Y*tExit
+	pad := 1e-9 * (1 + math.Abs(ray.Origin.Y) + math.Abs(dir.Y)*math.Max(math.Abs(tEnter), math.Abs(tExit)) +
+		math.Max(math.Abs(low), math.Abs(high)))
+	if math.Min(y0, y1) > high+pad || math.Max(y0, y1) < low-pad {
+		return FalseObject()
+	}
+
+	// Both triangles wind counter clockwise seen from above
+	closest := math.Inf(1)
+	var rec HitRecord
+	for _, tri := range [2][3]int{{0, 2, 1}, {1, 2, 3}} {
+		v0, v1, v2 := verticies[tri[0]], verticies[tri[1]], verticies[tri[2]]
+		isHit, t0, u, v := intersectTriangle(ray.Origin, dir, v0, v1, v2, false)
+		if !isHit || t0 >= closest {
+			continue
+		}
+		closest = t0
+
+		n := vec.Cross(vec.Subtract(v1, v0), vec.Subtract(v2, v0))
+		n = vec.Divide(n, n.Magnitude)
+		rec = triangleHitRecord(h, dir, v0, v1, v2, n, t0, u, v)
+
+		var uvs [3][2]float64
+		var normals [3]vec.Vec3
+		for k, c := range tri {
+			uvs[k] = h.uv(corners[c][0], corners[c][1])
+			normals[k] = h.normals[corners[c][1]*w+corners[c][0]]
+		}
+		applyTriangleUVs(&rec, v0, v1, v2, uvs)
+		shadeTriangle(&rec, dir, normals[0], normals[1], normals[2])
+	}
+	return rec, !math.IsInf(closest, 1)
+}
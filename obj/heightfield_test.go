@@ -0,0 +1,116 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestHeightfieldIntersects(t *testing.T) {
+	t.Parallel()
+
+	// A single peak in the middle of a 3 x 3 grid
+	heights := &HeightMap{3, 3, []float64{
+		0, 0, 0,
+		0, 1, 0,
+		0, 0, 0}}
+	hill, err := NewHeightfield("hill", heights, *vec.NewVec3(-1, 0, -1), *vec.NewVec3(2, 1, 2), color.RGBA{0, 255, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top := cam.NewRay(0, "camera", vec.NewVec3(0, 5, 0), vec.NewVec3(0, -1, 0))
+	rec, is_hit := hill.Intersects(top)
+	if !is_hit || math.Abs(rec.T0-4) > 1e-9 {
+		t.Fatal("Peak not hit correctly", rec.T0)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 1, 0)) {
+		t.Error("Normal at the peak should point straight up", rec.Normal)
+	}
+	if math.Abs(rec.U-0.5) > 1e-9 || math.Abs(rec.V-0.5) > 1e-9 {
+		t.Error("UV at the peak not correct", rec.U, rec.V)
+	}
+
+	// On the slope the plane through the corners is x + y + z = 1
+	slope := cam.NewRay(0, "camera", vec.NewVec3(0.25, 5, 0.25), vec.NewVec3(0, -1, 0))
+	rec, is_hit = hill.Intersects(slope)
+	if !is_hit || math.Abs(rec.T0-4.5) > 1e-9 {
+		t.Error("Slope not hit correctly", rec.T0)
+	}
+	if !rec.FrontFace || rec.Normal.Y <= 0 {
+		t.Error("Slope should be hit from above", rec.Normal)
+	}
+
+	// Walking the grid sideways the ray climbs into the hill
+	side := cam.NewRay(0, "camera", vec.NewVec3(-5, 0.5, 0), vec.NewVec3(1, 0, 0))
+	rec, is_hit = hill.Intersects(side)
+	if !is_hit || math.Abs(rec.T0-4.5) > 1e-9 {
+		t.Error("Side of the hill not hit correctly", rec.T0)
+	}
+
+	above := cam.NewRay(0, "camera", vec.NewVec3(-5, 2, 0), vec.NewVec3(1, 0, 0))
+	if _, is_hit := hill.Intersects(above); is_hit {
+		t.Error("Ray above the hill should miss")
+	}
+}
+
+func TestHeightfieldTooSmall(t *testing.T) {
+	t.Parallel()
+
+	maps := []*HeightMap{
+		{1, 3, []float64{0, 1, 0}},
+		{3, 1, []float64{0, 1, 0}},
+		{0, 0, nil},
+		{2, 2, []float64{0, 1, 0}},
+	}
+	for _, heights := range maps {
+		_, err := NewHeightfield("flat", heights, vec.Vec3{}, *vec.NewVec3(1, 1, 1), color.RGBA{0, 255, 0, 1}, 1)
+		if err == nil {
+			t.Error("Height map should be rejected", heights.Width, heights.Height, len(heights.Heights))
+		}
+	}
+}
+
+func TestHeightfieldFlat(t *testing.T) {
+	t.Parallel()
+
+	// A flat field has a box of no height, so every ray crosses the height
+	// of the cells at a single point
+	flat := &HeightMap{3, 3, []float64{0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5}}
+	for _, size := range []vec.Vec3{*vec.NewVec3(2, 1, 2), *vec.NewVec3(2, 0, 2)} {
+		field, err := NewHeightfield("flat", flat, *vec.NewVec3(-1, 0, -1), size, color.RGBA{0, 255, 0, 1}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		height := size.Y * 0.5
+
+		missed := 0
+		for i := 0; i < 40; i++ {
+			for j := 0; j < 40; j++ {
+				origin := vec.NewVec3(-1.3+float64(i)*0.07, 3, -1.2+float64(j)*0.065)
+				dir := vec.NewVec3(0.3*math.Sin(float64(i*j)), -1, 0.3*math.Cos(float64(i+j)))
+				ray := cam.NewRay(0, "camera", origin, dir)
+
+				// The plane the field lies in, clipped to its extent
+				dir.Normalize()
+				t0 := (height - origin.Y) / dir.Y
+				x, z := origin.X+dir.X*t0, origin.Z+dir.Z*t0
+				inside := x > -1+1e-6 && x < 1-1e-6 && z > -1+1e-6 && z < 1-1e-6
+
+				rec, is_hit := field.Intersects(ray)
+				if inside && (!is_hit || math.Abs(rec.T0-t0) > 1e-9) {
+					missed++
+				}
+				if !inside && is_hit && (x < -1-1e-6 || x > 1+1e-6 || z < -1-1e-6 || z > 1+1e-6) {
+					t.Error("Ray beside the flat field should miss", x, z)
+				}
+			}
+		}
+		if missed > 0 {
+			t.Error("Flat field should be hit wherever the plane is", size.Y, missed)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// HitRecord holds everything known about an intersection between a ray
+// and a surface. Normals point out of the surface regardless of which
+// side was hit; FrontFace records whether the ray arrived from outside
+type HitRecord struct {
+	// T0 is the distance to the hit, T1 the distance to where the ray
+	// leaves the object (equal to T0 for surfaces without volume)
+	T0 float64
+	T1 float64
+
+	Point           vec.Vec3
+	Normal          vec.Vec3 // shading normal
+	GeometricNormal vec.Vec3 // true normal of the surface
+	FrontFace       bool
+
+	// Surface parameterization of the hit
+	U float64
+	V float64
+
+	// Barycentric coordinates, for triangles only. They weight the
+	// first, second and third vertex respectively
+	Barycentric [3]float64
+
+	// Tangent frame. Tangent follows increasing U where the surface has
+	// a parameterization, and together with Bitangent and Normal forms an
+	// orthonormal basis
+	Tangent   vec.Vec3
+	Bitangent vec.Vec3
+
+	// Error bounds the floating point error in each coordinate of Point
+	Error vec.Vec3
+
+	// Object is the primitive that was hit and carries its material
+	Object Object
+}
+
+// newHitRecord fills in the fields shared by every primitive. dir is the
+// normalized ray direction and n the outward geometric normal, which is
+// also used as the shading normal
+func newHitRecord(object Object, dir, p, n vec.Vec3, t0, t1 float64) HitRecord {
+	rec := HitRecord{
+		T0:              t0,
+		T1:              t1,
+		Point:           p,
+		Normal:          n,
+		GeometricNormal: n,
+		FrontFace:       vec.Dot(dir, n) < 0,
+		Object:          object}
+	rec.Tangent, rec.Bitangent = tangentFrame(n)
+	return rec
+}
+
+// tangentFrame builds an orthonormal tangent and bitangent around the
+// normal n. It is used when a surface has no natural parameterization
+func tangentFrame(n vec.Vec3) (vec.Vec3, vec.Vec3) {
+	var t vec.Vec3
+	if math.Abs(n.X) > math.Abs(n.Y) {
+		t = *vec.NewVec3(-n.Z, 0, n.X)
+	} else {
+		t = *vec.NewVec3(0, n.Z, -n.Y)
+	}
+	t = vec.Divide(t, t.Magnitude)
+	return t, vec.Cross(n, t)
+}
+
+// alignTangentFrame builds the tangent frame around n from a surface
+// derivative dpdu, falling back to an arbitrary frame when dpdu is
+// degenerate or parallel to n
+func alignTangentFrame(n, dpdu vec.Vec3) (vec.Vec3, vec.Vec3) {
+	t := vec.Subtract(dpdu, vec.Multiply(n, vec.Dot(n, dpdu)))
+	if t.Magnitude == 0 {
+		return tangentFrame(n)
+	}
+	t = vec.Divide(t, t.Magnitude)
+	return t, vec.Cross(n, t)
+}
@@ -0,0 +1,98 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestHitRecordFrontFace(t *testing.T) {
+	t.Parallel()
+
+	sphere := Sphere{"sphere1", *vec.NewVec3(0, 0, -3), 1, color.RGBA{0, 0, 255, 1}, 1}
+	outside := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	inside := cam.NewRay(0, "refraction", vec.NewVec3(0, 0, -3), vec.NewVec3(0, 0, -1))
+
+	rec1, _ := sphere.Intersects(outside)
+	rec2, _ := sphere.Intersects(inside)
+
+	if !rec1.FrontFace || rec2.FrontFace {
+		t.Error("Front face flag is not correct")
+	}
+
+	if rec1.Object.GetID() != "sphere1" {
+		t.Error("Hit record should refer to the sphere")
+	}
+}
+
+func TestHitRecordTriangle(t *testing.T) {
+	t.Parallel()
+
+	v0 := vec.NewVec3(0, 0, -1)
+	v1 := vec.NewVec3(1, 0, -1)
+	v2 := vec.NewVec3(0, 1, -1)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, false)
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0.25, 0.5, 0), vec.NewVec3(0, 0, -1))
+
+	rec, is_hit := tri.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Triangle was not hit")
+	}
+
+	b := rec.Barycentric
+	if math.Abs(b[0]-0.25) > 1e-9 || math.Abs(b[1]-0.25) > 1e-9 || math.Abs(b[2]-0.5) > 1e-9 {
+		t.Error("Barycentric coordinates are not correct")
+	}
+
+	if math.Abs(vec.Dot(rec.Tangent, rec.Normal)) > 1e-9 ||
+		math.Abs(vec.Dot(rec.Bitangent, rec.Normal)) > 1e-9 ||
+		math.Abs(vec.Dot(rec.Tangent, rec.Bitangent)) > 1e-9 {
+		t.Error("Tangent frame is not orthogonal")
+	}
+
+	if !vec.IsEqual(rec.Tangent, *vec.NewVec3(1, 0, 0)) {
+		t.Error("Tangent should follow the first edge")
+	}
+}
+
+func TestHitRecordBVHReportsPrimitive(t *testing.T) {
+	t.Parallel()
+
+	red := Sphere{"red", *vec.NewVec3(0, 0, -3), 1, color.RGBA{255, 0, 0, 1}, 1}
+	blue := Sphere{"blue", *vec.NewVec3(3, 0, -3), 1, color.RGBA{0, 0, 255, 1}, 1}
+	bvh := NewBVH("bvh", []Object{red, blue})
+	ray := cam.NewRay(0, "camera", vec.NewVec3(3, 0, 0), vec.NewVec3(0, 0, -1))
+
+	rec, _ := bvh.Intersects(ray)
+	if rec.Object.GetColor() != blue.Col {
+		t.Error("Hit record should carry the material of the primitive that was hit")
+	}
+}
+
+func TestSphereUV(t *testing.T) {
+	t.Parallel()
+
+	sphere := Sphere{"sphere1", *vec.NewVec3(0, 0, -3), 1, color.RGBA{0, 0, 255, 1}, 1}
+
+	// The point facing the camera is a quarter of the way around from +x
+	// on the equator
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, _ := sphere.Intersects(ray)
+	if math.Abs(rec.U-0.75) > 1e-9 || math.Abs(rec.V-0.5) > 1e-9 {
+		t.Error("UV not correct", rec.U, rec.V)
+	}
+
+	// The tangent follows increasing U, which runs from +x towards -z
+	if !vec.IsEqual(rec.Tangent, *vec.NewVec3(1, 0, 0)) {
+		t.Error("Tangent not correct", rec.Tangent)
+	}
+
+	top := cam.NewRay(0, "camera", vec.NewVec3(0, 5, -3), vec.NewVec3(0, -1, 0))
+	rec, _ = sphere.Intersects(top)
+	if math.Abs(rec.V-1) > 1e-9 {
+		t.Error("Top pole should have V of 1", rec.V)
+	}
+}
@@ -0,0 +1,224 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/mat"
+	"github.com/agdt3/goray/vec"
+)
+
+// Instance places a shared object in the scene with its own transform and
+// material. Many instances can reference the same Shape (and therefore the
+// same acceleration structure) without copying any geometry. Instances of
+// emissive shapes give off their light, and can be used as a Light
+type Instance struct {
+	ID              string
+	Shape           Object
+	Transform       *mat.Matrix4
+	Col             color.RGBA
+	RefractiveIndex float64
+	inverse         *mat.Matrix4
+	bounds          AABB
+	passThrough     bool // hits keep the object the shape reports
+}
+
+// NewInstance is a constructor for Instances. The transform maps the
+// shape's object space into world space and must be invertible. The color
+// and refractive index override the material of the shared shape
+func NewInstance(id string, shape Object, transform *mat.Matrix4, col color.RGBA, refractive float64) (*Instance, error) {
+	inverse, err := transform.Inverse()
+	if err != nil {
+		return nil, err
+	}
+
+	i := new(Instance)
+	i.ID = id
+	i.Shape = shape
+	i.Transform = transform
+	i.Col = col
+	i.RefractiveIndex = refractive
+	i.inverse = inverse
+
+	// World space bounds enclose the transformed corners of the
+	// object space bounds
+	i.bounds = EmptyAABB()
+	for _, c := range shape.Bounds().Corners() {
+		i.bounds = i.bounds.Extend(mat.TransformPoint(transform, c))
+	}
+	return i, nil
+}
+
+// NewTransformed wraps an object with a transform that scales it, rotates
+// it by angle radians around axis and then translates it. Unlike
+// NewInstance the object keeps its own ID and material: hits refer to
+// whatever object the shape reports, such as the leaf of a BVH or a point
+// of a PointCloud with per point colors
+func NewTransformed(shape Object, translation, axis vec.Vec3, angle float64, scale vec.Vec3) (*Instance, error) {
+	transform := mat.NewTRS4(translation, axis, angle, scale)
+	i, err := NewInstance(shape.GetID(), shape, transform, shape.GetColor(), shape.GetRefractiveIndex())
+	if err != nil {
+		return nil, err
+	}
+	i.passThrough = true
+	return i, nil
+}
+
+// GetID is the object specific method to return the ID of the instance
+func (i *Instance) GetID() string {
+	return i.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (i *Instance) GetColor() color.RGBA {
+	return i.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (i *Instance) GetRefractiveIndex() float64 {
+	return i.RefractiveIndex
+}
+
+// Bounds returns the world space bounding box of the instance
+func (i *Instance) Bounds() AABB {
+	return i.bounds
+}
+
+// Intersects transforms the ray into object space, intersects the shared
+// shape and transforms the result back into world space. The record
+// refers to the instance, so its material override is used, except for
+// objects made by NewTransformed
+func (i *Instance) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	dir := ray.Direction
+	dir.Normalize()
+
+	local := cam.Ray{
+		ID:        ray.ID,
+		Type:      ray.Type,
+		Origin:    mat.TransformPoint(i.inverse, ray.Origin),
+		Direction: mat.TransformDirection(i.inverse, dir)}
+
+	// Shapes measure distance along their normalized direction, so
+	// distances are rescaled by the length of the object space direction
+	scale := local.Direction.Magnitude
+	if scale == 0 {
+		return FalseObject()
+	}
+
+	rec, isHit := i.Shape.Intersects(&local)
+	if !isHit {
+		return FalseObject()
+	}
+
+	rec.T0 /= scale
+	rec.T1 /= scale
+	rec.Error = mat.TransformPointError(i.Transform, rec.Point, rec.Error)
+	rec.Point = mat.TransformPoint(i.Transform, rec.Point)
+	rec.Normal = i.transformNormal(rec.Normal)
+	rec.GeometricNormal = i.transformNormal(rec.GeometricNormal)
+	rec.Tangent, rec.Bitangent = alignTangentFrame(rec.Normal, mat.TransformDirection(i.Transform, rec.Tangent))
+	rec.FrontFace = vec.Dot(dir, rec.GeometricNormal) < 0
+	if !i.passThrough {
+		rec.Object = i
+	}
+	return rec, true
+}
+
+// transformNormal moves an object space normal into world space
+func (i *Instance) transformNormal(n vec.Vec3) vec.Vec3 {
+	n = mat.TransformNormal(i.inverse, n)
+	return vec.Divide(n, n.Magnitude)
+}
+
+// Emission returns the radiance given off by the shape when it is an
+// Emitter, so emissive shapes glow under a material override too
+func (i *Instance) Emission() [3]float64 {
+	if e, ok := i.Shape.(Emitter); ok {
+		return e.Emission()
+	}
+	return [3]float64{}
+}
+
+// NumSamples returns the samples of the shape when it is a Light, and 0
+// otherwise
+func (i *Instance) NumSamples() int {
+	if l, ok := i.Shape.(Light); ok {
+		return l.NumSamples()
+	}
+	return 0
+}
+
+// Sample samples the shape in object space, when it is a Light, and moves
+// the sample into world space
+func (i *Instance) Sample(p vec.Vec3, u, v float64) (LightSample, bool) {
+	light, ok := i.Shape.(Light)
+	if !ok {
+		return LightSample{}, false
+	}
+	local, ok := light.Sample(mat.TransformPoint(i.inverse, p), u, v)
+	if !ok {
+		return LightSample{}, false
+	}
+
+	if math.IsInf(local.Distance, 1) {
+		dir := mat.TransformDirection(i.Transform, local.Direction)
+		return LightSample{vec.Divide(dir, dir.Magnitude), local.Distance, vec.Vec3{}, vec.Vec3{}}, true
+	}
+	q := mat.TransformPoint(i.Transform, local.Point)
+	toLight := vec.Subtract(q, p)
+	if toLight.Magnitude == 0 {
+		return LightSample{}, false
+	}
+	var n vec.Vec3
+	if local.Normal.Magnitude > 0 {
+		n = i.transformNormal(local.Normal)
+	}
+	return LightSample{vec.Divide(toLight, toLight.Magnitude), toLight.Magnitude, q, n}, true
+}
+
+// Evaluate moves the sample back into object space, evaluates the shape
+// there and corrects the result for the transform: for the change in
+// distance, and for samples on a surface, for the change in area and in
+// the cosine at the sampled point
+func (i *Instance) Evaluate(p vec.Vec3, s LightSample) [3]float64 {
+	light, ok := i.Shape.(Light)
+	if !ok {
+		return [3]float64{}
+	}
+	lp := mat.TransformPoint(i.inverse, p)
+	if math.IsInf(s.Distance, 1) {
+		dir := mat.TransformDirection(i.inverse, s.Direction)
+		return light.Evaluate(lp, LightSample{vec.Divide(dir, dir.Magnitude), s.Distance, vec.Vec3{}, vec.Vec3{}})
+	}
+
+	lq := mat.TransformPoint(i.inverse, s.Point)
+	toLight := vec.Subtract(lq, lp)
+	if toLight.Magnitude == 0 {
+		return [3]float64{}
+	}
+	local := LightSample{vec.Divide(toLight, toLight.Magnitude), toLight.Magnitude, lq, vec.Vec3{}}
+	ratio := (local.Distance * local.Distance) / (s.Distance * s.Distance)
+
+	if s.Normal.Magnitude > 0 {
+		n := mat.TransformNormal(i.Transform, s.Normal)
+		local.Normal = vec.Divide(n, n.Magnitude)
+		cosLocal := math.Abs(vec.Dot(local.Normal, local.Direction))
+		if cosLocal == 0 {
+			return [3]float64{}
+		}
+		// A patch of the local surface is stretched by the transform by
+		// the area spanned by its transformed tangents
+		t, b := tangentFrame(local.Normal)
+		stretch := vec.Cross(mat.TransformDirection(i.Transform, t), mat.TransformDirection(i.Transform, b)).Magnitude
+		ratio *= stretch * math.Abs(vec.Dot(s.Normal, s.Direction)) / cosLocal
+	}
+
+	radiance := light.Evaluate(lp, local)
+	for c := range radiance {
+		radiance[c] *= ratio
+	}
+	return radiance
+}
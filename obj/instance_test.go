@@ -0,0 +1,154 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/mat"
+	"github.com/agdt3/goray/vec"
+)
+
+func makeQuad() []Object {
+	v0 := vec.NewVec3(-1, -1, 0)
+	v1 := vec.NewVec3(1, -1, 0)
+	v2 := vec.NewVec3(1, 1, 0)
+	v3 := vec.NewVec3(-1, 1, 0)
+	col := color.RGBA{255, 0, 0, 1}
+	return []Object{
+		NewTriangle("tri1", *v0, *v1, *v2, col, 1, false),
+		NewTriangle("tri2", *v0, *v2, *v3, col, 1, false)}
+}
+
+func TestBVHIntersects(t *testing.T) {
+	t.Parallel()
+
+	objects := make([]Object, 0)
+	for i := 0; i < 10; i++ {
+		center := vec.NewVec3(float64(i)*3, 0, -5)
+		objects = append(objects, Sphere{"sphere", *center, 1, color.RGBA{0, 0, 255, 1}, 1})
+	}
+	bvh := NewBVH("bvh", objects)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(9, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := bvh.Intersects(ray)
+
+	if !is_hit || rec.T0 != 4 {
+		t.Error("Ray should hit the fourth sphere")
+	}
+
+	if !vec.IsEqual(rec.Point, *vec.NewVec3(9, 0, -4)) {
+		t.Error("Hit location was not correct")
+	}
+
+	miss := cam.NewRay(0, "camera", vec.NewVec3(1.5, 0, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := bvh.Intersects(miss); is_hit {
+		t.Error("Ray between spheres should not hit")
+	}
+}
+
+func TestInstanceIntersects(t *testing.T) {
+	t.Parallel()
+
+	mesh := NewBVH("quad", makeQuad())
+	transform := mat.Multiply4(mat.NewTranslation4(5, 0, -4), mat.NewScale4(2, 2, 2))
+	inst, err := NewInstance("inst1", mesh, transform, color.RGBA{0, 255, 0, 1}, 1.5)
+	if err != nil {
+		t.Error("Instance should be created")
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(6.5, 1.5, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := inst.Intersects(ray)
+	hit, n, t0 := rec.Point, rec.Normal, rec.T0
+
+	if !is_hit {
+		t.Error("Scaled instance was not hit")
+	}
+
+	if math.Abs(t0-4) > 0.0001 || math.Abs(hit.Z+4) > 0.0001 {
+		t.Error("Distance should be measured in world space")
+	}
+
+	if !vec.IsEqual(n, *vec.NewVec3(0, 0, 1)) {
+		t.Error("N vector not correct")
+	}
+
+	if inst.GetColor() != (color.RGBA{0, 255, 0, 1}) || inst.GetRefractiveIndex() != 1.5 {
+		t.Error("Instance material should override the shared mesh")
+	}
+
+	outside := cam.NewRay(0, "camera", vec.NewVec3(1.5, 0, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := inst.Intersects(outside); is_hit {
+		t.Error("Ray outside of the transformed mesh should not hit")
+	}
+}
+
+func TestInstanceBounds(t *testing.T) {
+	t.Parallel()
+
+	mesh := NewBVH("quad", makeQuad())
+	inst, _ := NewInstance("inst1", mesh, mat.NewTranslation4(0, 10, 0), color.RGBA{}, 1)
+	b := inst.Bounds()
+
+	if b.Min.Y != 9 || b.Max.Y != 11 {
+		t.Error("Instance bounds were not transformed")
+	}
+}
+
+func TestTransformedSphere(t *testing.T) {
+	t.Parallel()
+
+	// A unit sphere stretched along y then turned onto its side is an
+	// ellipsoid with a semi-axis of 2 along x
+	sphere := Sphere{"sphere", *vec.NewVec3(0, 0, 0), 1, color.RGBA{0, 0, 255, 1}, 1.3}
+	ellipsoid, err := NewTransformed(sphere, *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), math.Pi/2, *vec.NewVec3(1, 2, 1))
+	if err != nil {
+		t.Fatal("Transform should be invertible")
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(1.5, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := ellipsoid.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Ellipsoid was not hit")
+	}
+
+	dz := math.Sqrt(1 - 0.75*0.75)
+	if math.Abs(rec.T0-(5-dz)) > 1e-9 {
+		t.Error("Hit distance not correct", rec.T0)
+	}
+
+	// The normal is the gradient of (x/2)^2 + y^2 + z^2
+	expected := *vec.NewVec3(1.5/4, 0, dz)
+	expected = vec.Divide(expected, expected.Magnitude)
+	if vec.Subtract(rec.Normal, expected).Magnitude > 1e-9 {
+		t.Error("N vector not correct", rec.Normal, expected)
+	}
+
+	if ellipsoid.GetID() != "sphere" || ellipsoid.GetColor() != sphere.Col || ellipsoid.GetRefractiveIndex() != 1.3 {
+		t.Error("Transformed object should keep its own material")
+	}
+
+	if _, err := NewTransformed(sphere, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 1), 0, *vec.NewVec3(1, 0, 1)); err == nil {
+		t.Error("Flattening the object should fail")
+	}
+}
+
+func TestTransformedKeepsHitObject(t *testing.T) {
+	t.Parallel()
+
+	red := Sphere{"red", *vec.NewVec3(-2, 0, 0), 1, color.RGBA{255, 0, 0, 1}, 1}
+	blue := Sphere{"blue", *vec.NewVec3(2, 0, 0), 1, color.RGBA{0, 0, 255, 1}, 1}
+	group := NewBVH("group", []Object{red, blue})
+	moved, err := NewTransformed(group, *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), 0, *vec.NewVec3(1, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each hit keeps the sphere of the group it landed on
+	ray := cam.NewRay(0, "camera", vec.NewVec3(2, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, isHit := moved.Intersects(ray)
+	if !isHit || rec.Object.GetID() != "blue" || rec.Object.GetColor() != blue.Col {
+		t.Error("Transformed group should keep the material of the sphere hit", rec.Object)
+	}
+}
@@ -0,0 +1,293 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// LightSample is a direction from a shaded point toward a point on a
+// light
+type LightSample struct {
+	Direction vec.Vec3 // normalized, toward the light
+	Distance  float64  // to the light, +Inf for directional lights
+	Point     vec.Vec3 // on the light
+	Normal    vec.Vec3 // of the light at Point, zero for point-like lights
+}
+
+// Light is a source of light for shading. A light is sampled NumSamples
+// times per shaded point: Sample picks a point on the light from u, v
+// running from 0 to 1, and Evaluate returns the red, green and blue light
+// arriving at p from that sample, ignoring anything in between. The
+// average over the samples is the light arriving from the whole light,
+// as if it came from a point light in the sampled direction
+type Light interface {
+	GetID() string
+	NumSamples() int
+	Sample(p vec.Vec3, u, v float64) (LightSample, bool)
+	Evaluate(p vec.Vec3, s LightSample) [3]float64
+}
+
+// pointSample returns the sample toward a point-like light at position
+func pointSample(p, position vec.Vec3) (LightSample, bool) {
+	toLight := vec.Subtract(position, p)
+	dist := toLight.Magnitude
+	if dist == 0 {
+		return LightSample{}, false
+	}
+	return LightSample{vec.Divide(toLight, dist), dist, position, vec.Vec3{}}, true
+}
+
+// inverseSquare returns intensity fallen off over the distance of s
+func inverseSquare(intensity [3]float64, s LightSample) [3]float64 {
+	var radiance [3]float64
+	for c := range radiance {
+		radiance[c] = intensity[c] / (s.Distance * s.Distance)
+	}
+	return radiance
+}
+
+// PointLight gives off Intensity in red, green and blue evenly in every
+// direction from Position, falling off with the square of the distance
+type PointLight struct {
+	ID        string
+	Position  vec.Vec3
+	Intensity [3]float64
+}
+
+// NewPointLight is a constructor for PointLight
+func NewPointLight(id string, position vec.Vec3, intensity [3]float64) *PointLight {
+	l := new(PointLight)
+	l.ID = id
+	l.Position = position
+	l.Intensity = intensity
+	return l
+}
+
+// GetID returns the ID of the light
+func (l *PointLight) GetID() string {
+	return l.ID
+}
+
+// NumSamples returns 1, as a point light is the same from every sample
+func (l *PointLight) NumSamples() int {
+	return 1
+}
+
+// Sample returns the direction from p to the light
+func (l *PointLight) Sample(p vec.Vec3, u, v float64) (LightSample, bool) {
+	return pointSample(p, l.Position)
+}
+
+// Evaluate returns the intensity fallen off over the distance to p
+func (l *PointLight) Evaluate(p vec.Vec3, s LightSample) [3]float64 {
+	return inverseSquare(l.Intensity, s)
+}
+
+// DirectionalLight is a light so far away, like the sun, that it shines
+// along Direction everywhere with the same Irradiance
+type DirectionalLight struct {
+	ID         string
+	Direction  vec.Vec3 // normalized, the way the light travels
+	Irradiance [3]float64
+}
+
+// NewDirectionalLight is a constructor for DirectionalLight
+func NewDirectionalLight(id string, direction vec.Vec3, irradiance [3]float64) *DirectionalLight {
+	l := new(DirectionalLight)
+	l.ID = id
+	l.Direction = vec.Divide(direction, direction.Magnitude)
+	l.Irradiance = irradiance
+	return l
+}
+
+// GetID returns the ID of the light
+func (l *DirectionalLight) GetID() string {
+	return l.ID
+}
+
+// NumSamples returns 1, as the light arrives from a single direction
+func (l *DirectionalLight) NumSamples() int {
+	return 1
+}
+
+// Sample returns the direction back toward the light, which is infinitely
+// far away
+func (l *DirectionalLight) Sample(p vec.Vec3, u, v float64) (LightSample, bool) {
+	return LightSample{vec.Invert(l.Direction), math.Inf(1), vec.Vec3{}, vec.Vec3{}}, true
+}
+
+// Evaluate returns the irradiance, which is the same everywhere
+func (l *DirectionalLight) Evaluate(p vec.Vec3, s LightSample) [3]float64 {
+	return l.Irradiance
+}
+
+// SpotLight is a point light at Position that only shines within a cone
+// of half angle Angle around Direction. Over the outer Falloff of that
+// angle the light fades smoothly to nothing at the edge of the cone.
+// Angles are in radians
+type SpotLight struct {
+	ID        string
+	Position  vec.Vec3
+	Direction vec.Vec3 // normalized, the axis of the cone
+	Intensity [3]float64
+	Angle     float64
+	Falloff   float64
+}
+
+// NewSpotLight is a constructor for SpotLight
+func NewSpotLight(id string, position, direction vec.Vec3, intensity [3]float64, angle, falloff float64) *SpotLight {
+	l := new(SpotLight)
+	l.ID = id
+	l.Position = position
+	l.Direction = vec.Divide(direction, direction.Magnitude)
+	l.Intensity = intensity
+	l.Angle = angle
+	l.Falloff = falloff
+	return l
+}
+
+// GetID returns the ID of the light
+func (l *SpotLight) GetID() string {
+	return l.ID
+}
+
+// NumSamples returns 1, as a spot light is the same from every sample
+func (l *SpotLight) NumSamples() int {
+	return 1
+}
+
+// Sample returns the direction from p to the light. Points outside the
+// cone get no light
+func (l *SpotLight) Sample(p vec.Vec3, u, v float64) (LightSample, bool) {
+	s, ok := pointSample(p, l.Position)
+	if !ok || l.Cone(vec.Invert(s.Direction)) == 0 {
+		return s, false
+	}
+	return s, true
+}
+
+// Cone returns the fraction of the intensity sent along the normalized
+// direction dir, from 1 well inside the cone to 0 outside it
+func (l *SpotLight) Cone(dir vec.Vec3) float64 {
+	cos := vec.Dot(dir, l.Direction)
+	cosOuter := math.Cos(l.Angle)
+	cosInner := math.Cos(math.Max(0, l.Angle-l.Falloff))
+	if cos <= cosOuter {
+		return 0
+	}
+	if cos >= cosInner {
+		return 1
+	}
+	x := (cos - cosOuter) / (cosInner - cosOuter)
+	return x * x * (3 - 2*x)
+}
+
+// Evaluate returns the intensity sent toward p, fallen off over the
+// distance to it
+func (l *SpotLight) Evaluate(p vec.Vec3, s LightSample) [3]float64 {
+	radiance := inverseSquare(l.Intensity, s)
+	cone := l.Cone(vec.Invert(s.Direction))
+	for c := range radiance {
+		radiance[c] *= cone
+	}
+	return radiance
+}
+
+// SphereLight is a basic spherical light that rays can hit, as in older
+// scenes. For shading it acts as a point light at its center whose Col
+// does not fall off with distance. Emissive spheres are the physical
+// equivalent
+type SphereLight struct {
+	ID           string
+	Center       vec.Vec3
+	Radius       float64
+	RadiusSquare float64
+	Col          color.RGBA
+}
+
+// Intersects checks for intersections between cam.Ray and Sphere-like
+// light, using the geometric method
+func (l *SphereLight) Intersects(ray *cam.Ray) (bool, float64) {
+	rd := ray.Direction
+	rd.Normalize()
+
+	oc := vec.Subtract(l.Center, ray.Origin)
+	l2oc := vec.Dot(oc, oc)
+	tCa := vec.Dot(oc, rd)
+
+	//sphere located behind ray origin
+	if tCa < 0 {
+		return false, 0
+	}
+
+	d2 := l2oc - (tCa * tCa)
+
+	// if the distance between the closest point to the sphere center on
+	// the projected ray is greater than the radius, then the projected
+	// ray is definitely outside the bounds of the sphere
+	if d2 > l.RadiusSquare {
+		return false, 0
+	}
+
+	t2hc := l.RadiusSquare - d2
+
+	if t2hc < 0 {
+		return false, 0
+	}
+
+	thc := math.Sqrt(t2hc)
+	t0 := tCa - thc
+	t1 := tCa + thc
+
+	// Sphere is behind the point of origin
+	if t0 < 0 && t1 < 0 {
+		return false, 0
+	} else if t0 <= 0 && t1 > 0 {
+		// Point of origin is inside the sphere or on/inside the surface
+		t0 = t1
+	}
+
+	// Swap if reversed
+	if t0 > t1 {
+		tmp := t0
+		t0 = t1
+		t1 = tmp
+	}
+
+	return true, t0
+}
+
+// NewSphereLight is a constructor for SphereLight
+func NewSphereLight(id string, center vec.Vec3, radius float64, col color.RGBA) *SphereLight {
+	l := new(SphereLight)
+	l.ID = id
+	l.Center = center
+	l.Radius = radius
+	l.RadiusSquare = radius * radius
+	l.Col = col
+	return l
+}
+
+// GetID returns the ID of the light
+func (l *SphereLight) GetID() string {
+	return l.ID
+}
+
+// NumSamples returns 1, as the light is taken to come from its center
+func (l *SphereLight) NumSamples() int {
+	return 1
+}
+
+// Sample returns the direction from p to the center of the light
+func (l *SphereLight) Sample(p vec.Vec3, u, v float64) (LightSample, bool) {
+	return pointSample(p, l.Center)
+}
+
+// Evaluate returns the color of the light in red, green and blue from 0
+// to 1, whatever the distance
+func (l *SphereLight) Evaluate(p vec.Vec3, s LightSample) [3]float64 {
+	return [3]float64{float64(l.Col.R) / 255, float64(l.Col.G) / 255, float64(l.Col.B) / 255}
+}
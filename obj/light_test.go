@@ -0,0 +1,79 @@
+package obj
+
+import (
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/vec"
+)
+
+func TestPointLight(t *testing.T) {
+	t.Parallel()
+
+	light := NewPointLight("light1", *vec.NewVec3(0, 2, 0), [3]float64{8, 4, 0})
+	p := *vec.NewVec3(0, 0, 0)
+	s, ok := light.Sample(p, 0.5, 0.5)
+	if !ok || s.Direction != *vec.NewVec3(0, 1, 0) || s.Distance != 2 {
+		t.Fatal("Point light sample not correct", s)
+	}
+	if radiance := light.Evaluate(p, s); radiance != [3]float64{2, 1, 0} {
+		t.Error("Point light should fall off with the square of the distance", radiance)
+	}
+	if _, ok := light.Sample(light.Position, 0.5, 0.5); ok {
+		t.Error("Point light has no direction from its own position")
+	}
+}
+
+func TestDirectionalLight(t *testing.T) {
+	t.Parallel()
+
+	light := NewDirectionalLight("sun1", *vec.NewVec3(0, -2, 0), [3]float64{1, 1, 1})
+	for _, p := range []vec.Vec3{*vec.NewVec3(0, 0, 0), *vec.NewVec3(100, -50, 3)} {
+		s, ok := light.Sample(p, 0.5, 0.5)
+		if !ok || s.Direction != *vec.NewVec3(0, 1, 0) || !math.IsInf(s.Distance, 1) {
+			t.Error("Directional light should be infinitely far back along its direction", s)
+		}
+		if light.Evaluate(p, s) != light.Irradiance {
+			t.Error("Directional light should not fall off")
+		}
+	}
+}
+
+func TestSpotLight(t *testing.T) {
+	t.Parallel()
+
+	// A cone of 30 degrees that fades over its outer 10. A cone of -1
+	// means somewhere in between
+	light := NewSpotLight("spot1", *vec.NewVec3(0, 1, 0), *vec.NewVec3(0, -1, 0), [3]float64{1, 1, 1}, math.Pi/6, math.Pi/18)
+
+	var tests = []struct {
+		angle float64
+		cone  float64
+	}{
+		{0, 1},
+		{math.Pi / 9, 1},
+		{math.Pi / 7.2, -1},
+		{math.Pi / 5, 0},
+	}
+	for _, test := range tests {
+		p := *vec.NewVec3(math.Tan(test.angle), 0, 0)
+		s, ok := light.Sample(p, 0.5, 0.5)
+		if ok != (test.cone != 0) {
+			t.Error("Spot light should only be sampled inside its cone", test.angle)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		radiance := light.Evaluate(p, s)
+		want := test.cone / (s.Distance * s.Distance)
+		if test.cone < 0 {
+			full := 1 / (s.Distance * s.Distance)
+			if radiance[0] <= 0 || radiance[0] >= full {
+				t.Error("Spot light should fade at the edge of its cone", test.angle, radiance[0])
+			}
+		} else if math.Abs(radiance[0]-want) > 1e-12 {
+			t.Error("Spot light cone not correct", test.angle, radiance[0], want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Medium is a homogeneous participating medium such as fog, smoke or the
+// inside of tinted glass. Absorption and Scattering are the fraction of
+// red, green and blue light absorbed and scattered per unit distance, and
+// G is the asymmetry of the Henyey-Greenstein phase function, from -1 for
+// scattering straight back through 0 for scattering evenly to 1 for
+// scattering straight on
+type Medium struct {
+	Absorption [3]float64
+	Scattering [3]float64
+	G          float64
+	Steps      int // samples used to integrate in-scattered light
+}
+
+// NewMedium is a constructor for Medium with default integration settings
+func NewMedium(absorption, scattering [3]float64, g float64) *Medium {
+	m := new(Medium)
+	m.Absorption = absorption
+	m.Scattering = scattering
+	m.G = g
+	m.Steps = 16
+	return m
+}
+
+// Extinction returns the fraction of light lost per unit distance, by
+// absorption or by scattering out of the ray
+func (m *Medium) Extinction() [3]float64 {
+	var e [3]float64
+	for c := range e {
+		e[c] = m.Absorption[c] + m.Scattering[c]
+	}
+	return e
+}
+
+// Transmittance returns the fraction of light that makes it through dist
+// of the medium by the Beer-Lambert law
+func (m *Medium) Transmittance(dist float64) [3]float64 {
+	e := m.Extinction()
+	var t [3]float64
+	for c := range t {
+		t[c] = math.Exp(-e[c] * dist)
+	}
+	return t
+}
+
+// Range returns how far light gets into the medium before every channel
+// is attenuated below 1e-4, or +Inf if the medium is clear
+func (m *Medium) Range() float64 {
+	e := m.Extinction()
+	lowest := math.Min(e[0], math.Min(e[1], e[2]))
+	if lowest <= 0 {
+		return math.Inf(1)
+	}
+	return -math.Log(1e-4) / lowest
+}
+
+// HenyeyGreenstein returns the phase function with asymmetry g for light
+// turned through an angle with cosine cosTheta. It integrates to 1 over
+// the sphere
+func HenyeyGreenstein(cosTheta, g float64) float64 {
+	denom := 1 + g*g - 2*g*cosTheta
+	return (1 - g*g) / (4 * math.Pi * denom * math.Sqrt(denom))
+}
+
+// Phase returns the fraction of light from the direction toLight that is
+// scattered into a ray travelling along dir, toward where the ray came
+// from. Both directions must be normalized
+func (m *Medium) Phase(dir, toLight vec.Vec3) float64 {
+	return HenyeyGreenstein(vec.Dot(dir, toLight), m.G)
+}
+
+// IncidentFunc returns the normalized direction to a light from p and
+// the red, green and blue radiance arriving from it, which is 0 when the
+// light is hidden. parent is the ray the light is gathered for, so the
+// shadow ray can be linked to it
+type IncidentFunc func(p vec.Vec3, parent *cam.Ray) (vec.Vec3, [3]float64)
+
+// InScatter integrates the light scattered into the ray toward its origin
+// between t0 and t1, attenuated back to t0. incident is sampled at the
+// midpoint of Steps equal stretches for the parent ray. Stretches beyond
+// Range contribute nothing and are skipped, so the medium may extend to
+// infinity
+func (m *Medium) InScatter(org, dir vec.Vec3, t0, t1 float64, parent *cam.Ray, incident IncidentFunc) [3]float64 {
+	var sum [3]float64
+	t1 = math.Min(t1, t0+m.Range())
+	if t1 <= t0 || m.Steps < 1 {
+		return sum
+	}
+
+	dt := (t1 - t0) / float64(m.Steps)
+	for i := 0; i < m.Steps; i++ {
+		t := t0 + (float64(i)+0.5)*dt
+		p := vec.Add(org, vec.Multiply(dir, t))
+		toLight, radiance := incident(p, parent)
+		if radiance == [3]float64{} {
+			continue
+		}
+		phase := m.Phase(dir, toLight)
+		transmittance := m.Transmittance(t - t0)
+		for c := range sum {
+			sum[c] += transmittance[c] * m.Scattering[c] * phase * radiance[c] * dt
+		}
+	}
+	return sum
+}
+
+// Volume fills the inside of a closed Boundary object with a medium. A
+// Volume is not itself an Object; the boundary is only added to the
+// scene if its surface should be seen, as for glass
+type Volume struct {
+	ID       string
+	Boundary Object
+	Medium   *Medium
+}
+
+// NewVolume is a constructor for Volume
+func NewVolume(id string, boundary Object, medium *Medium) *Volume {
+	v := new(Volume)
+	v.ID = id
+	v.Boundary = boundary
+	v.Medium = medium
+	return v
+}
+
+// Segments returns the stretches of the ray inside the volume between 0
+// and maxDist, as pairs of distances in ascending order
+func (v *Volume) Segments(ray *cam.Ray, maxDist float64) [][2]float64 {
+	segments := make([][2]float64, 0, 1)
+	for _, i := range SolidIntervals(v.Boundary, ray) {
+		t0 := math.Max(0, i.Enter.T0)
+		t1 := math.Min(maxDist, i.Exit.T0)
+		if t1 > t0 {
+			segments = append(segments, [2]float64{t0, t1})
+		}
+	}
+	return segments
+}
@@ -0,0 +1,85 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestHenyeyGreenstein(t *testing.T) {
+	t.Parallel()
+
+	// The phase function integrates to 1 over the sphere for any g
+	for _, g := range []float64{-0.7, 0, 0.3, 0.9} {
+		sum := 0.0
+		n := 20000
+		for i := 0; i < n; i++ {
+			cosTheta := -1 + (float64(i)+0.5)*2/float64(n)
+			sum += HenyeyGreenstein(cosTheta, g) * 2 * math.Pi * 2 / float64(n)
+		}
+		if math.Abs(sum-1) > 1e-3 {
+			t.Error("Phase function not normalized for g", g, sum)
+		}
+	}
+
+	if HenyeyGreenstein(1, 0.5) <= HenyeyGreenstein(-1, 0.5) {
+		t.Error("Positive g should scatter forward")
+	}
+}
+
+func TestMediumInScatter(t *testing.T) {
+	t.Parallel()
+
+	m := NewMedium([3]float64{0.1, 0.2, 0.3}, [3]float64{0.4, 0.4, 0.4}, 0)
+	m.Steps = 1000
+
+	transmittance := m.Transmittance(2)
+	if math.Abs(transmittance[0]-math.Exp(-1)) > 1e-12 || math.Abs(transmittance[2]-math.Exp(-1.4)) > 1e-12 {
+		t.Error("Transmittance not correct", transmittance)
+	}
+
+	// Under constant light from everywhere the scattered light has a
+	// closed form
+	incident := func(p vec.Vec3, parent *cam.Ray) (vec.Vec3, [3]float64) {
+		return *vec.NewVec3(0, 1, 0), [3]float64{1, 1, 1}
+	}
+	scattered := m.InScatter(*vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, -1), 1, 3, nil, incident)
+	e := m.Extinction()
+	for c := range scattered {
+		expected := m.Scattering[c] / e[c] * (1 - math.Exp(-e[c]*2)) / (4 * math.Pi)
+		if math.Abs(scattered[c]-expected) > 1e-6 {
+			t.Error("In-scattered light not correct", c, scattered[c], expected)
+		}
+	}
+
+	// A medium with infinite extent is only integrated as far as light
+	// gets through it
+	infinite := m.InScatter(*vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, -1), 0, math.Inf(1), nil, incident)
+	if math.IsNaN(infinite[0]) || math.Abs(infinite[0]-0.8/(4*math.Pi)) > 1e-4 {
+		t.Error("Infinite medium not integrated correctly", infinite)
+	}
+}
+
+func TestVolumeSegments(t *testing.T) {
+	t.Parallel()
+
+	boundary := Sphere{"smoke", *vec.NewVec3(0, 0, -5), 1, color.RGBA{0, 0, 0, 1}, 1}
+	volume := NewVolume("smoke", boundary, NewMedium([3]float64{1, 1, 1}, [3]float64{0, 0, 0}, 0))
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	segments := volume.Segments(ray, 100)
+	if len(segments) != 1 || math.Abs(segments[0][0]-4) > 1e-9 || math.Abs(segments[0][1]-6) > 1e-9 {
+		t.Error("Segments not correct", segments)
+	}
+
+	// Segments are cut off at the closest surface, and start at the
+	// origin of rays inside the volume
+	inside := cam.NewRay(0, "camera", vec.NewVec3(0, 0, -5), vec.NewVec3(0, 0, -1))
+	segments = volume.Segments(inside, 0.5)
+	if len(segments) != 1 || segments[0] != [2]float64{0, 0.5} {
+		t.Error("Segments from inside not correct", segments)
+	}
+}
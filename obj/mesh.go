@@ -0,0 +1,433 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Triangulate returns the vertex indecies of the mesh as a flat list of
+// triangles, three indecies per triangle. Polygon faces are split by ear
+// clipping, so concave faces are triangulated correctly. Faces read from
+// .mesh files are already split into triangles, which is detected from
+// the length of VertexIndecies
+func (p *PolygonMesh) Triangulate() []int {
+	corners := p.triangleCorners()
+	indecies := make([]int, len(corners), len(corners))
+	for i, c := range corners {
+		indecies[i] = p.VertexIndecies[c]
+	}
+	return indecies
+}
+
+// TriangulateNormals returns the normal indecies matching the triangles
+// of Triangulate, or nil when the faces do not all have vertex normals
+func (p *PolygonMesh) TriangulateNormals() []int {
+	return p.triangulateAttribute(p.NormalIndecies, len(p.VertexNormals)/3)
+}
+
+// TriangulateTextures returns the texture vertex indecies matching the
+// triangles of Triangulate, or nil when the faces do not all have texture
+// verticies
+func (p *PolygonMesh) TriangulateTextures() []int {
+	return p.triangulateAttribute(p.TextureIndecies, len(p.TextureVertecies)/2)
+}
+
+// triangulateAttribute triangulates per-vertex attribute indecies, which
+// must be valid for every vertex of every face
+func (p *PolygonMesh) triangulateAttribute(attributes []int, numValues int) []int {
+	if len(attributes) != len(p.VertexIndecies) || numValues == 0 {
+		return nil
+	}
+
+	corners := p.triangleCorners()
+	indecies := make([]int, len(corners), len(corners))
+	for i, c := range corners {
+		a := attributes[c]
+		if a < 0 || a >= numValues {
+			return nil
+		}
+		indecies[i] = a
+	}
+	return indecies
+}
+
+// triangleCorners splits the faces into triangles and returns, for each
+// triangle corner, its position in VertexIndecies. Per-corner attributes
+// such as normal indecies are triangulated through it
+func (p *PolygonMesh) triangleCorners() []int {
+	faces := p.faceTriangles()
+	total := 0
+	for _, corners := range faces {
+		total += len(corners)
+	}
+
+	corners := make([]int, 0, total)
+	for _, c := range faces {
+		corners = append(corners, c...)
+	}
+	return corners
+}
+
+// faceTriangles returns the triangle corners of each face separately, as
+// positions in VertexIndecies. Faces that are already split into
+// triangles keep their triangles; others are split by ear clipping. Faces
+// with fewer than three verticies, or with a vertex index outside of
+// Verticies, are skipped
+func (p *PolygonMesh) faceTriangles() [][]int {
+	numVerticies := len(p.Verticies) / 3
+	valid := func(start, n int) bool {
+		for _, v := range p.VertexIndecies[start : start+n] {
+			if v < 0 || v >= numVerticies {
+				return false
+			}
+		}
+		return true
+	}
+
+	totalVerticies := 0
+	totalTriangles := 0
+	for _, v := range p.NumVerticies {
+		totalVerticies += v
+		if v > 2 {
+			totalTriangles += (v - 2)
+		}
+	}
+
+	faces := make([][]int, 0, len(p.NumVerticies))
+	faceStart := 0
+	if len(p.VertexIndecies) != totalVerticies && len(p.VertexIndecies) == totalTriangles*3 {
+		for _, n := range p.NumVerticies {
+			if n < 3 {
+				continue
+			}
+			corners := make([]int, (n-2)*3, (n-2)*3)
+			for i := range corners {
+				corners[i] = faceStart + i
+			}
+			if valid(faceStart, len(corners)) {
+				faces = append(faces, corners)
+			}
+			faceStart += len(corners)
+		}
+		return faces
+	}
+
+	for _, n := range p.NumVerticies {
+		if n < 3 || faceStart+n > len(p.VertexIndecies) || !valid(faceStart, n) {
+			faceStart += int(math.Max(0, float64(n)))
+			continue
+		}
+		points := make([]vec.Vec3, n, n)
+		for k := range points {
+			v := p.VertexIndecies[faceStart+k] * 3
+			points[k] = *vec.NewVec3(p.Verticies[v], p.Verticies[v+1], p.Verticies[v+2])
+		}
+		corners := earClip(points)
+		for i := range corners {
+			corners[i] += faceStart
+		}
+		faces = append(faces, corners)
+		faceStart += n
+	}
+	return faces
+}
+
+// polygonNormal returns Newell's normal of a polygon, whose length is
+// twice its area. It is well defined for concave and non-planar polygons
+func polygonNormal(points []vec.Vec3) vec.Vec3 {
+	var x, y, z float64
+	for k := range points {
+		a := points[k]
+		b := points[(k+1)%len(points)]
+		x += (a.Y - b.Y) * (a.Z + b.Z)
+		y += (a.Z - b.Z) * (a.X + b.X)
+		z += (a.X - b.X) * (a.Y + b.Y)
+	}
+	return *vec.NewVec3(x, y, z)
+}
+
+// earClip splits a polygon into len(points)-2 triangles with the winding
+// of the polygon, returned as indecies into points. The polygon is
+// projected onto the plane across its Newell normal, so it may be concave
+// or somewhat non-planar. Ears are clipped from the second vertex on,
+// which fans convex polygons around their first vertex. Should no ear be
+// found, as for self-intersecting polygons, the next vertex is clipped
+// anyway so that every polygon gives a full set of triangles
+func earClip(points []vec.Vec3) []int {
+	n := len(points)
+	if n < 3 {
+		return []int{}
+	}
+	corners := make([]int, 0, (n-2)*3)
+	if n == 3 {
+		return append(corners, 0, 1, 2)
+	}
+
+	normal := polygonNormal(points)
+	if normal.Magnitude == 0 {
+		for j := 1; j < n-1; j++ {
+			corners = append(corners, 0, j, j+1)
+		}
+		return corners
+	}
+	tangent, bitangent := tangentFrame(vec.Divide(normal, normal.Magnitude))
+	xs := make([]float64, n, n)
+	ys := make([]float64, n, n)
+	for k, v := range points {
+		xs[k], ys[k] = vec.Dot(v, tangent), vec.Dot(v, bitangent)
+	}
+
+	// Twice the signed area of a, b, c, positive when they turn
+	// counterclockwise around the normal
+	cross := func(a, b, c int) float64 {
+		return (xs[b]-xs[a])*(ys[c]-ys[a]) - (ys[b]-ys[a])*(xs[c]-xs[a])
+	}
+
+	remaining := make([]int, n, n)
+	for k := range remaining {
+		remaining[k] = k
+	}
+	isEar := func(k int) bool {
+		m := len(remaining)
+		a, b, c := remaining[(k+m-1)%m], remaining[k], remaining[(k+1)%m]
+		if cross(a, b, c) <= 0 {
+			return false
+		}
+		for _, v := range remaining {
+			if v == a || v == b || v == c {
+				continue
+			}
+			if cross(a, b, v) >= 0 && cross(b, c, v) >= 0 && cross(c, a, v) >= 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	k := 1
+	for len(remaining) > 3 {
+		m := len(remaining)
+		ear := k % m
+		for tries := 0; tries < m; tries++ {
+			if isEar((k + tries) % m) {
+				ear = (k + tries) % m
+				break
+			}
+		}
+		corners = append(corners, remaining[(ear+m-1)%m], remaining[ear], remaining[(ear+1)%m])
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+		k = ear
+	}
+	return append(corners, remaining[0], remaining[1], remaining[2])
+}
+
+// AngleWeightedNormals computes a unit normal for every vertex by
+// averaging the normals of the triangles around it, weighted by the angle
+// each triangle makes at the vertex. This keeps the result independent of
+// how the surface happens to be split into triangles
+func AngleWeightedNormals(verticies []float64, indecies []int) []float64 {
+	vertex := func(i int) vec.Vec3 {
+		return *vec.NewVec3(verticies[i*3], verticies[i*3+1], verticies[i*3+2])
+	}
+
+	sums := make([]float64, len(verticies), len(verticies))
+	for t := 0; t+2 < len(indecies); t += 3 {
+		tri := [3]int{indecies[t], indecies[t+1], indecies[t+2]}
+		p := [3]vec.Vec3{vertex(tri[0]), vertex(tri[1]), vertex(tri[2])}
+
+		n := vec.Cross(vec.Subtract(p[1], p[0]), vec.Subtract(p[2], p[0]))
+		if n.Magnitude == 0 {
+			// Degenerate triangles have no direction to contribute
+			continue
+		}
+		n = vec.Divide(n, n.Magnitude)
+
+		for k := 0; k < 3; k++ {
+			e0 := vec.Subtract(p[(k+1)%3], p[k])
+			e1 := vec.Subtract(p[(k+2)%3], p[k])
+			if e0.Magnitude == 0 || e1.Magnitude == 0 {
+				continue
+			}
+			cos := vec.Dot(e0, e1) / (e0.Magnitude * e1.Magnitude)
+			angle := math.Acos(math.Max(-1, math.Min(1, cos)))
+
+			sums[tri[k]*3] += n.X * angle
+			sums[tri[k]*3+1] += n.Y * angle
+			sums[tri[k]*3+2] += n.Z * angle
+		}
+	}
+
+	for i := 0; i+2 < len(sums); i += 3 {
+		n := vec.NewVec3(sums[i], sums[i+1], sums[i+2])
+		if n.Magnitude == 0 {
+			continue
+		}
+		sums[i] /= n.Magnitude
+		sums[i+1] /= n.Magnitude
+		sums[i+2] /= n.Magnitude
+	}
+	return sums
+}
+
+// TriangleMesh is a single object made of many triangles. Unlike
+// converting a PolygonMesh into Triangles, it keeps the shared vertex
+// buffer and intersects triangles by index, so no per-triangle edges or
+// normals are stored. When Smooth is set the shading normal is
+// interpolated from the vertex normals in Normals, indexed per triangle
+// corner by NormalIndecies. Texture coordinates are interpolated the same
+// way from the u, v pairs in UVs when the mesh has them
+type TriangleMesh struct {
+	ID              string
+	Verticies       []float64
+	Indecies        []int
+	Normals         []float64
+	NormalIndecies  []int
+	Smooth          bool
+	UVs             []float64
+	UVIndecies      []int
+	Col             color.RGBA
+	RefractiveIndex float64
+	Culling         bool
+	tree            *bvhTree
+	areas           []float64 // running total of triangle areas, for sampling
+}
+
+// NewTriangleMesh creates a mesh object that shares the vertex buffer of
+// the PolygonMesh and builds a BVH over its triangles. The mesh is smooth
+// shaded when the PolygonMesh has vertex normals, and flat shaded
+// otherwise, as ConvertPolygonSerial does
+func NewTriangleMesh(id string, p *PolygonMesh, col color.RGBA, refractive float64, culling bool) *TriangleMesh {
+	m := new(TriangleMesh)
+	m.ID = id
+	m.Verticies = p.Verticies
+	m.Indecies = p.Triangulate()
+	if normalIndecies := p.TriangulateNormals(); normalIndecies != nil {
+		m.Normals = p.VertexNormals
+		m.NormalIndecies = normalIndecies
+		m.Smooth = true
+	}
+	if uvIndecies := p.TriangulateTextures(); uvIndecies != nil {
+		m.UVs = p.TextureVertecies
+		m.UVIndecies = uvIndecies
+	}
+	m.Col = col
+	m.RefractiveIndex = refractive
+	m.Culling = culling
+
+	bounds := make([]AABB, m.NumTriangles(), m.NumTriangles())
+	for i := range bounds {
+		v0, v1, v2 := m.TriangleVerticies(i)
+		bounds[i] = NewAABB(v0, v1).Extend(v2)
+	}
+	m.tree = newBVHTree(bounds)
+	return m
+}
+
+// SmoothNormals replaces the vertex normals of the mesh with
+// angle-weighted normals computed from its triangles and turns on smooth
+// shading. It is meant for meshes of curved surfaces that come without
+// normals; hard edges are rounded off as well
+func (m *TriangleMesh) SmoothNormals() {
+	m.Normals = AngleWeightedNormals(m.Verticies, m.Indecies)
+	m.NormalIndecies = m.Indecies
+	m.Smooth = true
+}
+
+// NumTriangles returns the number of triangles in the mesh
+func (m *TriangleMesh) NumTriangles() int {
+	return len(m.Indecies) / 3
+}
+
+// vertex returns the vertex at index i of the vertex buffer
+func (m *TriangleMesh) vertex(i int) vec.Vec3 {
+	return *vec.NewVec3(m.Verticies[i*3], m.Verticies[i*3+1], m.Verticies[i*3+2])
+}
+
+// TriangleNormals returns the three vertex normals of triangle i
+func (m *TriangleMesh) TriangleNormals(i int) (vec.Vec3, vec.Vec3, vec.Vec3) {
+	normal := func(k int) vec.Vec3 {
+		return *vec.NewVec3(m.Normals[k*3], m.Normals[k*3+1], m.Normals[k*3+2])
+	}
+	return normal(m.NormalIndecies[i*3]), normal(m.NormalIndecies[i*3+1]), normal(m.NormalIndecies[i*3+2])
+}
+
+// TriangleUVs returns the texture coordinates of the three verticies of
+// triangle i. Meshes without texture coordinates use (0, 0), (1, 0) and
+// (0, 1), so U and V are the barycentric coordinates of the hit
+func (m *TriangleMesh) TriangleUVs(i int) [3][2]float64 {
+	if m.UVIndecies == nil {
+		return defaultTriangleUVs
+	}
+	var uvs [3][2]float64
+	for k := 0; k < 3; k++ {
+		j := m.UVIndecies[i*3+k]
+		uvs[k] = [2]float64{m.UVs[j*2], m.UVs[j*2+1]}
+	}
+	return uvs
+}
+
+// TriangleVerticies returns the three verticies of triangle i
+func (m *TriangleMesh) TriangleVerticies(i int) (vec.Vec3, vec.Vec3, vec.Vec3) {
+	return m.vertex(m.Indecies[i*3]), m.vertex(m.Indecies[i*3+1]), m.vertex(m.Indecies[i*3+2])
+}
+
+// GetID is the object specific method to return the ID of the mesh
+func (m *TriangleMesh) GetID() string {
+	return m.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (m *TriangleMesh) GetColor() color.RGBA {
+	return m.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (m *TriangleMesh) GetRefractiveIndex() float64 {
+	return m.RefractiveIndex
+}
+
+// Bounds returns the axis-aligned bounding box of the mesh
+func (m *TriangleMesh) Bounds() AABB {
+	return m.tree.bounds()
+}
+
+// Intersects returns the closest intersection with any of the triangles
+// in the mesh
+func (m *TriangleMesh) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	dir := ray.Direction
+	dir.Normalize()
+
+	var u, v float64
+	closest, t0 := m.tree.traverse(ray, math.Inf(1), func(i int, maxDist float64) (float64, bool) {
+		v0, v1, v2 := m.TriangleVerticies(i)
+		isHit, t, hu, hv := intersectTriangle(ray.Origin, dir, v0, v1, v2, m.Culling)
+		if !isHit || t <= 0 || t >= maxDist {
+			return 0, false
+		}
+		u, v = hu, hv
+		return t, true
+	})
+
+	if closest < 0 {
+		return FalseObject()
+	}
+
+	v0, v1, v2 := m.TriangleVerticies(closest)
+	n := vec.Cross(vec.Subtract(v1, v0), vec.Subtract(v2, v0))
+	n.Normalize()
+
+	rec := triangleHitRecord(m, dir, v0, v1, v2, n, t0, u, v)
+	if m.UVIndecies != nil {
+		applyTriangleUVs(&rec, v0, v1, v2, m.TriangleUVs(closest))
+	}
+	if m.Smooth {
+		n0, n1, n2 := m.TriangleNormals(closest)
+		shadeTriangle(&rec, dir, n0, n1, n2)
+	}
+	return rec, true
+}
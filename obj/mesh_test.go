@@ -0,0 +1,393 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func makeSquareMesh() *PolygonMesh {
+	poly := MakePolygonMesh()
+	poly.NumFaces[0] = 1
+	poly.NumVerticies = []int{4}
+	poly.VertexIndecies = []int{0, 1, 2, 3}
+	poly.Verticies = []float64{
+		-0.5, -0.5, -4.0,
+		0.5, -0.5, -4.0,
+		0.5, 0.5, -4.0,
+		-0.5, 0.5, -4.0}
+	return poly
+}
+
+func TestTriangulatePolygonFace(t *testing.T) {
+	t.Parallel()
+
+	indecies := makeSquareMesh().Triangulate()
+	expected := []int{0, 1, 2, 0, 2, 3}
+
+	if len(indecies) != len(expected) {
+		t.Fatal("Quad should be split into two triangles")
+	}
+
+	for i := range expected {
+		if indecies[i] != expected[i] {
+			t.Error("Quad was not fanned correctly")
+		}
+	}
+}
+
+func TestTriangulatePretriangulatedFace(t *testing.T) {
+	t.Parallel()
+
+	// .mesh files store faces already split into triangles
+	poly := makeSquareMesh()
+	poly.VertexIndecies = []int{0, 1, 2, 0, 2, 3}
+
+	indecies := poly.Triangulate()
+	if len(indecies) != 6 || indecies[5] != 3 {
+		t.Error("Pre-triangulated faces should be kept as they are")
+	}
+}
+
+func TestTriangulateSkipsDegenerateFaces(t *testing.T) {
+	t.Parallel()
+
+	// A point face next to a triangle, stored as a polygon and already split
+	poly := makeSquareMesh()
+	poly.NumFaces[0] = 2
+	poly.NumVerticies = []int{1, 3}
+	poly.VertexIndecies = []int{3, 0, 1, 2}
+	indecies := poly.Triangulate()
+	if len(indecies) != 3 || indecies[0] != 0 || indecies[2] != 2 {
+		t.Error("Polygon faces with fewer than three verticies should be skipped", indecies)
+	}
+
+	poly.NumVerticies = []int{4, 1}
+	poly.VertexIndecies = []int{0, 1, 2, 0, 2, 3}
+	indecies = poly.Triangulate()
+	if len(indecies) != 6 || indecies[5] != 3 {
+		t.Error("Pre-split faces with fewer than three verticies should be skipped", indecies)
+	}
+
+	if triangles := poly.ConvertPolygonParallel(color.RGBA{255, 0, 0, 1}, 1); len(triangles) != 2 {
+		t.Error("Degenerate face should not produce triangles", len(triangles))
+	}
+}
+
+func TestTriangulateSkipsInvalidVerticies(t *testing.T) {
+	t.Parallel()
+
+	// The second face refers past the end of the vertex buffer, as a
+	// broken file would
+	poly := makeSquareMesh()
+	poly.NumFaces[0] = 2
+	poly.NumVerticies = []int{3, 3}
+	poly.VertexIndecies = []int{0, 1, 2, 0, 2, 7}
+	if indecies := poly.Triangulate(); len(indecies) != 3 {
+		t.Error("Polygon face with a vertex out of range should be skipped", indecies)
+	}
+	if triangles := poly.ConvertPolygonSerial(color.RGBA{255, 0, 0, 1}, 1); len(triangles) != 1 {
+		t.Error("Converted face with a vertex out of range should be skipped", len(triangles))
+	}
+
+	// Pre-split faces are checked the same way
+	poly.NumVerticies = []int{4}
+	poly.VertexIndecies = []int{0, 1, 2, 0, 2, -1}
+	if triangles := poly.ConvertPolygonParallel(color.RGBA{255, 0, 0, 1}, 1); len(triangles) != 0 {
+		t.Error("Pre-split face with a vertex out of range should be skipped", len(triangles))
+	}
+	if mesh := NewTriangleMesh("mesh1", poly, color.RGBA{255, 0, 0, 1}, 1, false); mesh.NumTriangles() != 0 {
+		t.Error("Mesh should not keep faces with a vertex out of range", mesh.NumTriangles())
+	}
+}
+
+func TestTriangulateConcaveFace(t *testing.T) {
+	t.Parallel()
+
+	// An arrowhead in the plane x = 1 whose fourth vertex points back
+	// into the face, so a fan around the first vertex would cover the notch
+	poly := MakePolygonMesh()
+	poly.NumFaces[0] = 1
+	poly.NumVerticies = []int{4}
+	poly.VertexIndecies = []int{0, 1, 2, 3}
+	poly.Verticies = []float64{
+		1, 0, 0,
+		1, 2, 1,
+		1, 0, 2,
+		1, 1, 1}
+
+	indecies := poly.Triangulate()
+	if len(indecies) != 6 {
+		t.Fatal("Concave quad should be split into two triangles")
+	}
+
+	verticies := poly.verticiesVec3()
+	area := 0.0
+	for i := 0; i < len(indecies); i += 3 {
+		v0, v1, v2 := verticies[indecies[i]], verticies[indecies[i+1]], verticies[indecies[i+2]]
+		n := vec.Cross(vec.Subtract(v1, v0), vec.Subtract(v2, v0))
+		if n.X <= 0 {
+			t.Error("Triangle does not keep the winding of the face", indecies[i:i+3])
+		}
+		area += n.Magnitude / 2
+	}
+	if math.Abs(area-1) > 1e-12 {
+		t.Error("Triangles should exactly cover the face", area)
+	}
+}
+
+func TestConvertPolygonOrder(t *testing.T) {
+	t.Parallel()
+
+	poly := makeCubeMesh()
+	serial := poly.ConvertPolygonSerial(color.RGBA{255, 0, 0, 1}, 1)
+	parallel := poly.ConvertPolygonParallel(color.RGBA{255, 0, 0, 1}, 1)
+	indecies := poly.Triangulate()
+	if len(serial) != 12 || len(parallel) != 12 {
+		t.Fatal("Cube should be split into 12 triangles", len(serial), len(parallel))
+	}
+
+	verticies := poly.verticiesVec3()
+	for i := range serial {
+		if serial[i] != parallel[i] {
+			t.Error("Parallel conversion should keep the order of the faces", i)
+		}
+		if serial[i].V0 != verticies[indecies[i*3]] || serial[i].V1 != verticies[indecies[i*3+1]] ||
+			serial[i].V2 != verticies[indecies[i*3+2]] {
+			t.Error("Triangle does not match the triangulated face", i)
+		}
+	}
+}
+
+func TestConvertPolygonAttributes(t *testing.T) {
+	t.Parallel()
+
+	col := color.RGBA{0, 255, 0, 1}
+	poly := makeSquareMesh()
+	triangles := poly.ConvertPolygonSerial(col, 1.5)
+	for i, tri := range triangles {
+		if tri.Smooth || tri.UVs != defaultTriangleUVs || tri.Col != col || tri.RefractiveIndex != 1.5 {
+			t.Error("Face without normals or uvs should give a flat triangle with default uvs", i)
+		}
+	}
+
+	poly.VertexNormals = []float64{0, 0, 1, 0, 1, 0}
+	poly.NormalIndecies = []int{0, 1, 1, 0}
+	poly.TextureVertecies = []float64{0, 0, 1, 0, 1, 1, 0, 1}
+	poly.TextureIndecies = []int{0, 1, 2, 3}
+	triangles = poly.ConvertPolygonParallel(col, 1)
+	if len(triangles) != 2 {
+		t.Fatal("Square should be split into 2 triangles", len(triangles))
+	}
+
+	up, front := *vec.NewVec3(0, 1, 0), *vec.NewVec3(0, 0, 1)
+	expected := []struct {
+		normals [3]vec.Vec3
+		uvs     [3][2]float64
+	}{
+		{[3]vec.Vec3{front, up, up}, [3][2]float64{{0, 0}, {1, 0}, {1, 1}}},
+		{[3]vec.Vec3{front, up, front}, [3][2]float64{{0, 0}, {1, 1}, {0, 1}}},
+	}
+	for i, tri := range triangles {
+		if !tri.Smooth || tri.N0 != expected[i].normals[0] || tri.N1 != expected[i].normals[1] ||
+			tri.N2 != expected[i].normals[2] {
+			t.Error("Triangle should be smooth with the vertex normals of its corners", i, tri.N0, tri.N1, tri.N2)
+		}
+		if tri.UVs != expected[i].uvs {
+			t.Error("Triangle should take the uvs of its corners", i, tri.UVs)
+		}
+	}
+
+	poly.NormalIndecies[1] = -1
+	poly.TextureIndecies[3] = 9
+	triangles = poly.ConvertPolygonSerial(col, 1)
+	if triangles[0].Smooth || !triangles[1].Smooth {
+		t.Error("Only triangles with a normal on every corner should be smooth")
+	}
+	if triangles[0].UVs == defaultTriangleUVs || triangles[1].UVs != defaultTriangleUVs {
+		t.Error("Triangles with an out of range uv index should keep the default uvs")
+	}
+}
+
+func TestTriangleMeshIntersects(t *testing.T) {
+	t.Parallel()
+
+	poly := makeSquareMesh()
+	mesh := NewTriangleMesh("mesh1", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+
+	if mesh.NumTriangles() != 2 {
+		t.Error("Mesh should contain two triangles")
+	}
+
+	if &mesh.Verticies[0] != &poly.Verticies[0] {
+		t.Error("Mesh should share the vertex buffer of the polygon mesh")
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(-0.25, 0.25, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := mesh.Intersects(ray)
+	hit, n, t0 := rec.Point, rec.Normal, rec.T0
+
+	if !is_hit || t0 != 4 {
+		t.Error("Mesh was not hit")
+	}
+
+	if !vec.IsEqual(hit, *vec.NewVec3(-0.25, 0.25, -4)) {
+		t.Error("Hit location was not correct")
+	}
+
+	if !vec.IsEqual(n, *vec.NewVec3(0, 0, 1)) {
+		t.Error("N vector not correct")
+	}
+
+	miss := cam.NewRay(0, "camera", vec.NewVec3(1, 0, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := mesh.Intersects(miss); is_hit {
+		t.Error("Ray beside the mesh should not hit")
+	}
+}
+
+func TestAngleWeightedNormals(t *testing.T) {
+	t.Parallel()
+
+	// Three faces of a cube meeting at the origin. The top face is split
+	// into two triangles there, which would pull a plain average towards +z
+	verticies := []float64{
+		0, 0, 0,
+		-1, 0, 0,
+		0, -1, 0,
+		0, 0, -1,
+		-1, -1, 0}
+	indecies := []int{
+		0, 4, 2,
+		0, 1, 4,
+		0, 2, 3,
+		0, 3, 1}
+
+	normals := AngleWeightedNormals(verticies, indecies)
+	expected := 1 / math.Sqrt(3)
+	for k := 0; k < 3; k++ {
+		if math.Abs(normals[k]-expected) > 1e-9 {
+			t.Error("Corner normal should point along the diagonal", normals[0:3])
+		}
+	}
+}
+
+func TestTriangleMeshSmoothShading(t *testing.T) {
+	t.Parallel()
+
+	// Vertex normals tilted outwards, as on a curved surface
+	poly := makeSquareMesh()
+	poly.VertexNormals = []float64{
+		-1, -1, 1,
+		1, -1, 1,
+		1, 1, 1,
+		-1, 1, 1}
+	poly.NormalIndecies = []int{0, 1, 2, 3}
+	mesh := NewTriangleMesh("mesh1", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0.25, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := mesh.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Mesh was not hit")
+	}
+	if rec.Normal.X <= 0 || math.Abs(rec.Normal.Magnitude-1) > 1e-9 {
+		t.Error("Shading normal should lean towards +x", rec.Normal)
+	}
+	if !vec.IsEqual(rec.GeometricNormal, *vec.NewVec3(0, 0, 1)) {
+		t.Error("Geometric normal should stay flat", rec.GeometricNormal)
+	}
+
+	// At the center the tilts cancel out
+	center := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, _ = mesh.Intersects(center)
+	if math.Abs(rec.Normal.X) > 1e-9 || math.Abs(rec.Normal.Y) > 1e-9 {
+		t.Error("Shading normal at the center should be flat", rec.Normal)
+	}
+
+	mesh.Smooth = false
+	rec, _ = mesh.Intersects(ray)
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) {
+		t.Error("Flat shading should use the face normal", rec.Normal)
+	}
+}
+
+func TestTriangleMeshFlatByDefault(t *testing.T) {
+	t.Parallel()
+
+	// A corner of a cube has no vertex normals, so its edges stay sharp
+	poly := makeCubeMesh()
+	mesh := NewTriangleMesh("cube", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+	if mesh.Smooth {
+		t.Fatal("Mesh without vertex normals should be flat shaded")
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0.9, 0.9, 5), vec.NewVec3(0, 0, -1))
+	rec, is_hit := mesh.Intersects(ray)
+	if !is_hit || !vec.IsEqual(rec.Normal, rec.GeometricNormal) {
+		t.Fatal("Flat shading should use the face normal", rec.Normal)
+	}
+
+	mesh.SmoothNormals()
+	rec, _ = mesh.Intersects(ray)
+	if !mesh.Smooth || vec.IsEqual(rec.Normal, rec.GeometricNormal) {
+		t.Error("Computed normals should round off the edges", rec.Normal)
+	}
+}
+
+func TestSmoothTriangleIntersects(t *testing.T) {
+	t.Parallel()
+
+	v0 := *vec.NewVec3(-1, -1, -3)
+	v1 := *vec.NewVec3(1, -1, -3)
+	v2 := *vec.NewVec3(0, 1, -3)
+	n0 := *vec.NewVec3(-1, 0, 1)
+	n1 := *vec.NewVec3(1, 0, 1)
+	n2 := *vec.NewVec3(0, 0, 1)
+	tri := NewSmoothTriangle("tri", v0, v1, v2, n0, n1, n2, color.RGBA{255, 0, 0, 1}, 1, false)
+
+	// The hit at v1 takes the normal of v1
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0.99, -0.99, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := tri.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Triangle was not hit")
+	}
+	if rec.Normal.X < 0.6 {
+		t.Error("Shading normal not interpolated", rec.Normal)
+	}
+}
+
+func TestTriangleMeshUV(t *testing.T) {
+	t.Parallel()
+
+	// Texture coordinates that flip the square horizontally
+	poly := makeSquareMesh()
+	poly.TextureVertecies = []float64{
+		1, 0,
+		0, 0,
+		0, 1,
+		1, 1}
+	poly.TextureIndecies = []int{0, 1, 2, 3}
+	mesh := NewTriangleMesh("mesh1", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0.25, -0.25, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := mesh.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Mesh was not hit")
+	}
+	if math.Abs(rec.U-0.25) > 1e-9 || math.Abs(rec.V-0.25) > 1e-9 {
+		t.Error("UV not correct", rec.U, rec.V)
+	}
+	if !vec.IsEqual(rec.Tangent, *vec.NewVec3(-1, 0, 0)) {
+		t.Error("Tangent should follow increasing U", rec.Tangent)
+	}
+
+	// Without texture coordinates U and V are barycentric
+	poly.TextureIndecies = nil
+	mesh = NewTriangleMesh("mesh1", poly, color.RGBA{255, 0, 0, 1}, 1, false)
+	rec, _ = mesh.Intersects(ray)
+	if math.Abs(rec.U-rec.Barycentric[1]) > 1e-12 || math.Abs(rec.V-rec.Barycentric[2]) > 1e-12 {
+		t.Error("Default UV should be barycentric", rec.U, rec.V)
+	}
+}
@@ -16,12 +16,33 @@ type Object interface {
 	GetID() string
 	GetColor() color.RGBA
 	GetRefractiveIndex() float64
-	Intersects(*cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, float64)
+	Bounds() AABB
+	Intersects(*cam.Ray) (HitRecord, bool)
 }
 
-// FalseObject returns a failure-state object
-func FalseObject() (bool, vec.Vec3, vec.Vec3, float64, float64) {
-	return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
+// machineEpsilon bounds the relative rounding error of a float64 operation
+const machineEpsilon = 0x1p-53
+
+// gamma bounds the relative error accumulated by n floating point
+// operations, as used in Physically Based Rendering
+func gamma(n float64) float64 {
+	return (n * machineEpsilon) / (1 - n*machineEpsilon)
+}
+
+// interpolateBarycentric returns the point with barycentric coordinates
+// (u, v) weighting v1 and v2. Interpolating the verticies is more accurate
+// than stepping along the ray and has a known error bound
+func interpolateBarycentric(v0, v1, v2 vec.Vec3, u, v float64) vec.Vec3 {
+	w := 1 - u - v
+	return *vec.NewVec3(
+		w*v0.X+u*v1.X+v*v2.X,
+		w*v0.Y+u*v1.Y+v*v2.Y,
+		w*v0.Z+u*v1.Z+v*v2.Z)
+}
+
+// FalseObject returns a failure-state hit
+func FalseObject() (HitRecord, bool) {
+	return HitRecord{}, false
 }
 
 // Sphere object
@@ -30,13 +51,32 @@ type Sphere struct {
 	Center          vec.Vec3
 	Radius          float64
 	Col             color.RGBA
-	EasingDistance  float64
 	RefractiveIndex float64
 }
 
 // Intersects checks for intersections with sphere using
-// geometric method
-func (s Sphere) Intersects(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, float64) {
+// geometric method. U and V are the longitude and latitude of the hit
+func (s Sphere) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	isHit, rd, t0, t1 := s.roots(ray)
+	if !isHit {
+		return FalseObject()
+	}
+
+	// Sphere is behind the point of origin
+	if t0 < 0 && t1 < 0 {
+		return FalseObject()
+	} else if t0 <= 0 && t1 > 0 {
+		// Point of origin is inside the sphere or on/inside the surface
+		t0 = t1
+	}
+
+	return s.hitRecord(ray, rd, t0, t1), true
+}
+
+// roots returns the normalized ray direction and both distances along it
+// where the ray crosses the sphere, in ascending order. Either may be
+// behind the origin
+func (s Sphere) roots(ray *cam.Ray) (bool, vec.Vec3, float64, float64) {
 	sc := s.Center
 	rd := ray.Direction
 	rd.Normalize()
@@ -46,9 +86,10 @@ func (s Sphere) Intersects(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, flo
 	l2oc := vec.Dot(oc, oc)
 	tCa := vec.Dot(oc, rd)
 
-	//sphere located behind ray origin
-	if tCa < 0 {
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
+	// sphere located behind ray origin. A ray starting inside the sphere
+	// still leaves it, even when heading away from the center
+	if tCa < 0 && l2oc > srsq {
+		return false, rd, 0, 0
 	}
 
 	d2 := l2oc - (tCa * tCa)
@@ -57,55 +98,42 @@ func (s Sphere) Intersects(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, flo
 	// the projected ray is greater than the radius, then the projected
 	// ray is definitely outside the bounds of the sphere
 	if d2 > srsq {
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
+		return false, rd, 0, 0
 	}
 
 	t2hc := srsq - d2
 
 	if t2hc < 0 {
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
-	}
-
-	// If the origin is inside the sphere of light, it counts as a hit
-	// This is a useful result if the ray is refracted inside the sphere
-	/*
-		if l2oc < srsq {
-			// TODO: These numbers make no sense
-			t0 := float64(0)
-			t1 := float64(0)
-			hit := ray.Origin
-			oc.Multiply(-1)
-			return true, hit, oc, t0, t1
-		}
-	*/
-
-	thc := math.Sqrt(t2hc)
-	t0 := tCa - thc
-	t1 := tCa + thc
-
-	// Sphere is behind the point of origin
-	if t0 < 0 && t1 < 0 {
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
-	} else if t0 <= 0 && t1 > 0 {
-		// Point of origin is inside the sphere or on/inside the surface
-		t0 = t1
-	}
-
-	// Swap if reversed
-	if t0 > t1 {
-		tmp := t0
-		t0 = t1
-		t1 = tmp
+		return false, rd, 0, 0
 	}
 
-	dist := s.EasingDistance * t0
-	rd.Multiply(dist)
-	hit := vec.Add(ray.Origin, rd)
-
-	n := vec.Subtract(hit, sc)
-	n.Divide(s.Radius)
+	thc := math.Sqrt(t2hc)
+	return true, rd, tCa - thc, tCa + thc
+}
 
-	return true, hit, n, t0, t1
+// hitRecord builds the record for the hit t0 along the ray, which has
+// normalized direction rd
+func (s Sphere) hitRecord(ray *cam.Ray, rd vec.Vec3, t0, t1 float64) HitRecord {
+	hit := vec.Add(ray.Origin, vec.Multiply(rd, t0))
+
+	// Reproject the hit onto the surface, which bounds its error
+	// relative to the hit itself
+	n := vec.Subtract(hit, s.Center)
+	n = vec.Divide(n, n.Magnitude)
+	hit = vec.Add(s.Center, vec.Multiply(n, s.Radius))
+
+	rec := newHitRecord(s, rd, hit, n, t0, t1)
+	rec.Error = *vec.NewVec3(
+		gamma(5)*math.Abs(hit.X),
+		gamma(5)*math.Abs(hit.Y),
+		gamma(5)*math.Abs(hit.Z))
+
+	// Longitude and latitude around the y axis. U runs once around
+	// starting at +x and V from the bottom pole to the top one
+	rec.U = sphericalAngle(n.X, -n.Z) / (2 * math.Pi)
+	rec.V = 1 - math.Acos(math.Max(-1, math.Min(1, n.Y)))/math.Pi
+	rec.Tangent, rec.Bitangent = alignTangentFrame(n, *vec.NewVec3(n.Z, 0, -n.X))
+	return rec
 }
 
 // GetColor is the object specific method to return the color
@@ -125,66 +153,10 @@ func (s Sphere) GetID() string {
 	return s.ID
 }
 
-// Light is a basic spherical light
-// TODO: Ironically, Light does not fit the Object interface
-type Light struct {
-	Type         string //point, directional, etc
-	Center       vec.Vec3
-	Radius       float64
-	RadiusSquare float64
-	Col          color.RGBA
-}
-
-// Intersects checks for intersections between cam.Ray and Sphere-like
-// light, using the geometric method
-func (l *Light) Intersects(ray *cam.Ray) (bool, float64) {
-	rd := ray.Direction
-	rd.Normalize()
-
-	oc := vec.Subtract(l.Center, ray.Origin)
-	l2oc := vec.Dot(oc, oc)
-	tCa := vec.Dot(oc, rd)
-
-	//sphere located behind ray origin
-	if tCa < 0 {
-		return false, 0
-	}
-
-	d2 := l2oc - (tCa * tCa)
-
-	// if the distance between the closest point to the sphere center on
-	// the projected ray is greater than the radius, then the projected
-	// ray is definitely outside the bounds of the sphere
-	if d2 > l.RadiusSquare {
-		return false, 0
-	}
-
-	t2hc := l.RadiusSquare - d2
-
-	if t2hc < 0 {
-		return false, 0
-	}
-
-	thc := math.Sqrt(t2hc)
-	t0 := tCa - thc
-	t1 := tCa + thc
-
-	// Sphere is behind the point of origin
-	if t0 < 0 && t1 < 0 {
-		return false, 0
-	} else if t0 <= 0 && t1 > 0 {
-		// Point of origin is inside the sphere or on/inside the surface
-		t0 = t1
-	}
-
-	// Swap if reversed
-	if t0 > t1 {
-		tmp := t0
-		t0 = t1
-		t1 = tmp
-	}
-
-	return true, t0
+// Bounds returns the axis-aligned bounding box of the sphere
+func (s Sphere) Bounds() AABB {
+	r := *vec.NewVec3(s.Radius, s.Radius, s.Radius)
+	return AABB{vec.Subtract(s.Center, r), vec.Add(s.Center, r)}
 }
 
 // Triangle is fundamental container for the most basic renderable shape
@@ -199,19 +171,22 @@ type Triangle struct {
 	v0v1            vec.Vec3
 	v0v2            vec.Vec3
 	N               vec.Vec3
+	N0              vec.Vec3 // vertex normals, used when Smooth is set
+	N1              vec.Vec3
+	N2              vec.Vec3
+	Smooth          bool
+	UVs             [3][2]float64 // texture coordinates of V0, V1 and V2
 	Col             color.RGBA
-	EasingDistance  float64
 	RefractiveIndex float64
 	Culling         bool
 }
 
 // NewTriangle is a constructor for Triangles and precomputed values
-func NewTriangle(id string, v0, v1, v2 vec.Vec3, col color.RGBA, easing, refractive float64, culling bool) *Triangle {
+func NewTriangle(id string, v0, v1, v2 vec.Vec3, col color.RGBA, refractive float64, culling bool) *Triangle {
 	t := new(Triangle)
 
 	t.ID = id
 	t.Col = col
-	t.EasingDistance = easing
 	t.RefractiveIndex = refractive
 	t.Culling = culling
 
@@ -232,6 +207,19 @@ func NewTriangle(id string, v0, v1, v2 vec.Vec3, col color.RGBA, easing, refract
 
 	// May not need to normalize this
 	t.N.Normalize()
+
+	t.UVs = defaultTriangleUVs
+	return t
+}
+
+// NewSmoothTriangle is a constructor for Triangles that interpolate the
+// vertex normals n0, n1 and n2 across their surface
+func NewSmoothTriangle(id string, v0, v1, v2, n0, n1, n2 vec.Vec3, col color.RGBA, refractive float64, culling bool) *Triangle {
+	t := NewTriangle(id, v0, v1, v2, col, refractive, culling)
+	t.N0 = n0
+	t.N1 = n1
+	t.N2 = n2
+	t.Smooth = true
 	return t
 }
 
@@ -252,10 +240,15 @@ func (t *Triangle) GetRefractiveIndex() float64 {
 	return t.RefractiveIndex
 }
 
+// Bounds returns the axis-aligned bounding box of the triangle
+func (t *Triangle) Bounds() AABB {
+	return NewAABB(t.V0, t.V1).Extend(t.V2)
+}
+
 // IntersectsImplicit checks for intersections between a ray the triangle
 // using the implicit method
 // TODO: Dead code
-func (t *Triangle) IntersectsImplicit(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, float64) {
+func (t *Triangle) IntersectsImplicit(ray *cam.Ray) (HitRecord, bool) {
 	// This is the geometric solution
 	// Plane intersection first
 	TOLERANCE := 0.001
@@ -265,7 +258,7 @@ func (t *Triangle) IntersectsImplicit(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, f
 	if math.Abs((vec.Dot(t.N, dir))) < TOLERANCE {
 		// Ray direction and N are perpendicular
 		// Ray is parallel to plane and will not intersect
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
+		return FalseObject()
 	}
 
 	D := vec.Dot(t.N, t.V0)
@@ -273,7 +266,7 @@ func (t *Triangle) IntersectsImplicit(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, f
 
 	if t0 < 0 {
 		// Plane is behind ray
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
+		return FalseObject()
 	}
 
 	// Inside-outside test to see if point is inside triangle, not just plane
@@ -285,8 +278,7 @@ func (t *Triangle) IntersectsImplicit(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, f
 	// triangle and P are to the left of the triangle's edges, then all the
 	// edges formed by P are inside the triangle and so is P
 
-	dist := t.EasingDistance * t0
-	dir.Multiply(dist)
+	dir.Multiply(t0)
 	P := vec.Add(ray.Origin, dir)
 
 	pe0 := vec.Subtract(P, t.V0)
@@ -296,16 +288,16 @@ func (t *Triangle) IntersectsImplicit(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, f
 		(vec.Dot(vec.Cross(t.E1, pe1), t.N) <= 0) ||
 		(vec.Dot(vec.Cross(t.E2, pe2), t.N) <= 0) {
 
-		return false, *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, 0), 0, 0
+		return FalseObject()
 	}
 
-	return true, P, t.N, t0, t0
+	return newHitRecord(t, dir, P, t.N, t0, t0), true
 }
 
 // IntersectsBarycentric checks for intersections between a ray the triangle
 // using the barycentric method
 // TODO: Dead code
-func (t *Triangle) IntersectsBarycentric(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, float64) {
+func (t *Triangle) IntersectsBarycentric(ray *cam.Ray) (HitRecord, bool) {
 	TOLERANCE := 0.001
 	dir := ray.Direction
 	dir.Normalize()
@@ -324,8 +316,7 @@ func (t *Triangle) IntersectsBarycentric(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3
 		return FalseObject()
 	}
 
-	dist := t.EasingDistance * t0
-	dir.Multiply(dist)
+	dir.Multiply(t0)
 	P := vec.Add(ray.Origin, dir)
 
 	/* Barycentric */
@@ -359,49 +350,197 @@ func (t *Triangle) IntersectsBarycentric(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3
 	u /= denominator
 	v /= denominator
 
-	return true, P, t.N, t0, t0
+	rec := newHitRecord(t, dir, P, t.N, t0, t0)
+	rec.Barycentric = [3]float64{1 - u - v, u, v}
+	return rec, true
 }
 
 // Intersects checks for intersections between a ray the triangle
-// using the Trombole-Muller method
-func (t *Triangle) Intersects(ray *cam.Ray) (bool, vec.Vec3, vec.Vec3, float64, float64) {
-	TOLERANCE := 0.001
+// using the watertight method
+func (t *Triangle) Intersects(ray *cam.Ray) (HitRecord, bool) {
 	dir := ray.Direction
 	dir.Normalize()
 
-	/* Trombole-Muller */
-	pvec := vec.Cross(dir, t.v0v2)
-	denominator := vec.Dot(pvec, t.v0v1)
-	invDenominator := 1 / denominator
-
-	if t.Culling && (denominator < TOLERANCE) {
-		return FalseObject()
-	} else if math.Abs(denominator) < TOLERANCE {
+	isHit, t0, u, v := intersectTriangle(ray.Origin, dir, t.V0, t.V1, t.V2, t.Culling)
+	if !isHit {
 		return FalseObject()
 	}
 
-	tvec := vec.Subtract(ray.Origin, t.V0)
-	qvec := vec.Cross(tvec, t.v0v1)
+	rec := triangleHitRecord(t, dir, t.V0, t.V1, t.V2, t.N, t0, u, v)
+	applyTriangleUVs(&rec, t.V0, t.V1, t.V2, t.UVs)
+	if t.Smooth {
+		shadeTriangle(&rec, dir, t.N0, t.N1, t.N2)
+	}
+	return rec, true
+}
 
-	u := vec.Dot(pvec, tvec) * invDenominator
-	if u < 0 || u > 1 {
-		return FalseObject()
+// shadeTriangle replaces the flat shading normal of a triangle hit with
+// the vertex normals n0, n1 and n2 interpolated at its barycentric
+// coordinates. The geometric normal is flipped if needed to lie on the
+// same side as the shading normal, so the vertex normals decide which
+// side of the surface is the outside
+func shadeTriangle(rec *HitRecord, dir, n0, n1, n2 vec.Vec3) {
+	b := rec.Barycentric
+	n := vec.Add(vec.Add(vec.Multiply(n0, b[0]), vec.Multiply(n1, b[1])), vec.Multiply(n2, b[2]))
+	if n.Magnitude == 0 {
+		return
 	}
+	n = vec.Divide(n, n.Magnitude)
 
-	v := vec.Dot(qvec, dir) * invDenominator
-	if v < 0 || u+v > 1 {
-		return FalseObject()
+	if vec.Dot(n, rec.GeometricNormal) < 0 {
+		rec.GeometricNormal = vec.Invert(rec.GeometricNormal)
+		rec.FrontFace = vec.Dot(dir, rec.GeometricNormal) < 0
+	}
+	rec.Normal = n
+	rec.Tangent, rec.Bitangent = alignTangentFrame(n, rec.Tangent)
+}
+
+// defaultTriangleUVs are the texture coordinates of triangles that do not
+// have their own, which make U and V the barycentric coordinates
+var defaultTriangleUVs = [3][2]float64{{0, 0}, {1, 0}, {0, 1}}
+
+// applyTriangleUVs interpolates the texture coordinates uvs of the
+// verticies v0, v1 and v2 at the hit, and aligns the tangent with the
+// direction U increases in across the triangle
+func applyTriangleUVs(rec *HitRecord, v0, v1, v2 vec.Vec3, uvs [3][2]float64) {
+	b := rec.Barycentric
+	rec.U = b[0]*uvs[0][0] + b[1]*uvs[1][0] + b[2]*uvs[2][0]
+	rec.V = b[0]*uvs[0][1] + b[1]*uvs[1][1] + b[2]*uvs[2][1]
+
+	du02 := uvs[0][0] - uvs[2][0]
+	dv02 := uvs[0][1] - uvs[2][1]
+	du12 := uvs[1][0] - uvs[2][0]
+	dv12 := uvs[1][1] - uvs[2][1]
+	det := du02*dv12 - dv02*du12
+	if det == 0 {
+		// Degenerate texture coordinates keep the default frame
+		return
 	}
 
-	// (t0, u, v) as opposed to (t, u, v)
-	t0 := vec.Dot(qvec, t.v0v2) * invDenominator
+	dp02 := vec.Subtract(v0, v2)
+	dp12 := vec.Subtract(v1, v2)
+	dpdu := vec.Divide(vec.Subtract(vec.Multiply(dp02, dv12), vec.Multiply(dp12, dv02)), det)
+	rec.Tangent, rec.Bitangent = alignTangentFrame(rec.Normal, dpdu)
+}
 
-	dist := t.EasingDistance * t0
-	dir.Multiply(dist)
-	P := vec.Add(ray.Origin, dir)
+// triangleHitRecord fills a hit record for a triangle with verticies
+// v0, v1, v2 and unit normal n, hit at distance t0 with barycentric
+// coordinates (u, v) weighting v1 and v2
+func triangleHitRecord(object Object, dir, v0, v1, v2, n vec.Vec3, t0, u, v float64) HitRecord {
+	w := 1 - u - v
+	P := interpolateBarycentric(v0, v1, v2, u, v)
+
+	rec := newHitRecord(object, dir, P, n, t0, t0)
+	rec.U = u
+	rec.V = v
+	rec.Barycentric = [3]float64{w, u, v}
+	rec.Tangent, rec.Bitangent = alignTangentFrame(n, vec.Subtract(v1, v0))
+
+	// Error of interpolating the verticies
+	rec.Error = *vec.NewVec3(
+		gamma(7)*(math.Abs(w*v0.X)+math.Abs(u*v1.X)+math.Abs(v*v2.X)),
+		gamma(7)*(math.Abs(w*v0.Y)+math.Abs(u*v1.Y)+math.Abs(v*v2.Y)),
+		gamma(7)*(math.Abs(w*v0.Z)+math.Abs(u*v1.Z)+math.Abs(v*v2.Z)))
+	return rec
+}
+
+// intersectTriangle is the watertight ray/triangle test of Woop, Benthin
+// and Wald, shared by Triangle and TriangleMesh. dir must be normalized.
+// The triangle is moved into a sheared space where the ray starts at the
+// origin and points down +z, so neighbouring triangles evaluate their
+// shared edge identically and rays cannot slip between them. Hits closer
+// than the rounding error of the computation are rejected, which scales
+// with the triangle instead of using a fixed tolerance.
+// It returns the distance t0 along dir and the barycentric coordinates
+// (u, v) of the hit, weighting v1 and v2 respectively
+func intersectTriangle(org, dir, v0, v1, v2 vec.Vec3, culling bool) (bool, float64, float64, float64) {
+	d := [3]float64{dir.X, dir.Y, dir.Z}
+
+	// Permute so the largest component of the direction is z, swapping
+	// x and y when needed to preserve the winding of the triangle
+	kz := 0
+	if math.Abs(d[1]) > math.Abs(d[kz]) {
+		kz = 1
+	}
+	if math.Abs(d[2]) > math.Abs(d[kz]) {
+		kz = 2
+	}
+	kx := (kz + 1) % 3
+	ky := (kx + 1) % 3
+	if d[kz] < 0 {
+		kx, ky = ky, kx
+	}
+
+	if d[kz] == 0 {
+		// Degenerate direction
+		return false, 0, 0, 0
+	}
 
-	// Note that may have dist < t0
-	return true, P, t.N, t0, t0
+	sx := d[kx] / d[kz]
+	sy := d[ky] / d[kz]
+	sz := 1 / d[kz]
+
+	a := vec.Subtract(v0, org)
+	b := vec.Subtract(v1, org)
+	c := vec.Subtract(v2, org)
+	pa := [3]float64{a.X, a.Y, a.Z}
+	pb := [3]float64{b.X, b.Y, b.Z}
+	pc := [3]float64{c.X, c.Y, c.Z}
+
+	// Shear so the ray runs along +z
+	ax := pa[kx] - sx*pa[kz]
+	ay := pa[ky] - sy*pa[kz]
+	bx := pb[kx] - sx*pb[kz]
+	by := pb[ky] - sy*pb[kz]
+	cx := pc[kx] - sx*pc[kz]
+	cy := pc[ky] - sy*pc[kz]
+
+	// Scaled barycentric coordinates from the 2D edge functions
+	e0 := cx*by - cy*bx
+	e1 := ax*cy - ay*cx
+	e2 := bx*ay - by*ax
+
+	// Front faces have positive edge functions in the sheared space
+	if culling && (e0 < 0 || e1 < 0 || e2 < 0) {
+		return false, 0, 0, 0
+	} else if (e0 < 0 || e1 < 0 || e2 < 0) && (e0 > 0 || e1 > 0 || e2 > 0) {
+		return false, 0, 0, 0
+	}
+
+	det := e0 + e1 + e2
+	if det == 0 {
+		// Ray is parallel to the plane or the triangle is degenerate
+		return false, 0, 0, 0
+	}
+
+	az := sz * pa[kz]
+	bz := sz * pb[kz]
+	cz := sz * pc[kz]
+	scaledT := e0*az + e1*bz + e2*cz
+
+	// Hit must be in front of the ray origin
+	if (det < 0 && scaledT >= 0) || (det > 0 && scaledT <= 0) {
+		return false, 0, 0, 0
+	}
+
+	invDet := 1 / det
+	t0 := scaledT * invDet
+
+	// Reject hits that are within the floating point error of t = 0
+	maxZ := math.Max(math.Abs(az), math.Max(math.Abs(bz), math.Abs(cz)))
+	maxX := math.Max(math.Abs(ax), math.Max(math.Abs(bx), math.Abs(cx)))
+	maxY := math.Max(math.Abs(ay), math.Max(math.Abs(by), math.Abs(cy)))
+	maxE := math.Max(math.Abs(e0), math.Max(math.Abs(e1), math.Abs(e2)))
+	deltaZ := gamma(3) * maxZ
+	deltaX := gamma(5) * (maxX + maxZ)
+	deltaY := gamma(5) * (maxY + maxZ)
+	deltaE := 2 * (gamma(2)*maxX*maxY + deltaY*maxX + deltaX*maxY)
+	deltaT := 3 * (gamma(3)*maxE*maxZ + deltaE*maxZ + deltaZ*maxE) * math.Abs(invDet)
+	if t0 <= deltaT {
+		return false, 0, 0, 0
+	}
+
+	return true, t0, e1 * invDet, e2 * invDet
 }
 
 // PolygonMesh is a container for mesh polygon data
@@ -412,7 +551,10 @@ type PolygonMesh struct {
 	VertexIndecies   []int
 	Verticies        []float64
 	VertexNormals    []float64
-	TextureVertecies []float64
+	NormalIndecies   []int     // one per vertex index, -1 where a face has none
+	TextureVertecies []float64 // u, v pairs
+	TextureIndecies  []int     // one per vertex index, -1 where a face has none
+	Creases          []Crease  // sharp edges kept by Subdivide
 }
 
 // MakePolygonMesh sets up mesh container
@@ -424,62 +566,23 @@ func MakePolygonMesh() *PolygonMesh {
 }
 
 // ConvertPolygonSerial converts data in PolygonMesh
-// into an array of triangles using a serial strategy
-func (p *PolygonMesh) ConvertPolygonSerial() []Triangle {
+// into an array of triangles using a serial strategy. The triangles carry
+// the vertex normals and texture coordinates of the mesh where it has them
+func (p *PolygonMesh) ConvertPolygonSerial(col color.RGBA, refractive float64) []Triangle {
 	start := time.Now()
 
-	var vertexIndex int
-	var numTriangles int
-
+	faces := p.faceTriangles()
 	totalTriangles := 0
-	for _, v := range p.NumVerticies {
-		totalTriangles += (v - 2)
+	for _, corners := range faces {
+		totalTriangles += len(corners) / 3
 	}
 
 	triangles := make([]Triangle, totalTriangles, totalTriangles)
 
-	indexIntoVertexIndex := 0
 	triangleIndex := 0
-	for i := 0; i < p.NumFaces[0]; i++ {
-		numTriangles = p.NumVerticies[i] - 2
-		for j := 0; j < numTriangles; j++ {
-			vertexIndex = p.VertexIndecies[indexIntoVertexIndex] * 3
-			v0 := vec.NewVec3(
-				p.Verticies[vertexIndex],
-				p.Verticies[vertexIndex+1],
-				p.Verticies[vertexIndex+2])
-
-			vertexIndex = p.VertexIndecies[indexIntoVertexIndex+1] * 3
-			v1 := vec.NewVec3(
-				p.Verticies[vertexIndex],
-				p.Verticies[vertexIndex+1],
-				p.Verticies[vertexIndex+2])
-
-			vertexIndex = p.VertexIndecies[indexIntoVertexIndex+2] * 3
-			v2 := vec.NewVec3(
-				p.Verticies[vertexIndex],
-				p.Verticies[vertexIndex+1],
-				p.Verticies[vertexIndex+2])
-
-			// TODO: Remove test color
-			red := uint8(0)
-			green := uint8(0)
-			blue := uint8(0)
-			if i == 0 || i == 3 {
-				red = uint8(255 / (j + 1))
-			}
-			if i == 1 || i == 4 {
-				green = uint8(255 / (j + 1))
-			}
-			if i == 2 || i == 5 {
-				blue = uint8(255 / (j + 1))
-			}
-
-			triangle := NewTriangle("", *v0, *v1, *v2, color.RGBA{red, green, blue, 1}, 1, 1, false)
-			triangles[triangleIndex] = *triangle
-
-			// Increment our various indecies
-			indexIntoVertexIndex += 3
+	for _, corners := range faces {
+		for j := 0; j*3 < len(corners); j++ {
+			triangles[triangleIndex] = *p.generateTriangle(corners[j*3:j*3+3], col, refractive)
 			triangleIndex++
 		}
 	}
@@ -489,77 +592,87 @@ func (p *PolygonMesh) ConvertPolygonSerial() []Triangle {
 }
 
 // ConvertPolygonParallel converts data in PolygonMesh
-// into an array of triangles using a parallel strategy
-func (p *PolygonMesh) ConvertPolygonParallel() []Triangle {
+// into an array of triangles using a parallel strategy. Each face is
+// converted in its own goroutine into its place in the array, so the
+// triangles come out in the same order as from ConvertPolygonSerial
+func (p *PolygonMesh) ConvertPolygonParallel(col color.RGBA, refractive float64) []Triangle {
 	start := time.Now()
 
+	faces := p.faceTriangles()
 	totalTriangles := 0
-	for _, v := range p.NumVerticies {
-		totalTriangles += (v - 2)
+	for _, corners := range faces {
+		totalTriangles += len(corners) / 3
 	}
 
 	// Create slice
-	triangles := make([]Triangle, 0, totalTriangles)
+	triangles := make([]Triangle, totalTriangles, totalTriangles)
 
 	// Create channel queue
-	trianglesChan := make(chan *Triangle, totalTriangles)
+	done := make(chan bool, len(faces))
 
-	indexIntoVertexIndex := 0
-	for i := 0; i < p.NumFaces[0]; i++ {
-		numTriangles := p.NumVerticies[i] - 2
-		for j := 0; j < numTriangles; j++ {
-
-			go p.generateTriangle(i, j, indexIntoVertexIndex, trianglesChan)
+	triangleIndex := 0
+	for _, corners := range faces {
+		go func(triangleIndex int, corners []int) {
+			for j := 0; j*3 < len(corners); j++ {
+				triangles[triangleIndex+j] = *p.generateTriangle(corners[j*3:j*3+3], col, refractive)
+			}
+			done <- true
+		}(triangleIndex, corners)
 
-			// Increment our various indecies
-			indexIntoVertexIndex += 3
-		}
+		// Increment our various indecies
+		triangleIndex += len(corners) / 3
 	}
 
-	for k := 0; k < totalTriangles; k++ {
-		triangles = append(triangles, *<-trianglesChan)
+	for range faces {
+		<-done
 	}
-	//fmt.Println(triangles)
 	elapsed := time.Since(start)
 	fmt.Println(elapsed)
 	return triangles
 }
 
-func (p *PolygonMesh) generateTriangle(i, j, indexIntoVertexIndex int, trianglesChan chan *Triangle) {
-	var vertexIndex int
-	vertexIndex = p.VertexIndecies[indexIntoVertexIndex] * 3
-	v0 := vec.NewVec3(
-		p.Verticies[vertexIndex],
-		p.Verticies[vertexIndex+1],
-		p.Verticies[vertexIndex+2])
-
-	vertexIndex = p.VertexIndecies[indexIntoVertexIndex+1] * 3
-	v1 := vec.NewVec3(
-		p.Verticies[vertexIndex],
-		p.Verticies[vertexIndex+1],
-		p.Verticies[vertexIndex+2])
-
-	vertexIndex = p.VertexIndecies[indexIntoVertexIndex+2] * 3
-	v2 := vec.NewVec3(
-		p.Verticies[vertexIndex],
-		p.Verticies[vertexIndex+1],
-		p.Verticies[vertexIndex+2])
+// generateTriangle creates a triangle from the positions of its corners
+// in VertexIndecies. It is smooth shaded when all three corners have
+// vertex normals, and takes the texture coordinates of the corners when
+// all three have them
+func (p *PolygonMesh) generateTriangle(corners []int, col color.RGBA, refractive float64) *Triangle {
+	var v [3]vec.Vec3
+	for k, c := range corners {
+		i := p.VertexIndecies[c] * 3
+		v[k] = *vec.NewVec3(p.Verticies[i], p.Verticies[i+1], p.Verticies[i+2])
+	}
 
-	// TODO: Remove test color
-	red := uint8(0)
-	green := uint8(0)
-	blue := uint8(0)
-	if i == 0 || i == 3 {
-		red = uint8(255 / (j + 1))
+	// attribute returns the attribute index of every corner, or nil if
+	// a corner has none
+	attribute := func(indecies []int, numValues int) []int {
+		if len(indecies) != len(p.VertexIndecies) {
+			return nil
+		}
+		values := make([]int, len(corners))
+		for k, c := range corners {
+			if indecies[c] < 0 || indecies[c] >= numValues {
+				return nil
+			}
+			values[k] = indecies[c]
+		}
+		return values
 	}
-	if i == 1 || i == 4 {
-		green = uint8(255 / (j + 1))
+
+	var t *Triangle
+	if n := attribute(p.NormalIndecies, len(p.VertexNormals)/3); n != nil {
+		normal := func(i int) vec.Vec3 {
+			return *vec.NewVec3(p.VertexNormals[i*3], p.VertexNormals[i*3+1], p.VertexNormals[i*3+2])
+		}
+		t = NewSmoothTriangle("", v[0], v[1], v[2], normal(n[0]), normal(n[1]), normal(n[2]), col, refractive, false)
+	} else {
+		t = NewTriangle("", v[0], v[1], v[2], col, refractive, false)
 	}
-	if i == 2 || i == 5 {
-		blue = uint8(255 / (j + 1))
+	if uv := attribute(p.TextureIndecies, len(p.TextureVertecies)/2); uv != nil {
+		for k, i := range uv {
+			t.UVs[k] = [2]float64{p.TextureVertecies[i*2], p.TextureVertecies[i*2+1]}
+		}
 	}
-
-	trianglesChan <- NewTriangle("", *v0, *v1, *v2, color.RGBA{red, green, blue, 1}, 1, 1, false)
+	return t
 }
 
 // String stringifies triangles
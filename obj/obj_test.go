@@ -23,8 +23,8 @@ func TestCameraRayIntersection1D(t *testing.T) {
 	t.Parallel()
 
 	center := vec.NewVec3(0, 0, -3)
-	sphere := Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1, 1.0}
-	ray := cam.NewRay("A", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	sphere := Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1.0}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
 	isHit, hit, n, t0, t1 := sphere.Intersects(ray)
 
 	if !isHit {
@@ -49,8 +49,8 @@ func TestTransmissionRayIntersection1DHeadOn(t *testing.T) {
 	t.Parallel()
 
 	center := vec.NewVec3(0, 0, -3)
-	sphere := Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1, 1.2}
-	ray := cam.NewRay("A", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	sphere := Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1.2}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
 	_, hit, n, _, _ := sphere.Intersects(ray)
 
 	internal_dir := RefractionVector(
@@ -63,7 +63,7 @@ func TestTransmissionRayIntersection1DHeadOn(t *testing.T) {
 		t.Error("Internal refracted direction is incorrect")
 	}
 
-	ref_ray := cam.NewRay("B", "refraction", &hit, &internal_dir)
+	ref_ray := cam.NewRay(2, "refraction", &hit, &internal_dir)
 	isHit2, hit2, n2, _, _ := sphere.Intersects(ref_ray)
 
 	if !isHit2 {
@@ -84,7 +84,7 @@ func TestTransmissionRayIntersection1DHeadOn(t *testing.T) {
 		t.Error("External refracted direction is incorrect")
 	}
 
-	trans_ray := cam.NewRay("C", "transmission", &hit2, &external_dir)
+	trans_ray := cam.NewRay(3, "transmission", &hit2, &external_dir)
 	world_trans_ray, _ := world.NewTransmittedRay(ray, hit, n, sphere)
 
 	if !cam.IsEqual(trans_ray, world_trans_ray) {
@@ -98,8 +98,8 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 
 	world := NewWorld()
 	center := vec.NewVec3(0.5, 0, -3)
-	sphere := obj.Sphere{"sphere1", *center, 1, color.RGBA{0, 0, 255, 1}, 1, 1.2}
-	ray := cam.NewRay("A", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	sphere := obj.Sphere{ID: "sphere1", Center: *center, Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1.2}
+	ray := cam.NewRay(1, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
 	_, hit, n, _, _ := sphere.Intersects(ray)
 
 	internal_dir := RefractionVector(
@@ -112,7 +112,7 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 		t.Error("Internal refracted direction is incorrect")
 	}
 
-	ref_ray := cam.NewRay("B", "refraction", &hit, &internal_dir)
+	ref_ray := cam.NewRay(2, "refraction", &hit, &internal_dir)
 	isHit2, hit2, n2, _, _ := sphere.Intersects(ref_ray)
 
 	if !isHit2 {
@@ -133,7 +133,7 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 		t.Error("External refracted direction is incorrect")
 	}
 
-	trans_ray := cam.Ray{"C", "transmitted", hit2, external_dir}
+	trans_ray := cam.Ray{ID: 3, Type: "transmitted", Origin: hit2, Direction: external_dir}
 	world_trans_ray, _ := world.NewTransmittedRay(ray, hit, n, sphere)
 	fmt.Println(ray)
 	fmt.Println(ref_ray)
@@ -147,9 +147,9 @@ func TestTransmissionRayIntersection1DAtAngle(t *testing.T) {
 func TestLightIntersection1D(t *testing.T) {
 	t.Parallel()
 
-	ray := cam.NewRay("noid", "shadow", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	ray := cam.NewRay(0, "shadow", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
 	center := vec.NewVec3(0, 0, -3)
-	light := Light{"light1", *center, 1, 1, color.RGBA{255, 255, 255, 1}}
+	light := SphereLight{"light1", *center, 1, 1, color.RGBA{255, 255, 255, 1}}
 
 	hit, dist := light.Intersects(ray)
 	if !hit || dist != 2.0 {
@@ -162,9 +162,9 @@ func TestLightIntersection2D(t *testing.T) {
 
 	dir := vec.NewVec3(0, 1, -1)
 	dir.Normalize()
-	ray := cam.NewRay("noid", "shadow", vec.NewVec3(0, 0, 0), dir)
+	ray := cam.NewRay(0, "shadow", vec.NewVec3(0, 0, 0), dir)
 	center := vec.NewVec3(0, 2, -3)
-	light := Light{"light1", *center, 1, 1, color.RGBA{255, 255, 255, 1}}
+	light := SphereLight{"light1", *center, 1, 1, color.RGBA{255, 255, 255, 1}}
 
 	hit, dist := light.Intersects(ray)
 	if !hit {
@@ -186,19 +186,19 @@ func TestLightIntersectionByReflectedRay(t *testing.T) {
 
 	dir := vec.NewVec3(0, 1, -1)
 	dir.Normalize()
-	ray := cam.NewRay("noid", "camera", vec.NewVec3(0, 0, 0), dir)
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), dir)
 	center1 := vec.NewVec3(0, 5, 0)
 	center2 := vec.NewVec3(0, 2, -3)
-	light := Light{"light1", *center1, 1, 1, color.RGBA{255, 255, 255, 1}}
-	sphere := Sphere{"sphere1", *center2, 1, color.RGBA{255, 255, 255, 1}, 1, 1}
+	light := SphereLight{"light1", *center1, 1, 1, color.RGBA{255, 255, 255, 1}}
+	sphere := Sphere{"sphere1", *center2, 1, color.RGBA{255, 255, 255, 1}, 1}
 
-	is_hit, hit, n, t0, _ := sphere.Intersects(ray)
-	if !is_hit || !AlmostEqual(t0, 2.828, 0.001) {
+	rec, is_hit := sphere.Intersects(ray)
+	if !is_hit || !AlmostEqual(rec.T0, 2.828, 0.001) {
 		t.Error("Shadow ray did not intersect with light")
 	}
 
-	reflected_dir := vec.Reflect(hit, n)
-	shadow_ray := cam.NewRay("noid", "shadow", &hit, &reflected_dir)
+	reflected_dir := vec.Reflect(rec.Point, rec.Normal)
+	shadow_ray := cam.NewRay(0, "shadow", &rec.Point, &reflected_dir)
 	is_hit2, dist := light.Intersects(shadow_ray)
 
 	if !is_hit2 || !AlmostEqual(dist, 2.828, 0.001) {
@@ -215,7 +215,7 @@ func TestTriangleInit(t *testing.T) {
 	v0 := vec.NewVec3(0, 0, -1)
 	v1 := vec.NewVec3(1, 1, -1)
 	v2 := vec.NewVec3(-1, 1, -1)
-	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, 1, false)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, false)
 
 	e0 := vec.Subtract(*v1, *v0)
 	e1 := vec.Subtract(*v2, *v1)
@@ -246,11 +246,12 @@ func TestTriangleIntersects(t *testing.T) {
 	v0 := vec.NewVec3(0, -1, -1)
 	v1 := vec.NewVec3(1, 1, -1)
 	v2 := vec.NewVec3(-1, 1, -1)
-	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, 1, false)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, false)
 
-	ray := cam.Ray{"", "camera", *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, -1)}
+	ray := cam.Ray{Type: "camera", Origin: *vec.NewVec3(0, 0, 0), Direction: *vec.NewVec3(0, 0, -1)}
 
-	is_hit, p, n, t0, _ := tri.Intersects(&ray)
+	rec, is_hit := tri.Intersects(&ray)
+	p, n, t0 := rec.Point, rec.Normal, rec.T0
 
 	i_p := *vec.NewVec3(0, 0, -1)
 	i_n := *vec.NewVec3(0, 0, 1)
@@ -279,13 +280,70 @@ func TestTriangleCullBackface(t *testing.T) {
 	v0 := vec.NewVec3(0, -1, -1)
 	v1 := vec.NewVec3(-1, 1, -1)
 	v2 := vec.NewVec3(1, 1, -1)
-	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, 1, true)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, true)
 
-	ray := cam.Ray{"", "camera", *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 0, -1)}
+	ray := cam.Ray{Type: "camera", Origin: *vec.NewVec3(0, 0, 0), Direction: *vec.NewVec3(0, 0, -1)}
 
-	is_hit, _, _, _, _ := tri.Intersects(&ray)
+	_, is_hit := tri.Intersects(&ray)
 
 	if is_hit == true {
 		t.Error("Triangle should not have hit")
 	}
 }
+
+func TestTriangleCullFrontface(t *testing.T) {
+	t.Parallel()
+
+	v0 := vec.NewVec3(0, -1, -1)
+	v1 := vec.NewVec3(1, 1, -1)
+	v2 := vec.NewVec3(-1, 1, -1)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, true)
+
+	ray := cam.Ray{Type: "camera", Origin: *vec.NewVec3(0, 0, 0), Direction: *vec.NewVec3(0, 0, -1)}
+
+	if _, is_hit := tri.Intersects(&ray); !is_hit {
+		t.Error("Front facing triangle should hit with culling enabled")
+	}
+}
+
+func TestTriangleSharedEdgeIsWatertight(t *testing.T) {
+	t.Parallel()
+
+	// Two triangles share the diagonal edge (-1, -1) -> (1, 1)
+	v0 := vec.NewVec3(-1, -1, -3)
+	v1 := vec.NewVec3(1, -1, -3)
+	v2 := vec.NewVec3(1, 1, -3)
+	v3 := vec.NewVec3(-1, 1, -3)
+	tri1 := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, false)
+	tri2 := NewTriangle("tri2", *v0, *v2, *v3, color.RGBA{0, 0, 0, 1}, 1, false)
+
+	for i := 0; i < 100; i++ {
+		p := -0.9 + float64(i)*0.018
+		dir := vec.NewVec3(p, p, -3)
+		dir.Normalize()
+		ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), dir)
+
+		_, hit1 := tri1.Intersects(ray)
+		_, hit2 := tri2.Intersects(ray)
+		if !hit1 && !hit2 {
+			t.Error("Ray slipped through the shared edge")
+		}
+	}
+}
+
+func TestTriangleIntersectsSmallTriangle(t *testing.T) {
+	t.Parallel()
+
+	// Fixed determinant tolerances reject triangles this small
+	v0 := vec.NewVec3(0, -0.0001, -100)
+	v1 := vec.NewVec3(0.0001, 0.0001, -100)
+	v2 := vec.NewVec3(-0.0001, 0.0001, -100)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, false)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := tri.Intersects(ray)
+
+	if !is_hit || math.Abs(rec.T0-100) > 0.0001 {
+		t.Error("Small triangle was not hit")
+	}
+}
@@ -0,0 +1,37 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// OffsetRayOrigin returns the origin for a ray leaving the hit in
+// direction w. The hit point is pushed along the geometric normal just
+// far enough to clear its error bound, on the side of the surface w points
+// to, so the new ray cannot hit the surface it started on. Every
+// reflected, refracted and shadow ray should start here
+func OffsetRayOrigin(rec HitRecord, w vec.Vec3) vec.Vec3 {
+	p := rec.Point
+	n := rec.GeometricNormal
+	err := rec.Error
+	d := math.Abs(n.X)*err.X + math.Abs(n.Y)*err.Y + math.Abs(n.Z)*err.Z
+
+	offset := vec.Multiply(n, d)
+	if vec.Dot(w, n) < 0 {
+		offset = vec.Invert(offset)
+	}
+
+	// Round away from p so the offset is not lost when it is added
+	org := [3]float64{p.X + offset.X, p.Y + offset.Y, p.Z + offset.Z}
+	off := [3]float64{offset.X, offset.Y, offset.Z}
+	for i := range org {
+		if off[i] > 0 {
+			org[i] = math.Nextafter(org[i], math.Inf(1))
+		} else if off[i] < 0 {
+			org[i] = math.Nextafter(org[i], math.Inf(-1))
+		}
+	}
+
+	return *vec.NewVec3(org[0], org[1], org[2])
+}
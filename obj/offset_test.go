@@ -0,0 +1,63 @@
+package obj
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestOffsetRayOriginSide(t *testing.T) {
+	t.Parallel()
+
+	sphere := Sphere{"sphere1", *vec.NewVec3(0, 0, -3), 1, color.RGBA{0, 0, 255, 1}, 1}
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, _ := sphere.Intersects(ray)
+	p := rec.Point
+
+	out := OffsetRayOrigin(rec, *vec.NewVec3(0, 0, 1))
+	in := OffsetRayOrigin(rec, *vec.NewVec3(0, 0, -1))
+
+	if out.Z <= p.Z || in.Z >= p.Z {
+		t.Error("Origin should be offset to the side the ray leaves on")
+	}
+
+	if out.X != 0 || out.Y != 0 {
+		t.Error("Origin should only move along the normal")
+	}
+}
+
+func TestOffsetRayOriginAvoidsSelfIntersection(t *testing.T) {
+	t.Parallel()
+
+	// A tilted triangle far from the origin is prone to shadow acne
+	v0 := vec.NewVec3(1000, -1000, -1000)
+	v1 := vec.NewVec3(1000.5, -999, -1000.3)
+	v2 := vec.NewVec3(999, -999.7, -1000.1)
+	tri := NewTriangle("tri1", *v0, *v1, *v2, color.RGBA{0, 0, 0, 1}, 1, false)
+
+	failures := 0
+	for i := 0; i < 200; i++ {
+		target := interpolateBarycentric(*v0, *v1, *v2, 0.1+float64(i)*0.003, 0.2)
+		dir := vec.Subtract(target, *vec.NewVec3(0, 0, 0))
+		dir.Normalize()
+		ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), &dir)
+
+		rec, is_hit := tri.Intersects(ray)
+		if !is_hit {
+			continue
+		}
+
+		reflected := vec.Reflect(ray.Direction, rec.Normal)
+		org := OffsetRayOrigin(rec, reflected)
+		shadow := cam.NewChildRay(ray, "shadow", &org, &reflected)
+		if _, is_hit2 := tri.Intersects(shadow); is_hit2 {
+			failures++
+		}
+	}
+
+	if failures > 0 {
+		t.Errorf("Spawned rays hit their own surface %v times", failures)
+	}
+}
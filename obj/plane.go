@@ -0,0 +1,135 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Plane is a flat surface through Point facing Normal. It is infinite
+// unless Width and Height are set, in which case it is a rectangle of
+// that size centered on Point. UAxis and VAxis span the plane and define
+// its planar texture coordinates
+type Plane struct {
+	ID              string
+	Point           vec.Vec3
+	Normal          vec.Vec3
+	UAxis           vec.Vec3
+	VAxis           vec.Vec3
+	Width           float64
+	Height          float64
+	Col             color.RGBA
+	RefractiveIndex float64
+}
+
+// NewPlane is a constructor for infinite planes. The normal does not
+// need to be normalized
+func NewPlane(id string, point, normal vec.Vec3, col color.RGBA, refractive float64) *Plane {
+	p := new(Plane)
+	p.ID = id
+	p.Point = point
+	p.Normal = vec.Divide(normal, normal.Magnitude)
+	p.UAxis, p.VAxis = tangentFrame(p.Normal)
+	p.Col = col
+	p.RefractiveIndex = refractive
+	return p
+}
+
+// NewFinitePlane is a constructor for a width x height rectangle centered
+// on point. The width is measured along uAxis, which is projected into the
+// plane, and the height along the axis perpendicular to it
+func NewFinitePlane(id string, point, normal, uAxis vec.Vec3, width, height float64, col color.RGBA, refractive float64) *Plane {
+	p := NewPlane(id, point, normal, col, refractive)
+	p.UAxis, p.VAxis = alignTangentFrame(p.Normal, uAxis)
+	p.Width = width
+	p.Height = height
+	return p
+}
+
+// IsFinite reports whether the plane has a limited extent
+func (p *Plane) IsFinite() bool {
+	return p.Width > 0 && p.Height > 0
+}
+
+// GetID is the object specific method to return the ID of the plane
+func (p *Plane) GetID() string {
+	return p.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (p *Plane) GetColor() color.RGBA {
+	return p.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (p *Plane) GetRefractiveIndex() float64 {
+	return p.RefractiveIndex
+}
+
+// Bounds returns the bounding box of a finite plane. Infinite planes have
+// infinite bounds and should be added to a scene directly rather than
+// placed in a BVH
+func (p *Plane) Bounds() AABB {
+	if !p.IsFinite() {
+		inf := math.Inf(1)
+		return AABB{*vec.NewVec3(-inf, -inf, -inf), *vec.NewVec3(inf, inf, inf)}
+	}
+
+	u := vec.Multiply(p.UAxis, p.Width*0.5)
+	v := vec.Multiply(p.VAxis, p.Height*0.5)
+	b := NewAABB(vec.Add(p.Point, vec.Add(u, v)), vec.Subtract(p.Point, vec.Add(u, v)))
+	b = b.Extend(vec.Add(p.Point, vec.Subtract(u, v)))
+	return b.Extend(vec.Subtract(p.Point, vec.Subtract(u, v)))
+}
+
+// Intersects checks for intersections between a ray and the plane.
+// Texture coordinates are the distances along UAxis and VAxis from Point
+// for infinite planes, and run from 0 to 1 across finite planes
+func (p *Plane) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	dir := ray.Direction
+	dir.Normalize()
+
+	denominator := vec.Dot(p.Normal, dir)
+	if denominator == 0 {
+		// Ray is parallel to the plane
+		return FalseObject()
+	}
+
+	t0 := vec.Dot(vec.Subtract(p.Point, ray.Origin), p.Normal) / denominator
+	if t0 <= 0 {
+		// Plane is behind ray
+		return FalseObject()
+	}
+
+	// Project the hit back onto the plane to remove most of the error
+	// of stepping along the ray
+	hit := vec.Add(ray.Origin, vec.Multiply(dir, t0))
+	local := vec.Subtract(hit, p.Point)
+	local = vec.Subtract(local, vec.Multiply(p.Normal, vec.Dot(local, p.Normal)))
+	hit = vec.Add(p.Point, local)
+
+	u := vec.Dot(local, p.UAxis)
+	v := vec.Dot(local, p.VAxis)
+	if p.IsFinite() {
+		if math.Abs(u) > p.Width*0.5 || math.Abs(v) > p.Height*0.5 {
+			return FalseObject()
+		}
+		u = u/p.Width + 0.5
+		v = v/p.Height + 0.5
+	}
+
+	rec := newHitRecord(p, dir, hit, p.Normal, t0, t0)
+	rec.U = u
+	rec.V = v
+	rec.Tangent = p.UAxis
+	rec.Bitangent = p.VAxis
+	rec.Error = *vec.NewVec3(
+		gamma(7)*math.Max(math.Abs(hit.X), math.Abs(p.Point.X)),
+		gamma(7)*math.Max(math.Abs(hit.Y), math.Abs(p.Point.Y)),
+		gamma(7)*math.Max(math.Abs(hit.Z), math.Abs(p.Point.Z)))
+	return rec, true
+}
@@ -0,0 +1,81 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestPlaneIntersects(t *testing.T) {
+	t.Parallel()
+
+	ground := NewPlane("ground", *vec.NewVec3(0, -1, 0), *vec.NewVec3(0, 2, 0), color.RGBA{0, 255, 0, 1}, 1)
+
+	dir := vec.NewVec3(0, -1, -1)
+	dir.Normalize()
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), dir)
+	rec, is_hit := ground.Intersects(ray)
+
+	if !is_hit {
+		t.Fatal("Plane was not hit")
+	}
+
+	if math.Abs(rec.T0-math.Sqrt2) > 1e-9 || rec.Point.Y != -1 || math.Abs(rec.Point.Z+1) > 1e-9 {
+		t.Error("Hit location was not correct")
+	}
+
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 1, 0)) || !rec.FrontFace {
+		t.Error("N vector not correct")
+	}
+
+	up := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 1, 0))
+	if _, is_hit := ground.Intersects(up); is_hit {
+		t.Error("Ray pointing away from the plane should not hit")
+	}
+
+	parallel := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(1, 0, 0))
+	if _, is_hit := ground.Intersects(parallel); is_hit {
+		t.Error("Ray parallel to the plane should not hit")
+	}
+}
+
+func TestFinitePlaneExtentAndUV(t *testing.T) {
+	t.Parallel()
+
+	panel := NewFinitePlane(
+		"panel",
+		*vec.NewVec3(0, 0, -5),
+		*vec.NewVec3(0, 0, 1),
+		*vec.NewVec3(1, 0, 0),
+		4, 2,
+		color.RGBA{255, 255, 255, 1},
+		1)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(1, 0.5, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := panel.Intersects(ray)
+
+	if !is_hit {
+		t.Fatal("Finite plane was not hit")
+	}
+
+	if math.Abs(rec.U-0.75) > 1e-9 || math.Abs(rec.V-0.75) > 1e-9 {
+		t.Error("Planar texture coordinates are not correct")
+	}
+
+	outside := cam.NewRay(0, "camera", vec.NewVec3(0, 1.5, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := panel.Intersects(outside); is_hit {
+		t.Error("Ray outside of the extent should not hit")
+	}
+
+	b := panel.Bounds()
+	if b.Min.X != -2 || b.Max.X != 2 || b.Min.Y != -1 || b.Max.Y != 1 {
+		t.Error("Bounds of the finite plane are not correct")
+	}
+
+	if NewPlane("p", *vec.NewVec3(0, 0, 0), *vec.NewVec3(0, 1, 0), color.RGBA{}, 1).Bounds().IsFinite() {
+		t.Error("Infinite plane should have infinite bounds")
+	}
+}
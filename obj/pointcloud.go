@@ -0,0 +1,229 @@
+package obj
+
+import (
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// PointShape is what each point of a PointCloud is drawn as
+type PointShape int
+
+const (
+	// PointSphere draws every point as a sphere
+	PointSphere PointShape = iota
+	// PointDisk draws every point as a disk across its normal, or facing
+	// the ray when the cloud has no normals
+	PointDisk
+)
+
+// PointSet is a container for scanned point data and is not a true
+// object. Normals and Colors are optional, and have one entry per point
+// when present
+type PointSet struct {
+	Points  []float64 // x, y, z of each point
+	Normals []float64 // x, y, z of each normal
+	Colors  []color.RGBA
+}
+
+// NumPoints returns the number of points in the set
+func (s *PointSet) NumPoints() int {
+	return len(s.Points) / 3
+}
+
+// PointCloud is a single object drawing every point of a PointSet as a
+// small sphere or disk of Radius. Like TriangleMesh it keeps the point
+// buffers and builds a BVH over the points by index
+type PointCloud struct {
+	ID              string
+	Points          []float64
+	Normals         []float64
+	Colors          []color.RGBA
+	Radius          float64
+	Shape           PointShape
+	Col             color.RGBA
+	RefractiveIndex float64
+	tree            *bvhTree
+}
+
+// NewPointCloud creates a point cloud object that shares the buffers of
+// the PointSet. It returns an error if the set has normals or colors but
+// not one for every point
+func NewPointCloud(id string, set *PointSet, radius float64, shape PointShape, col color.RGBA, refractive float64) (*PointCloud, error) {
+	if len(set.Points)%3 != 0 {
+		return nil, errors.New("point set positions are not in threes")
+	}
+	if len(set.Normals) != 0 && len(set.Normals) != len(set.Points) {
+		return nil, errors.New("point set does not have a normal for every point")
+	}
+	if len(set.Colors) != 0 && len(set.Colors) != set.NumPoints() {
+		return nil, errors.New("point set does not have a color for every point")
+	}
+
+	c := new(PointCloud)
+	c.ID = id
+	c.Points = set.Points
+	if len(set.Normals) != 0 {
+		c.Normals = set.Normals
+	}
+	if len(set.Colors) != 0 {
+		c.Colors = set.Colors
+	}
+	c.Radius = radius
+	c.Shape = shape
+	c.Col = col
+	c.RefractiveIndex = refractive
+
+	bounds := make([]AABB, c.NumPoints(), c.NumPoints())
+	pad := *vec.NewVec3(radius, radius, radius)
+	for i := range bounds {
+		p := c.Point(i)
+		bounds[i] = NewAABB(vec.Subtract(p, pad), vec.Add(p, pad))
+	}
+	c.tree = newBVHTree(bounds)
+	return c, nil
+}
+
+// NumPoints returns the number of points in the cloud
+func (c *PointCloud) NumPoints() int {
+	return len(c.Points) / 3
+}
+
+// Point returns the position of point i
+func (c *PointCloud) Point(i int) vec.Vec3 {
+	return *vec.NewVec3(c.Points[i*3], c.Points[i*3+1], c.Points[i*3+2])
+}
+
+// GetID is the object specific method to return the ID of the point cloud
+func (c *PointCloud) GetID() string {
+	return c.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (c *PointCloud) GetColor() color.RGBA {
+	return c.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (c *PointCloud) GetRefractiveIndex() float64 {
+	return c.RefractiveIndex
+}
+
+// Bounds returns the axis-aligned bounding box of the point cloud
+func (c *PointCloud) Bounds() AABB {
+	return c.tree.bounds()
+}
+
+// Intersects returns the closest intersection with any of the points.
+// When the cloud has per point colors the record refers to the CloudPoint
+// that was hit, so it is shaded with that point's color
+func (c *PointCloud) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	var rec HitRecord
+	closest, _ := c.tree.traverse(ray, math.Inf(1), func(i int, maxDist float64) (float64, bool) {
+		r, isHit := c.intersectPoint(ray, i)
+		if !isHit || r.T0 <= 0 || r.T0 >= maxDist {
+			return 0, false
+		}
+		rec = r
+		return r.T0, true
+	})
+
+	if closest < 0 {
+		return FalseObject()
+	}
+	if c.Colors != nil {
+		rec.Object = CloudPoint{c, closest}
+	} else {
+		rec.Object = c
+	}
+	return rec, true
+}
+
+// intersectPoint intersects the sphere or disk of point i
+func (c *PointCloud) intersectPoint(ray *cam.Ray, i int) (HitRecord, bool) {
+	center := c.Point(i)
+	if c.Shape == PointSphere {
+		return Sphere{c.ID, center, c.Radius, c.Col, c.RefractiveIndex}.Intersects(ray)
+	}
+
+	dir := ray.Direction
+	dir.Normalize()
+	n := vec.Invert(dir)
+	if c.Normals != nil {
+		n = *vec.NewVec3(c.Normals[i*3], c.Normals[i*3+1], c.Normals[i*3+2])
+		if n.Magnitude == 0 {
+			return FalseObject()
+		}
+		n = vec.Divide(n, n.Magnitude)
+	}
+
+	dn := vec.Dot(dir, n)
+	if dn == 0 {
+		return FalseObject()
+	}
+	t := vec.Dot(vec.Subtract(center, ray.Origin), n) / dn
+	p := vec.Add(ray.Origin, vec.Multiply(dir, t))
+
+	// Reproject onto the plane of the disk
+	offset := vec.Subtract(p, center)
+	offset = vec.Subtract(offset, vec.Multiply(n, vec.Dot(offset, n)))
+	if offset.Magnitude > c.Radius {
+		return FalseObject()
+	}
+	p = vec.Add(center, offset)
+
+	rec := newHitRecord(c, dir, p, n, t, t)
+	x, y := vec.Dot(offset, rec.Tangent), vec.Dot(offset, rec.Bitangent)
+	rec.U = sphericalAngle(x, y) / (2 * math.Pi)
+	rec.V = offset.Magnitude / c.Radius
+	rec.Error = localError(p, 7)
+	return rec, true
+}
+
+// CloudPoint is a single point of a PointCloud. It is the object of hits
+// on clouds with per point colors
+type CloudPoint struct {
+	Cloud *PointCloud
+	Index int
+}
+
+// GetID returns the ID of the cloud the point belongs to
+func (p CloudPoint) GetID() string {
+	return p.Cloud.ID
+}
+
+// GetColor returns the color of the point
+func (p CloudPoint) GetColor() color.RGBA {
+	if p.Cloud.Colors == nil {
+		return p.Cloud.Col
+	}
+	return p.Cloud.Colors[p.Index]
+}
+
+// GetRefractiveIndex returns the refractive index of the cloud
+func (p CloudPoint) GetRefractiveIndex() float64 {
+	return p.Cloud.RefractiveIndex
+}
+
+// Bounds returns the box around the point's sphere or disk
+func (p CloudPoint) Bounds() AABB {
+	r := p.Cloud.Radius
+	pad := *vec.NewVec3(r, r, r)
+	center := p.Cloud.Point(p.Index)
+	return NewAABB(vec.Subtract(center, pad), vec.Add(center, pad))
+}
+
+// Intersects checks for intersections between a ray and the point alone
+func (p CloudPoint) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	rec, isHit := p.Cloud.intersectPoint(ray, p.Index)
+	if !isHit {
+		return FalseObject()
+	}
+	rec.Object = p
+	return rec, true
+}
@@ -0,0 +1,109 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func makePointSet() *PointSet {
+	set := new(PointSet)
+	for i := 0; i < 10; i++ {
+		set.Points = append(set.Points, float64(i), 0, -5)
+		set.Normals = append(set.Normals, 0, 0, 1)
+		set.Colors = append(set.Colors, color.RGBA{uint8(i), 0, 0, 255})
+	}
+	return set
+}
+
+func TestPointCloudSpheres(t *testing.T) {
+	t.Parallel()
+
+	set := makePointSet()
+	set.Colors = nil
+	cloud, err := NewPointCloud("cloud", set, 0.25, PointSphere, color.RGBA{0, 255, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(3, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := cloud.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-4.75) > 1e-9 {
+		t.Fatal("Point sphere not hit correctly", rec.T0)
+	}
+	if rec.Object != cloud {
+		t.Error("Without colors hits should report the cloud")
+	}
+
+	// Along the row of points the nearest one is hit
+	along := cam.NewRay(0, "camera", vec.NewVec3(20, 0, -5), vec.NewVec3(-1, 0, 0))
+	rec, is_hit = cloud.Intersects(along)
+	if !is_hit || math.Abs(rec.T0-10.75) > 1e-9 {
+		t.Error("Nearest point not hit", rec.T0)
+	}
+
+	between := cam.NewRay(0, "camera", vec.NewVec3(3.5, 0, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := cloud.Intersects(between); is_hit {
+		t.Error("Ray between the points should miss")
+	}
+}
+
+func TestPointCloudDisks(t *testing.T) {
+	t.Parallel()
+
+	cloud, err := NewPointCloud("cloud", makePointSet(), 0.25, PointDisk, color.RGBA{0, 255, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(7.2, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := cloud.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-5) > 1e-9 {
+		t.Fatal("Point disk not hit correctly", rec.T0)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) || math.Abs(rec.V-0.8) > 1e-9 {
+		t.Error("Disk hit not correct", rec.Normal, rec.V)
+	}
+	if rec.Object.GetColor() != (color.RGBA{7, 0, 0, 255}) {
+		t.Error("Hit should carry the color of the point", rec.Object.GetColor())
+	}
+
+	// Disks are flat, so rays along the row miss them
+	along := cam.NewRay(0, "camera", vec.NewVec3(20, 0, -5), vec.NewVec3(-1, 0, 0))
+	if _, is_hit := cloud.Intersects(along); is_hit {
+		t.Error("Ray in the plane of the disks should miss")
+	}
+
+	// Without normals the disks turn to face the ray
+	set := makePointSet()
+	set.Normals = nil
+	splats, err := NewPointCloud("splats", set, 0.25, PointDisk, color.RGBA{0, 255, 0, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, is_hit = splats.Intersects(along)
+	if !is_hit || math.Abs(rec.T0-11) > 1e-9 || !vec.IsEqual(rec.Normal, *vec.NewVec3(1, 0, 0)) {
+		t.Error("Splat should face the ray", rec.T0, rec.Normal)
+	}
+}
+
+func TestPointCloudMismatchedAttributes(t *testing.T) {
+	t.Parallel()
+
+	// Attribute buffers that do not cover every point are rejected rather
+	// than dropped
+	set := makePointSet()
+	set.Colors = set.Colors[1:]
+	if _, err := NewPointCloud("cloud", set, 0.25, PointSphere, color.RGBA{0, 255, 0, 1}, 1); err == nil {
+		t.Error("Too few colors should fail")
+	}
+
+	set = makePointSet()
+	set.Normals = set.Normals[3:]
+	if _, err := NewPointCloud("cloud", set, 0.25, PointSphere, color.RGBA{0, 255, 0, 1}, 1); err == nil {
+		t.Error("Too few normals should fail")
+	}
+}
@@ -0,0 +1,208 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestSolveQuartic(t *testing.T) {
+	t.Parallel()
+
+	// (t - 1)(t - 2)(t + 3)(t - 4) = t^4 - 4t^3 - 7t^2 + 34t - 24
+	roots := solveQuartic([5]float64{-24, 34, -7, -4, 1})
+	expected := []float64{-3, 1, 2, 4}
+
+	if len(roots) != len(expected) {
+		t.Fatal("Wrong number of roots", roots)
+	}
+	for k, r := range roots {
+		if math.Abs(r-expected[k]) > 1e-9 {
+			t.Error("Root not correct", roots)
+		}
+	}
+
+	if roots := solveQuartic([5]float64{1, 0, 0, 0, 1}); len(roots) != 0 {
+		t.Error("t^4 + 1 has no real roots", roots)
+	}
+}
+
+func TestBoxIntersects(t *testing.T) {
+	t.Parallel()
+
+	box := NewBox("box", *vec.NewVec3(-1, -1, -6), *vec.NewVec3(1, 1, -4), color.RGBA{255, 0, 0, 1}, 1)
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0.5, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := box.Intersects(ray)
+
+	if !is_hit {
+		t.Fatal("Box was not hit")
+	}
+	if math.Abs(rec.T0-4) > 1e-9 || math.Abs(rec.T1-6) > 1e-9 {
+		t.Error("Hit distances were not correct", rec.T0, rec.T1)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) {
+		t.Error("N vector not correct", rec.Normal)
+	}
+	if math.Abs(rec.U-0.75) > 1e-9 || math.Abs(rec.V-0.5) > 1e-9 {
+		t.Error("UV not correct", rec.U, rec.V)
+	}
+
+	inside := cam.NewRay(0, "camera", vec.NewVec3(0, 0, -5), vec.NewVec3(1, 0, 0))
+	rec, is_hit = box.Intersects(inside)
+	if !is_hit || math.Abs(rec.T0-1) > 1e-9 || rec.FrontFace {
+		t.Error("Ray from inside should hit the back of the far face")
+	}
+
+	miss := cam.NewRay(0, "camera", vec.NewVec3(2, 0, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := box.Intersects(miss); is_hit {
+		t.Error("Ray beside the box should not hit")
+	}
+}
+
+func TestOrientedBoxBounds(t *testing.T) {
+	t.Parallel()
+
+	axis := *vec.NewVec3(0, 0, 1)
+	xAxis := *vec.NewVec3(1, 1, 0)
+	box := NewOrientedBox("box", *vec.NewVec3(0, 0, 0), *vec.NewVec3(2, 2, 2), axis, xAxis, color.RGBA{255, 0, 0, 1}, 1)
+	b := box.Bounds()
+
+	if math.Abs(b.Max.X-math.Sqrt2) > 1e-9 || math.Abs(b.Max.Z-1) > 1e-9 {
+		t.Error("Rotated bounds not correct", b)
+	}
+
+	// The rotated corner pokes out to x = sqrt(2) on the diagonal
+	ray := cam.NewRay(0, "camera", vec.NewVec3(5, 0, 0), vec.NewVec3(-1, 0, 0))
+	rec, is_hit := box.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-(5-math.Sqrt2)) > 1e-9 {
+		t.Error("Rotated box hit not correct", rec.T0)
+	}
+}
+
+func TestDiskIntersects(t *testing.T) {
+	t.Parallel()
+
+	ring := NewDisk("ring", *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), 2, 1, color.RGBA{255, 0, 0, 1}, 1)
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(1.5, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := ring.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Disk was not hit")
+	}
+	if math.Abs(rec.T0-5) > 1e-9 || !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) {
+		t.Error("Hit not correct")
+	}
+	if math.Abs(rec.V-0.5) > 1e-9 {
+		t.Error("V not correct", rec.V)
+	}
+
+	hole := cam.NewRay(0, "camera", vec.NewVec3(0.5, 0, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := ring.Intersects(hole); is_hit {
+		t.Error("Ray through the hole should not hit")
+	}
+
+	// A ring with no width can still be hit on its edge
+	edge := NewDisk("edge", *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 0, 1), 1, 1, color.RGBA{255, 0, 0, 1}, 1)
+	rim := cam.NewRay(0, "camera", vec.NewVec3(1, 0, 0), vec.NewVec3(0, 0, -1))
+	if rec, is_hit := edge.Intersects(rim); !is_hit || rec.V != 0 || math.IsNaN(rec.U) {
+		t.Error("UV on a ring with no width not correct", is_hit, rec.U, rec.V)
+	}
+}
+
+func TestCylinderIntersects(t *testing.T) {
+	t.Parallel()
+
+	cyl := NewCylinder("cyl", *vec.NewVec3(0, -1, -5), *vec.NewVec3(0, 1, 0), 1, 2, color.RGBA{255, 0, 0, 1}, 1)
+
+	side := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := cyl.Intersects(side)
+	if !is_hit {
+		t.Fatal("Cylinder side was not hit")
+	}
+	if math.Abs(rec.T0-4) > 1e-9 || math.Abs(rec.T1-6) > 1e-9 {
+		t.Error("Side hit distances not correct", rec.T0, rec.T1)
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 0, 1)) || math.Abs(rec.V-0.5) > 1e-9 {
+		t.Error("Side normal or V not correct", rec.Normal, rec.V)
+	}
+
+	top := cam.NewRay(0, "camera", vec.NewVec3(0.5, 5, -5), vec.NewVec3(0, -1, 0))
+	rec, is_hit = cyl.Intersects(top)
+	if !is_hit || math.Abs(rec.T0-4) > 1e-9 || math.Abs(rec.T1-6) > 1e-9 {
+		t.Fatal("Cylinder cap was not hit correctly")
+	}
+	if !vec.IsEqual(rec.Normal, *vec.NewVec3(0, 1, 0)) {
+		t.Error("Cap normal not correct", rec.Normal)
+	}
+
+	b := cyl.Bounds()
+	if math.Abs(b.Min.Y+1) > 1e-9 || math.Abs(b.Max.Y-1) > 1e-9 || math.Abs(b.Max.X-1) > 1e-9 {
+		t.Error("Bounds not correct", b)
+	}
+}
+
+func TestConeIntersects(t *testing.T) {
+	t.Parallel()
+
+	cone := NewCone("cone", *vec.NewVec3(0, 0, -5), *vec.NewVec3(0, 1, 0), 1, 1, color.RGBA{255, 0, 0, 1}, 1)
+
+	// Halfway up the side the radius is 0.5
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0.5, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := cone.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Cone was not hit")
+	}
+	if math.Abs(rec.T0-4.5) > 1e-9 || math.Abs(rec.T1-5.5) > 1e-9 {
+		t.Error("Hit distances not correct", rec.T0, rec.T1)
+	}
+	expected := *vec.NewVec3(0, math.Sqrt2/2, math.Sqrt2/2)
+	if math.Abs(rec.Normal.Y-expected.Y) > 1e-9 || math.Abs(rec.Normal.Z-expected.Z) > 1e-9 {
+		t.Error("N vector not correct", rec.Normal)
+	}
+
+	base := cam.NewRay(0, "camera", vec.NewVec3(0.2, -5, -5), vec.NewVec3(0, 1, 0))
+	rec, is_hit = cone.Intersects(base)
+	if !is_hit || math.Abs(rec.T0-5) > 1e-9 || !vec.IsEqual(rec.Normal, *vec.NewVec3(0, -1, 0)) {
+		t.Error("Base cap not hit correctly")
+	}
+
+	above := cam.NewRay(0, "camera", vec.NewVec3(0, 1.5, 0), vec.NewVec3(0, 0, -1))
+	if _, is_hit := cone.Intersects(above); is_hit {
+		t.Error("Ray above the apex should not hit")
+	}
+}
+
+func TestTorusIntersects(t *testing.T) {
+	t.Parallel()
+
+	torus := NewTorus("torus", *vec.NewVec3(0, 0, -10), *vec.NewVec3(0, 1, 0), 2, 0.5, color.RGBA{255, 0, 0, 1}, 1)
+
+	// Through the tube on the near side of the ring
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	rec, is_hit := torus.Intersects(ray)
+	if !is_hit {
+		t.Fatal("Torus was not hit")
+	}
+	if math.Abs(rec.T0-7.5) > 1e-9 || math.Abs(rec.T1-12.5) > 1e-9 {
+		t.Error("Hit distances not correct", rec.T0, rec.T1)
+	}
+	if math.Abs(rec.Normal.Z-1) > 1e-9 {
+		t.Error("N vector not correct", rec.Normal)
+	}
+
+	// Straight down the hole
+	hole := cam.NewRay(0, "camera", vec.NewVec3(0, 5, -10), vec.NewVec3(0, -1, 0))
+	if _, is_hit := torus.Intersects(hole); is_hit {
+		t.Error("Ray through the hole should not hit")
+	}
+
+	// From above onto the top of the tube
+	top := cam.NewRay(0, "camera", vec.NewVec3(2, 5, -10), vec.NewVec3(0, -1, 0))
+	rec, is_hit = torus.Intersects(top)
+	if !is_hit || math.Abs(rec.T0-4.5) > 1e-9 || math.Abs(rec.Normal.Y-1) > 1e-9 {
+		t.Error("Top of tube not hit correctly", rec.T0, rec.Normal)
+	}
+}
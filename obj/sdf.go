@@ -0,0 +1,142 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// DistanceFunc returns the signed distance from p to a surface: positive
+// outside, negative inside. It must never overestimate the distance to
+// the surface or sphere tracing will step through it
+type DistanceFunc func(p vec.Vec3) float64
+
+// SDF is an implicit surface defined by a signed distance function. It is
+// intersected by sphere tracing inside Box, which must enclose the
+// surface. Distance functions that are not exact bounds, such as
+// SDFTwist, need StepScale below 1 to shorten each step
+type SDF struct {
+	ID              string
+	Distance        DistanceFunc
+	Box             AABB
+	Col             color.RGBA
+	RefractiveIndex float64
+	MaxSteps        int
+	MaxDistance     float64 // limits tracing when Box is infinite
+	Epsilon         float64 // distance at which the surface counts as hit
+	StepScale       float64
+}
+
+// NewSDF is a constructor for SDF objects with default tracing settings
+func NewSDF(id string, distance DistanceFunc, bounds AABB, col color.RGBA, refractive float64) *SDF {
+	s := new(SDF)
+	s.ID = id
+	s.Distance = distance
+	s.Box = bounds
+	s.Col = col
+	s.RefractiveIndex = refractive
+	s.MaxSteps = 512
+	s.MaxDistance = 1000
+	s.Epsilon = 1e-6
+	s.StepScale = 1
+	return s
+}
+
+// GetID is the object specific method to return the ID of the SDF
+func (s *SDF) GetID() string {
+	return s.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (s *SDF) GetColor() color.RGBA {
+	return s.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (s *SDF) GetRefractiveIndex() float64 {
+	return s.RefractiveIndex
+}
+
+// Bounds returns the box the surface is traced in
+func (s *SDF) Bounds() AABB {
+	return s.Box
+}
+
+// Normal estimates the outward surface normal at p from the gradient of
+// the distance function, sampled at the corners of a tetrahedron
+func (s *SDF) Normal(p vec.Vec3) vec.Vec3 {
+	h := s.Epsilon
+	offsets := [4][3]float64{{1, -1, -1}, {-1, -1, 1}, {-1, 1, -1}, {1, 1, 1}}
+
+	var n [3]float64
+	for _, k := range offsets {
+		d := s.Distance(*vec.NewVec3(p.X+h*k[0], p.Y+h*k[1], p.Z+h*k[2]))
+		n[0] += k[0] * d
+		n[1] += k[1] * d
+		n[2] += k[2] * d
+	}
+	return *vec.NewVec3(n[0], n[1], n[2])
+}
+
+// Intersects sphere traces the ray through the distance field. A ray
+// starting inside the surface is traced to where it leaves. SDFs have no
+// surface parameterization of their own, so U and V are the longitude and
+// latitude of the hit seen from the center of Box, like Sphere. They are
+// left at 0 when Box is infinite
+func (s *SDF) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	dir := ray.Direction
+	dir.Normalize()
+	invDir := *vec.NewVec3(1/dir.X, 1/dir.Y, 1/dir.Z)
+
+	isHit, t, tMax := s.Box.IntersectsRay(ray.Origin, invDir, s.MaxDistance)
+	if !isHit {
+		return FalseObject()
+	}
+
+	// Trace the distance to the surface from whichever side we start on
+	side := 1.0
+	if s.Distance(vec.Add(ray.Origin, vec.Multiply(dir, t))) < 0 {
+		side = -1
+	}
+
+	for i := 0; i < s.MaxSteps && t <= tMax; i++ {
+		p := vec.Add(ray.Origin, vec.Multiply(dir, t))
+		d := side * s.Distance(p)
+		if d < s.Epsilon && t > 0 {
+			n := s.Normal(p)
+			if n.Magnitude == 0 {
+				n = vec.Invert(dir)
+			}
+			n = vec.Divide(n, n.Magnitude)
+
+			rec := newHitRecord(s, dir, p, n, t, t)
+
+			// The surface is only located to within Epsilon, so spawned
+			// rays must start further away than that
+			e := 2 * s.Epsilon
+			rec.Error = vec.Add(localError(p, 3), *vec.NewVec3(e, e, e))
+			if s.Box.IsFinite() {
+				s.sphericalUV(&rec)
+			}
+			return rec, true
+		}
+		t += math.Max(d*s.StepScale, s.Epsilon)
+	}
+	return FalseObject()
+}
+
+// sphericalUV projects the hit onto a sphere around the center of Box to
+// find its texture coordinates
+func (s *SDF) sphericalUV(rec *HitRecord) {
+	d := vec.Subtract(rec.Point, s.Box.Centroid())
+	if d.Magnitude == 0 {
+		return
+	}
+	rec.U = sphericalAngle(d.X, -d.Z) / (2 * math.Pi)
+	rec.V = 1 - math.Acos(math.Max(-1, math.Min(1, d.Y/d.Magnitude)))/math.Pi
+	rec.Tangent, rec.Bitangent = alignTangentFrame(rec.Normal, *vec.NewVec3(d.Z, 0, -d.X))
+}
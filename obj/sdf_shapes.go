@@ -0,0 +1,166 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// SDFSphere is the distance to a sphere
+func SDFSphere(center vec.Vec3, radius float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		return vec.Subtract(p, center).Magnitude - radius
+	}
+}
+
+// SDFBox is the distance to an axis-aligned box centered on center,
+// extending halfSize along each axis
+func SDFBox(center, halfSize vec.Vec3) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		qx := math.Abs(p.X-center.X) - halfSize.X
+		qy := math.Abs(p.Y-center.Y) - halfSize.Y
+		qz := math.Abs(p.Z-center.Z) - halfSize.Z
+		outside := vec.NewVec3(math.Max(qx, 0), math.Max(qy, 0), math.Max(qz, 0)).Magnitude
+		inside := math.Min(math.Max(qx, math.Max(qy, qz)), 0)
+		return outside + inside
+	}
+}
+
+// SDFTorus is the distance to a torus around center, lying in the xz plane
+func SDFTorus(center vec.Vec3, major, minor float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		x := p.X - center.X
+		y := p.Y - center.Y
+		z := p.Z - center.Z
+		ring := math.Sqrt(x*x+z*z) - major
+		return math.Sqrt(ring*ring+y*y) - minor
+	}
+}
+
+// SDFCylinder is the distance to a capped cylinder around center, with its
+// axis along y and extending halfHeight above and below center
+func SDFCylinder(center vec.Vec3, radius, halfHeight float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		x := p.X - center.X
+		z := p.Z - center.Z
+		dr := math.Sqrt(x*x+z*z) - radius
+		dy := math.Abs(p.Y-center.Y) - halfHeight
+		outside := math.Hypot(math.Max(dr, 0), math.Max(dy, 0))
+		return outside + math.Min(math.Max(dr, dy), 0)
+	}
+}
+
+// SDFCapsule is the distance to a line segment from a to b with rounded
+// ends of the given radius
+func SDFCapsule(a, b vec.Vec3, radius float64) DistanceFunc {
+	ab := vec.Subtract(b, a)
+	length2 := vec.Dot(ab, ab)
+	return func(p vec.Vec3) float64 {
+		ap := vec.Subtract(p, a)
+		h := 0.0
+		if length2 > 0 {
+			h = math.Max(0, math.Min(1, vec.Dot(ap, ab)/length2))
+		}
+		return vec.Subtract(ap, vec.Multiply(ab, h)).Magnitude - radius
+	}
+}
+
+// SDFPlane is the distance to the plane facing normal at the given offset
+// from the origin along it
+func SDFPlane(normal vec.Vec3, offset float64) DistanceFunc {
+	n := vec.Divide(normal, normal.Magnitude)
+	return func(p vec.Vec3) float64 {
+		return vec.Dot(p, n) - offset
+	}
+}
+
+// SDFUnion combines shapes into one, keeping everything inside any of them
+func SDFUnion(shapes ...DistanceFunc) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		d := math.Inf(1)
+		for _, f := range shapes {
+			d = math.Min(d, f(p))
+		}
+		return d
+	}
+}
+
+// SDFIntersection keeps only what is inside all of the shapes
+func SDFIntersection(shapes ...DistanceFunc) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		d := math.Inf(-1)
+		for _, f := range shapes {
+			d = math.Max(d, f(p))
+		}
+		return d
+	}
+}
+
+// SDFSubtract carves b out of a
+func SDFSubtract(a, b DistanceFunc) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		return math.Max(a(p), -b(p))
+	}
+}
+
+// SDFSmoothUnion joins two shapes with a blend of roughly size k where
+// they meet
+func SDFSmoothUnion(a, b DistanceFunc, k float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		da := a(p)
+		db := b(p)
+		if k <= 0 {
+			return math.Min(da, db)
+		}
+		h := math.Max(k-math.Abs(da-db), 0) / k
+		return math.Min(da, db) - h*h*k*0.25
+	}
+}
+
+// SDFTranslate moves a shape by offset
+func SDFTranslate(f DistanceFunc, offset vec.Vec3) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		return f(vec.Subtract(p, offset))
+	}
+}
+
+// SDFScale uniformly scales a shape about the origin
+func SDFScale(f DistanceFunc, scale float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		return f(vec.Divide(p, scale)) * scale
+	}
+}
+
+// SDFRound inflates a shape by radius, rounding its edges
+func SDFRound(f DistanceFunc, radius float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		return f(p) - radius
+	}
+}
+
+// SDFRepeat repeats a shape forever on a grid with the given period along
+// each axis. A period of 0 leaves that axis alone. The shape should fit
+// inside one cell centered on the origin
+func SDFRepeat(f DistanceFunc, period vec.Vec3) DistanceFunc {
+	repeat := func(x, c float64) float64 {
+		if c <= 0 {
+			return x
+		}
+		return x - c*math.Round(x/c)
+	}
+	return func(p vec.Vec3) float64 {
+		return f(*vec.NewVec3(repeat(p.X, period.X), repeat(p.Y, period.Y), repeat(p.Z, period.Z)))
+	}
+}
+
+// SDFTwist rotates a shape around the y axis by k radians per unit of
+// height. Twisting stretches the field, so the distance is no longer a
+// bound; trace it with a StepScale of about 1 / sqrt(1 + (k * r)^2) for a
+// shape of radius r
+func SDFTwist(f DistanceFunc, k float64) DistanceFunc {
+	return func(p vec.Vec3) float64 {
+		c := math.Cos(k * p.Y)
+		s := math.Sin(k * p.Y)
+		return f(*vec.NewVec3(c*p.X-s*p.Z, p.Y, s*p.X+c*p.Z))
+	}
+}
@@ -0,0 +1,97 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestSDFSphereMatchesSphere(t *testing.T) {
+	t.Parallel()
+
+	center := *vec.NewVec3(0.3, -0.2, -5)
+	bounds := NewAABB(*vec.NewVec3(-1, -1.5, -6.5), *vec.NewVec3(1.5, 1, -3.5))
+	sdf := NewSDF("sdf", SDFSphere(center, 1), bounds, color.RGBA{255, 0, 0, 1}, 1)
+	sphere := Sphere{"sphere", center, 1, color.RGBA{255, 0, 0, 1}, 1}
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0.05, 0, -1))
+	rec, is_hit := sdf.Intersects(ray)
+	expected, _ := sphere.Intersects(ray)
+
+	if !is_hit {
+		t.Fatal("SDF sphere was not hit")
+	}
+	if math.Abs(rec.T0-expected.T0) > 1e-5 {
+		t.Error("Hit distance not correct", rec.T0, expected.T0)
+	}
+	if vec.Subtract(rec.Normal, expected.Normal).Magnitude > 1e-4 || !rec.FrontFace {
+		t.Error("N vector not correct", rec.Normal, expected.Normal)
+	}
+
+	// A reflected ray leaving the surface must not hit it again
+	reflected := cam.NewRay(0, "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 1))
+	origin := OffsetRayOrigin(rec, reflected.Direction)
+	reflected.Origin = origin
+	if _, is_hit := sdf.Intersects(reflected); is_hit {
+		t.Error("Ray leaving the surface should not hit it again")
+	}
+
+	// From inside the ray is traced to where it leaves
+	inside := cam.NewRay(0, "camera", &center, vec.NewVec3(1, 0, 0))
+	rec, is_hit = sdf.Intersects(inside)
+	if !is_hit || math.Abs(rec.T0-1) > 1e-5 || rec.FrontFace {
+		t.Error("Ray from inside should hit the back of the surface")
+	}
+}
+
+func TestSDFCombinators(t *testing.T) {
+	t.Parallel()
+
+	a := SDFSphere(*vec.NewVec3(-1, 0, 0), 1)
+	b := SDFSphere(*vec.NewVec3(1, 0, 0), 1)
+	origin := *vec.NewVec3(0, 0, 0)
+
+	if SDFUnion(a, b)(origin) != 0 {
+		t.Error("Union not correct")
+	}
+	if SDFSmoothUnion(a, b, 0.5)(origin) >= 0 {
+		t.Error("Smooth union should blend the shapes together")
+	}
+	if SDFIntersection(a, b)(origin) != 0 || SDFSubtract(a, b)(origin) != 0 {
+		t.Error("Intersection or subtraction not correct")
+	}
+
+	box := SDFBox(origin, *vec.NewVec3(1, 2, 3))
+	if box(*vec.NewVec3(0, 0, 5)) != 2 || box(origin) != -1 {
+		t.Error("Box distance not correct")
+	}
+
+	repeated := SDFRepeat(SDFSphere(origin, 0.5), *vec.NewVec3(4, 0, 0))
+	if math.Abs(repeated(*vec.NewVec3(8, 0, 0))+0.5) > 1e-12 {
+		t.Error("Repetition not correct")
+	}
+
+	twisted := SDFTwist(SDFBox(origin, *vec.NewVec3(1, 1, 0.1)), math.Pi/2)
+	if twisted(*vec.NewVec3(0, 1, 0.9)) > 0 {
+		t.Error("Twist should rotate the box onto the point")
+	}
+}
+
+func TestSDFTwistIntersects(t *testing.T) {
+	t.Parallel()
+
+	origin := *vec.NewVec3(0, 0, 0)
+	twisted := SDFTwist(SDFBox(origin, *vec.NewVec3(1, 1, 0.2)), 0.5)
+	bounds := NewAABB(*vec.NewVec3(-2, -2, -2), *vec.NewVec3(2, 2, 2))
+	sdf := NewSDF("twist", twisted, bounds, color.RGBA{255, 0, 0, 1}, 1)
+	sdf.StepScale = 0.5
+
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 10), vec.NewVec3(0, 0, -1))
+	rec, is_hit := sdf.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-9.8) > 1e-5 {
+		t.Error("Twisted box was not hit correctly", rec.T0)
+	}
+}
@@ -0,0 +1,140 @@
+package obj
+
+import (
+	"math"
+	"sort"
+)
+
+// solveQuadratic returns the real roots of a*t^2 + b*t + c = 0 in
+// ascending order. It avoids the cancellation of the textbook formula
+func solveQuadratic(a, b, c float64) (bool, float64, float64) {
+	if a == 0 {
+		if b == 0 {
+			return false, 0, 0
+		}
+		t := -c / b
+		return true, t, t
+	}
+
+	discriminant := b*b - 4*a*c
+	if discriminant < 0 {
+		return false, 0, 0
+	}
+	root := math.Sqrt(discriminant)
+
+	var q float64
+	if b < 0 {
+		q = -0.5 * (b - root)
+	} else {
+		q = -0.5 * (b + root)
+	}
+
+	t0 := q / a
+	t1 := t0
+	if q != 0 {
+		t1 = c / q
+	}
+	if t0 > t1 {
+		t0, t1 = t1, t0
+	}
+	return true, t0, t1
+}
+
+// solveCubic returns the real roots of t^3 + a*t^2 + b*t + c = 0
+func solveCubic(a, b, c float64) []float64 {
+	// Depressed cubic s^3 + p*s + q = 0 with t = s - a/3
+	p := b - a*a/3
+	q := 2*a*a*a/27 - a*b/3 + c
+	shift := -a / 3
+
+	discriminant := q*q/4 + p*p*p/27
+	if discriminant > 0 {
+		root := math.Sqrt(discriminant)
+		return []float64{math.Cbrt(-q/2+root) + math.Cbrt(-q/2-root) + shift}
+	}
+
+	if p == 0 {
+		return []float64{shift}
+	}
+
+	// Three real roots, by the trigonometric method
+	r := 2 * math.Sqrt(-p/3)
+	phi := math.Acos(math.Max(-1, math.Min(1, 3*q/(p*r))))
+	roots := make([]float64, 3, 3)
+	for k := 0; k < 3; k++ {
+		roots[k] = r*math.Cos(phi/3-2*math.Pi*float64(k)/3) + shift
+	}
+	return roots
+}
+
+// solveQuartic returns the real roots of
+// c[4]*t^4 + c[3]*t^3 + c[2]*t^2 + c[1]*t + c[0] = 0 in ascending order
+// using Ferrari's method. Each root is polished with Newton iterations,
+// since the closed form loses precision
+func solveQuartic(c [5]float64) []float64 {
+	if c[4] == 0 {
+		roots := solveCubic(c[2]/c[3], c[1]/c[3], c[0]/c[3])
+		sort.Float64s(roots)
+		return roots
+	}
+
+	a := c[3] / c[4]
+	b := c[2] / c[4]
+	cc := c[1] / c[4]
+	d := c[0] / c[4]
+
+	// Depressed quartic y^4 + p*y^2 + q*y + r = 0 with t = y - a/4
+	p := b - 3*a*a/8
+	q := cc - a*b/2 + a*a*a/8
+	r := d - a*cc/4 + a*a*b/16 - 3*a*a*a*a/256
+	shift := -a / 4
+
+	var ys []float64
+	if math.Abs(q) < 1e-14 {
+		// Biquadratic
+		if ok, z0, z1 := solveQuadratic(1, p, r); ok {
+			for _, z := range []float64{z0, z1} {
+				if z >= 0 {
+					s := math.Sqrt(z)
+					ys = append(ys, s, -s)
+				}
+			}
+		}
+	} else {
+		// Take the largest root of the resolvent cubic
+		// m^3 + p*m^2 + (p^2/4 - r)*m - q^2/8 = 0
+		m := 0.0
+		for _, root := range solveCubic(p, p*p/4-r, -q*q/8) {
+			if root > m {
+				m = root
+			}
+		}
+		if m <= 0 {
+			return nil
+		}
+
+		s := math.Sqrt(2 * m)
+		if ok, y0, y1 := solveQuadratic(1, s, p/2+m-q/(2*s)); ok {
+			ys = append(ys, y0, y1)
+		}
+		if ok, y0, y1 := solveQuadratic(1, -s, p/2+m+q/(2*s)); ok {
+			ys = append(ys, y0, y1)
+		}
+	}
+
+	roots := make([]float64, 0, len(ys))
+	for _, y := range ys {
+		t := y + shift
+		for i := 0; i < 3; i++ {
+			f := (((c[4]*t+c[3])*t+c[2])*t+c[1])*t + c[0]
+			df := ((4*c[4]*t+3*c[3])*t+2*c[2])*t + c[1]
+			if df == 0 {
+				break
+			}
+			t -= f / df
+		}
+		roots = append(roots, t)
+	}
+	sort.Float64s(roots)
+	return roots
+}
@@ -0,0 +1,374 @@
+package obj
+
+import (
+	"math"
+
+	"github.com/agdt3/goray/vec"
+)
+
+// Crease marks the edge between two verticies of a PolygonMesh as sharp
+// for subdivision. A Sharpness of n keeps the edge sharp for n levels
+// before it starts to smooth out, fractional values blend between smooth
+// and sharp, and math.Inf(1) keeps it sharp forever. Boundary edges are
+// always treated as infinitely sharp
+type Crease struct {
+	V0        int
+	V1        int
+	Sharpness float64
+}
+
+// edgeKey identifies an undirected edge by its vertex indecies, smallest
+// first
+type edgeKey [2]int
+
+func newEdgeKey(a, b int) edgeKey {
+	if a > b {
+		a, b = b, a
+	}
+	return edgeKey{a, b}
+}
+
+// subdivEdge is the connectivity of one edge of a mesh being subdivided
+type subdivEdge struct {
+	faces     []int
+	opposite  []int // for triangles, the vertex opposite the edge in each face
+	sharpness float64
+	point     int // index of the new vertex on the edge
+}
+
+// isSharp reports whether the edge uses the crease rules at this level
+func (e *subdivEdge) isSharp() bool {
+	return e.sharpness > 0 || len(e.faces) != 2
+}
+
+// weight returns how far the edge is from smooth to sharp, from 0 to 1
+func (e *subdivEdge) weight() float64 {
+	if len(e.faces) != 2 {
+		return 1
+	}
+	return math.Max(0, math.Min(1, e.sharpness))
+}
+
+// subdivMesh is the connectivity of a mesh being subdivided
+type subdivMesh struct {
+	verticies []vec.Vec3
+	faces     [][]int
+	edges     map[edgeKey]*subdivEdge
+	order     []edgeKey   // edges in the order they were found
+	incident  [][]edgeKey // edges around each vertex
+}
+
+// newSubdivMesh builds the edge connectivity of a list of faces
+func newSubdivMesh(verticies []vec.Vec3, faces [][]int, creases []Crease) *subdivMesh {
+	m := &subdivMesh{
+		verticies: verticies,
+		faces:     faces,
+		edges:     make(map[edgeKey]*subdivEdge),
+		incident:  make([][]edgeKey, len(verticies))}
+
+	for f, face := range faces {
+		n := len(face)
+		for i := 0; i < n; i++ {
+			a, b := face[i], face[(i+1)%n]
+			key := newEdgeKey(a, b)
+			e, ok := m.edges[key]
+			if !ok {
+				e = new(subdivEdge)
+				m.edges[key] = e
+				m.order = append(m.order, key)
+				m.incident[a] = append(m.incident[a], key)
+				m.incident[b] = append(m.incident[b], key)
+			}
+			e.faces = append(e.faces, f)
+			if n == 3 {
+				e.opposite = append(e.opposite, face[(i+2)%n])
+			}
+		}
+	}
+
+	for _, c := range creases {
+		if e, ok := m.edges[newEdgeKey(c.V0, c.V1)]; ok {
+			e.sharpness = c.Sharpness
+		}
+	}
+	return m
+}
+
+// other returns the vertex at the other end of an edge from v
+func (k edgeKey) other(v int) int {
+	if k[0] == v {
+		return k[1]
+	}
+	return k[0]
+}
+
+// childCreases splits every sharp edge in two around its new edge point,
+// each half one level less sharp. Vertex points keep the index of the
+// vertex they replace
+func (m *subdivMesh) childCreases() []Crease {
+	creases := make([]Crease, 0)
+	for _, key := range m.order {
+		e := m.edges[key]
+		if e.sharpness-1 <= 0 {
+			continue
+		}
+		creases = append(creases,
+			Crease{key[0], e.point, e.sharpness - 1},
+			Crease{key[1], e.point, e.sharpness - 1})
+	}
+	return creases
+}
+
+// vertexRule applies the crease rules to a vertex. smooth is the position
+// from the smooth rule; crease(a, b) gives the position when the vertex
+// lies on a crease between neighbours a and b
+func (m *subdivMesh) vertexRule(v int, smooth vec.Vec3, crease func(a, b vec.Vec3) vec.Vec3) vec.Vec3 {
+	sharp := make([]int, 0, 2)
+	weight := 0.0
+	for _, key := range m.incident[v] {
+		if e := m.edges[key]; e.isSharp() {
+			sharp = append(sharp, key.other(v))
+			weight += e.weight()
+		}
+	}
+	if len(sharp) < 2 {
+		// Smooth and dart verticies
+		return smooth
+	}
+	weight /= float64(len(sharp))
+
+	// Corners stay where they are
+	rule := m.verticies[v]
+	if len(sharp) == 2 {
+		rule = crease(m.verticies[sharp[0]], m.verticies[sharp[1]])
+	}
+	return lerpVec3(smooth, rule, weight)
+}
+
+// lerpVec3 blends from a to b as w goes from 0 to 1
+func lerpVec3(a, b vec.Vec3, w float64) vec.Vec3 {
+	if w >= 1 {
+		return b
+	}
+	if w <= 0 {
+		return a
+	}
+	return vec.Add(vec.Multiply(a, 1-w), vec.Multiply(b, w))
+}
+
+// faceList returns the verticies of every face. Faces from .mesh files
+// are already split into triangles and are returned as such
+func (p *PolygonMesh) faceList() [][]int {
+	corners := p.triangleCorners()
+	totalVerticies := 0
+	for _, n := range p.NumVerticies {
+		totalVerticies += n
+	}
+
+	faces := make([][]int, 0, len(p.NumVerticies))
+	if len(p.VertexIndecies) != totalVerticies {
+		for i := 0; i+2 < len(corners); i += 3 {
+			faces = append(faces, []int{
+				p.VertexIndecies[corners[i]],
+				p.VertexIndecies[corners[i+1]],
+				p.VertexIndecies[corners[i+2]]})
+		}
+		return faces
+	}
+
+	start := 0
+	for _, n := range p.NumVerticies {
+		face := make([]int, n, n)
+		copy(face, p.VertexIndecies[start:start+n])
+		faces = append(faces, face)
+		start += n
+	}
+	return faces
+}
+
+// verticiesVec3 returns the vertex buffer as vectors
+func (p *PolygonMesh) verticiesVec3() []vec.Vec3 {
+	verticies := make([]vec.Vec3, len(p.Verticies)/3)
+	for i := range verticies {
+		verticies[i] = *vec.NewVec3(p.Verticies[i*3], p.Verticies[i*3+1], p.Verticies[i*3+2])
+	}
+	return verticies
+}
+
+// newSubdividedMesh packs subdivided verticies and faces into a
+// PolygonMesh
+func newSubdividedMesh(verticies []vec.Vec3, faces [][]int, creases []Crease) *PolygonMesh {
+	poly := MakePolygonMesh()
+	poly.NumFaces[0] = len(faces)
+	poly.Verticies = make([]float64, 0, len(verticies)*3)
+	for _, v := range verticies {
+		poly.Verticies = append(poly.Verticies, v.X, v.Y, v.Z)
+	}
+	for _, f := range faces {
+		poly.NumVerticies = append(poly.NumVerticies, len(f))
+		poly.VertexIndecies = append(poly.VertexIndecies, f...)
+	}
+	poly.Creases = creases
+	return poly
+}
+
+// Subdivide refines the mesh levels times, using Loop subdivision when
+// every face is a triangle and Catmull-Clark otherwise. Vertex normals and
+// texture coordinates are not carried over, so triangle meshes built from
+// the result compute their own normals
+func (p *PolygonMesh) Subdivide(levels int) *PolygonMesh {
+	for _, n := range p.NumVerticies {
+		if n != 3 {
+			return p.SubdivideCatmullClark(levels)
+		}
+	}
+	return p.SubdivideLoop(levels)
+}
+
+// SubdivideLoop refines a triangle mesh levels times with Loop's scheme.
+// Each level splits every triangle into four
+func (p *PolygonMesh) SubdivideLoop(levels int) *PolygonMesh {
+	verticies := p.verticiesVec3()
+	faces := p.faceList()
+	creases := p.Creases
+	for level := 0; level < levels; level++ {
+		verticies, faces, creases = loopLevel(verticies, faces, creases)
+	}
+	return newSubdividedMesh(verticies, faces, creases)
+}
+
+// loopLevel applies one level of Loop subdivision
+func loopLevel(verticies []vec.Vec3, faces [][]int, creases []Crease) ([]vec.Vec3, [][]int, []Crease) {
+	m := newSubdivMesh(verticies, faces, creases)
+	next := make([]vec.Vec3, 0, len(verticies)+len(m.order))
+
+	for v, p := range verticies {
+		n := len(m.incident[v])
+		smooth := p
+		if n > 0 {
+			sum := *vec.NewVec3(0, 0, 0)
+			for _, key := range m.incident[v] {
+				sum = vec.Add(sum, verticies[key.other(v)])
+			}
+			c := 3.0/8 + math.Cos(2*math.Pi/float64(n))/4
+			beta := (5.0/8 - c*c) / float64(n)
+			smooth = vec.Add(vec.Multiply(p, 1-float64(n)*beta), vec.Multiply(sum, beta))
+		}
+		next = append(next, m.vertexRule(v, smooth, func(a, b vec.Vec3) vec.Vec3 {
+			return vec.Add(vec.Multiply(p, 0.75), vec.Multiply(vec.Add(a, b), 0.125))
+		}))
+	}
+
+	for _, key := range m.order {
+		e := m.edges[key]
+		a, b := verticies[key[0]], verticies[key[1]]
+		sharp := vec.Multiply(vec.Add(a, b), 0.5)
+		smooth := sharp
+		if len(e.opposite) == 2 {
+			smooth = vec.Add(
+				vec.Multiply(vec.Add(a, b), 0.375),
+				vec.Multiply(vec.Add(verticies[e.opposite[0]], verticies[e.opposite[1]]), 0.125))
+		}
+		e.point = len(next)
+		next = append(next, lerpVec3(smooth, sharp, e.weight()))
+	}
+
+	nextFaces := make([][]int, 0, len(faces)*4)
+	for _, f := range faces {
+		e01 := m.edges[newEdgeKey(f[0], f[1])].point
+		e12 := m.edges[newEdgeKey(f[1], f[2])].point
+		e20 := m.edges[newEdgeKey(f[2], f[0])].point
+		nextFaces = append(nextFaces,
+			[]int{f[0], e01, e20},
+			[]int{f[1], e12, e01},
+			[]int{f[2], e20, e12},
+			[]int{e01, e12, e20})
+	}
+
+	return next, nextFaces, m.childCreases()
+}
+
+// SubdivideCatmullClark refines a polygon mesh levels times with the
+// Catmull-Clark scheme. Each level splits every n-sided face into n quads
+func (p *PolygonMesh) SubdivideCatmullClark(levels int) *PolygonMesh {
+	verticies := p.verticiesVec3()
+	faces := p.faceList()
+	creases := p.Creases
+	for level := 0; level < levels; level++ {
+		verticies, faces, creases = catmullClarkLevel(verticies, faces, creases)
+	}
+	return newSubdividedMesh(verticies, faces, creases)
+}
+
+// catmullClarkLevel applies one level of Catmull-Clark subdivision
+func catmullClarkLevel(verticies []vec.Vec3, faces [][]int, creases []Crease) ([]vec.Vec3, [][]int, []Crease) {
+	m := newSubdivMesh(verticies, faces, creases)
+
+	facePoints := make([]vec.Vec3, len(faces))
+	for f, face := range faces {
+		sum := *vec.NewVec3(0, 0, 0)
+		for _, v := range face {
+			sum = vec.Add(sum, verticies[v])
+		}
+		facePoints[f] = vec.Divide(sum, float64(len(face)))
+	}
+
+	// New verticies are laid out as vertex points, then edge points,
+	// then face points
+	next := make([]vec.Vec3, 0, len(verticies)+len(m.order)+len(faces))
+
+	for v, p := range verticies {
+		n := float64(len(m.incident[v]))
+		smooth := p
+		if n > 0 {
+			q := *vec.NewVec3(0, 0, 0)
+			adjacent := 0
+			seen := make(map[int]bool)
+			r := *vec.NewVec3(0, 0, 0)
+			for _, key := range m.incident[v] {
+				r = vec.Add(r, vec.Multiply(vec.Add(p, verticies[key.other(v)]), 0.5))
+				for _, f := range m.edges[key].faces {
+					if !seen[f] {
+						seen[f] = true
+						q = vec.Add(q, facePoints[f])
+						adjacent++
+					}
+				}
+			}
+			q = vec.Divide(q, float64(adjacent))
+			r = vec.Divide(r, n)
+			smooth = vec.Divide(vec.Add(vec.Add(q, vec.Multiply(r, 2)), vec.Multiply(p, n-3)), n)
+		}
+		next = append(next, m.vertexRule(v, smooth, func(a, b vec.Vec3) vec.Vec3 {
+			return vec.Divide(vec.Add(vec.Multiply(p, 6), vec.Add(a, b)), 8)
+		}))
+	}
+
+	for _, key := range m.order {
+		e := m.edges[key]
+		a, b := verticies[key[0]], verticies[key[1]]
+		sharp := vec.Multiply(vec.Add(a, b), 0.5)
+		smooth := sharp
+		if len(e.faces) == 2 {
+			f := vec.Add(facePoints[e.faces[0]], facePoints[e.faces[1]])
+			smooth = vec.Multiply(vec.Add(vec.Add(a, b), f), 0.25)
+		}
+		e.point = len(next)
+		next = append(next, lerpVec3(smooth, sharp, e.weight()))
+	}
+
+	faceStart := len(next)
+	next = append(next, facePoints...)
+
+	nextFaces := make([][]int, 0, len(faces)*4)
+	for f, face := range faces {
+		n := len(face)
+		for i := 0; i < n; i++ {
+			prev := m.edges[newEdgeKey(face[(i+n-1)%n], face[i])].point
+			after := m.edges[newEdgeKey(face[i], face[(i+1)%n])].point
+			nextFaces = append(nextFaces, []int{face[i], after, faceStart + f, prev})
+		}
+	}
+
+	return next, nextFaces, m.childCreases()
+}
@@ -0,0 +1,130 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func makeCubeMesh() *PolygonMesh {
+	poly := MakePolygonMesh()
+	poly.Verticies = []float64{
+		-1, -1, -1,
+		1, -1, -1,
+		1, 1, -1,
+		-1, 1, -1,
+		-1, -1, 1,
+		1, -1, 1,
+		1, 1, 1,
+		-1, 1, 1}
+	poly.VertexIndecies = []int{
+		0, 3, 2, 1,
+		4, 5, 6, 7,
+		0, 1, 5, 4,
+		2, 3, 7, 6,
+		1, 2, 6, 5,
+		0, 4, 7, 3}
+	poly.NumVerticies = []int{4, 4, 4, 4, 4, 4}
+	poly.NumFaces[0] = 6
+	return poly
+}
+
+func TestSubdivideCatmullClark(t *testing.T) {
+	t.Parallel()
+
+	cube := makeCubeMesh()
+	refined := cube.Subdivide(1)
+
+	// 8 vertex points, 12 edge points and 6 face points
+	if len(refined.Verticies) != 26*3 || len(refined.NumVerticies) != 24 {
+		t.Fatal("Wrong number of verticies or faces", len(refined.Verticies)/3, len(refined.NumVerticies))
+	}
+
+	// Each corner is pulled in to 5/9 along the diagonal
+	corner := *vec.NewVec3(refined.Verticies[18], refined.Verticies[19], refined.Verticies[20])
+	if math.Abs(corner.X-5.0/9) > 1e-12 || math.Abs(corner.Y-5.0/9) > 1e-12 || math.Abs(corner.Z-5.0/9) > 1e-12 {
+		t.Error("Corner vertex point not correct", corner)
+	}
+
+	// Sharp edges everywhere leave the cube as it was
+	for _, f := range cube.faceList() {
+		for i := range f {
+			cube.Creases = append(cube.Creases, Crease{f[i], f[(i+1)%4], math.Inf(1)})
+		}
+	}
+	sharp := cube.Subdivide(2)
+	for i, v := range sharp.Verticies {
+		if math.Abs(v) > 1 {
+			t.Fatal("Creased cube should not grow", i, v)
+		}
+	}
+	if sharp.Verticies[18] != 1 || sharp.Verticies[19] != 1 || sharp.Verticies[20] != 1 {
+		t.Error("Corners of a fully creased cube should not move")
+	}
+}
+
+func TestSubdivideCreaseSharpness(t *testing.T) {
+	t.Parallel()
+
+	cube := makeCubeMesh()
+	cube.Creases = []Crease{{6, 7, 2}}
+	refined := cube.Subdivide(1)
+
+	// The crease splits in two, one level less sharp
+	if len(refined.Creases) != 2 || refined.Creases[0].Sharpness != 1 {
+		t.Fatal("Crease was not carried to the refined mesh", refined.Creases)
+	}
+
+	// Its edge point stays on the original edge
+	p := refined.Creases[0].V1
+	if refined.Verticies[p*3] != 0 || refined.Verticies[p*3+1] != 1 || refined.Verticies[p*3+2] != 1 {
+		t.Error("Creased edge point should be the midpoint")
+	}
+
+	if len(refined.Subdivide(1).Creases) != 0 {
+		t.Error("Crease should be smooth after two levels")
+	}
+}
+
+func TestSubdivideLoop(t *testing.T) {
+	t.Parallel()
+
+	// Octahedron
+	poly := MakePolygonMesh()
+	poly.Verticies = []float64{
+		1, 0, 0,
+		-1, 0, 0,
+		0, 1, 0,
+		0, -1, 0,
+		0, 0, 1,
+		0, 0, -1}
+	poly.VertexIndecies = []int{
+		0, 2, 4, 2, 1, 4, 1, 3, 4, 3, 0, 4,
+		2, 0, 5, 1, 2, 5, 3, 1, 5, 0, 3, 5}
+	poly.NumVerticies = []int{3, 3, 3, 3, 3, 3, 3, 3}
+	poly.NumFaces[0] = 8
+
+	refined := poly.Subdivide(2)
+	if len(refined.NumVerticies) != 8*16 || len(refined.Verticies) != (6+12+48)*3 {
+		t.Fatal("Wrong number of verticies or faces", len(refined.Verticies)/3, len(refined.NumVerticies))
+	}
+
+	// By symmetry every vertex of the octahedron moves towards the
+	// center by the same amount
+	r := math.Abs(refined.Verticies[0])
+	if r >= 1 || r <= 0.3 || math.Abs(refined.Verticies[4*3+2]-r) > 1e-12 {
+		t.Error("Vertex points not correct", r)
+	}
+
+	// The refined mesh feeds into triangle meshes as usual
+	mesh := NewTriangleMesh("smooth", refined, color.RGBA{255, 0, 0, 1}, 1, false)
+	mesh.SmoothNormals()
+	ray := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 5), vec.NewVec3(0, 0, -1))
+	rec, is_hit := mesh.Intersects(ray)
+	if !is_hit || math.Abs(rec.T0-(5-r)) > 1e-9 || math.Abs(rec.Normal.Z-1) > 1e-9 {
+		t.Error("Refined mesh not hit correctly", rec.T0, rec.Normal)
+	}
+}
@@ -0,0 +1,126 @@
+package obj
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// Torus is a ring around Center in the plane perpendicular to Axis.
+// MajorRadius is the distance from the center to the middle of the tube
+// and MinorRadius is the radius of the tube
+type Torus struct {
+	ID              string
+	Center          vec.Vec3
+	Axis            vec.Vec3
+	MajorRadius     float64
+	MinorRadius     float64
+	Col             color.RGBA
+	RefractiveIndex float64
+	frame           frame
+}
+
+// NewTorus is a constructor for Tori
+func NewTorus(id string, center, axis vec.Vec3, major, minor float64, col color.RGBA, refractive float64) *Torus {
+	t := new(Torus)
+	t.ID = id
+	t.Center = center
+	t.Axis = vec.Divide(axis, axis.Magnitude)
+	t.MajorRadius = major
+	t.MinorRadius = minor
+	t.Col = col
+	t.RefractiveIndex = refractive
+	t.frame = newFrame(center, axis)
+	return t
+}
+
+// GetID is the object specific method to return the ID of the torus
+func (t *Torus) GetID() string {
+	return t.ID
+}
+
+// GetColor is the object specific method to return the color
+// as color.RGBA
+func (t *Torus) GetColor() color.RGBA {
+	return t.Col
+}
+
+// GetRefractiveIndex is the object specific method to return the
+// refractive index
+func (t *Torus) GetRefractiveIndex() float64 {
+	return t.RefractiveIndex
+}
+
+// Bounds returns the axis-aligned bounding box of the torus
+func (t *Torus) Bounds() AABB {
+	w := t.MajorRadius + t.MinorRadius
+	r := t.MinorRadius
+	return t.frame.bounds(AABB{*vec.NewVec3(-w, -w, -r), *vec.NewVec3(w, w, r)})
+}
+
+// Intersects checks for intersections between a ray and the torus.
+// U runs around the axis and V around the tube, starting on the outside
+func (t *Torus) Intersects(ray *cam.Ray) (HitRecord, bool) {
+	o, d := t.frame.localRay(ray)
+	major := t.MajorRadius
+	minor := t.MinorRadius
+
+	// The quartic is badly conditioned far from the torus, so start the
+	// ray on its bounding sphere
+	outer := major + minor
+	ok, s0, s1 := solveQuadratic(1, 2*vec.Dot(o, d), vec.Dot(o, o)-outer*outer)
+	if !ok || s1 <= 0 {
+		return FalseObject()
+	}
+	shift := math.Max(0, s0)
+	o = vec.Add(o, vec.Multiply(d, shift))
+
+	// (x^2 + y^2 + z^2 + R^2 - r^2)^2 = 4R^2 (x^2 + y^2), with |d| = 1
+	e := vec.Dot(o, o) - major*major - minor*minor
+	f := vec.Dot(o, d)
+	coeffs := [5]float64{
+		e*e - 4*major*major*(minor*minor-o.Z*o.Z),
+		4*f*e + 8*major*major*o.Z*d.Z,
+		2*e + 4*f*f + 4*major*major*d.Z*d.Z,
+		4 * f,
+		1,
+	}
+
+	var roots []float64
+	for _, root := range solveQuartic(coeffs) {
+		if root+shift > 0 {
+			roots = append(roots, root)
+		}
+	}
+	if len(roots) == 0 {
+		return FalseObject()
+	}
+
+	// Reproject onto the tube around the nearest point of the ring
+	p := vec.Add(o, vec.Multiply(d, roots[0]))
+	ring := math.Sqrt(p.X*p.X + p.Y*p.Y)
+	var c vec.Vec3
+	if ring > 0 {
+		c = *vec.NewVec3(p.X*major/ring, p.Y*major/ring, 0)
+	} else {
+		c = *vec.NewVec3(major, 0, 0)
+	}
+	n := vec.Subtract(p, c)
+	if n.Magnitude == 0 {
+		return FalseObject()
+	}
+	n = vec.Divide(n, n.Magnitude)
+	p = vec.Add(c, vec.Multiply(n, minor))
+
+	u := sphericalAngle(p.X, p.Y) / (2 * math.Pi)
+	v := sphericalAngle(math.Sqrt(p.X*p.X+p.Y*p.Y)-major, p.Z) / (2 * math.Pi)
+	dpdu := *vec.NewVec3(-p.Y, p.X, 0)
+
+	dir := ray.Direction
+	dir.Normalize()
+	t0 := roots[0] + shift
+	t1 := roots[len(roots)-1] + shift
+	return t.frame.hitRecord(t, dir, p, n, dpdu, localError(p, 7), t0, t1, u, v), true
+}
@@ -0,0 +1,208 @@
+package obj
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+// VoxelGrid is a dense grid of densities, stored with x varying fastest,
+// then y, then z. Densities are sampled at the centers of the voxels
+type VoxelGrid struct {
+	Nx, Ny, Nz int
+	Densities  []float64
+	MaxDensity float64
+}
+
+// NewVoxelGrid is a constructor for VoxelGrid. It returns an error if
+// the number of densities does not match the size of the grid
+func NewVoxelGrid(nx, ny, nz int, densities []float64) (*VoxelGrid, error) {
+	if nx < 1 || ny < 1 || nz < 1 || len(densities) != nx*ny*nz {
+		return nil, errors.New("voxel grid size does not match its densities")
+	}
+
+	g := new(VoxelGrid)
+	g.Nx, g.Ny, g.Nz = nx, ny, nz
+	g.Densities = densities
+	for _, d := range densities {
+		g.MaxDensity = math.Max(g.MaxDensity, d)
+	}
+	return g, nil
+}
+
+// At returns the density of voxel (i, j, k), clamped to the edges of the
+// grid
+func (g *VoxelGrid) At(i, j, k int) float64 {
+	clamp := func(v, n int) int {
+		return int(math.Max(0, math.Min(float64(n-1), float64(v))))
+	}
+	i, j, k = clamp(i, g.Nx), clamp(j, g.Ny), clamp(k, g.Nz)
+	return g.Densities[(k*g.Ny+j)*g.Nx+i]
+}
+
+// Density returns the trilinearly interpolated density at p, given in
+// coordinates running from 0 to 1 across the grid. It is 0 outside
+func (g *VoxelGrid) Density(p vec.Vec3) float64 {
+	if p.X < 0 || p.Y < 0 || p.Z < 0 || p.X > 1 || p.Y > 1 || p.Z > 1 {
+		return 0
+	}
+	x := p.X*float64(g.Nx) - 0.5
+	y := p.Y*float64(g.Ny) - 0.5
+	z := p.Z*float64(g.Nz) - 0.5
+	x0, y0, z0 := math.Floor(x), math.Floor(y), math.Floor(z)
+	fx, fy, fz := x-x0, y-y0, z-z0
+	i, j, k := int(x0), int(y0), int(z0)
+
+	lerp := func(a, b, t float64) float64 {
+		return a + (b-a)*t
+	}
+	c00 := lerp(g.At(i, j, k), g.At(i+1, j, k), fx)
+	c10 := lerp(g.At(i, j+1, k), g.At(i+1, j+1, k), fx)
+	c01 := lerp(g.At(i, j, k+1), g.At(i+1, j, k+1), fx)
+	c11 := lerp(g.At(i, j+1, k+1), g.At(i+1, j+1, k+1), fx)
+	return lerp(lerp(c00, c10, fy), lerp(c01, c11, fy), fz)
+}
+
+// VoxelVolume is a heterogeneous medium whose density comes from a voxel
+// grid stretched over Box. Extinction is the fraction of light lost per
+// unit distance at density 1, Albedo the fraction of that which is
+// scattered rather than absorbed in red, green and blue, and G the
+// Henyey-Greenstein asymmetry. Because the density varies, light through
+// the volume is estimated by Monte Carlo tracking against the largest
+// extinction in the grid, averaging Samples estimates
+type VoxelVolume struct {
+	ID         string
+	Box        AABB
+	Grid       *VoxelGrid
+	Extinction float64
+	Albedo     [3]float64
+	G          float64
+	Samples    int
+}
+
+// NewVoxelVolume is a constructor for VoxelVolume with default sampling
+func NewVoxelVolume(id string, box AABB, grid *VoxelGrid, extinction float64, albedo [3]float64, g float64) *VoxelVolume {
+	v := new(VoxelVolume)
+	v.ID = id
+	v.Box = box
+	v.Grid = grid
+	v.Extinction = extinction
+	v.Albedo = albedo
+	v.G = g
+	v.Samples = 16
+	return v
+}
+
+// Bounds returns the box the grid fills
+func (v *VoxelVolume) Bounds() AABB {
+	return v.Box
+}
+
+// Density returns the density of the volume at the world space point p
+func (v *VoxelVolume) Density(p vec.Vec3) float64 {
+	size := vec.Subtract(v.Box.Max, v.Box.Min)
+	q := vec.Subtract(p, v.Box.Min)
+	return v.Grid.Density(*vec.NewVec3(q.X/size.X, q.Y/size.Y, q.Z/size.Z))
+}
+
+// majorant returns the largest extinction anywhere in the volume
+func (v *VoxelVolume) majorant() float64 {
+	return v.Extinction * v.Grid.MaxDensity
+}
+
+// segment returns the normalized ray direction and the stretch of the ray
+// inside the box between 0 and maxDist
+func (v *VoxelVolume) segment(ray *cam.Ray, maxDist float64) (bool, vec.Vec3, float64, float64) {
+	dir := ray.Direction
+	dir.Normalize()
+	invDir := *vec.NewVec3(1/dir.X, 1/dir.Y, 1/dir.Z)
+	isHit, t0, t1 := v.Box.IntersectsRay(ray.Origin, invDir, maxDist)
+	return isHit && t1 > t0, dir, t0, t1
+}
+
+// RatioTracking returns an unbiased estimate of the fraction of light
+// that gets through the volume from t0 to t1 along the ray. It steps
+// through collisions sampled against the majorant and at each one keeps
+// the fraction of the majorant that is not real extinction
+func (v *VoxelVolume) RatioTracking(org, dir vec.Vec3, t0, t1 float64, rng *rand.Rand) float64 {
+	majorant := v.majorant()
+	if majorant <= 0 {
+		return 1
+	}
+	transmittance := 1.0
+	for t := t0; ; {
+		t -= math.Log(1-rng.Float64()) / majorant
+		if t >= t1 {
+			return transmittance
+		}
+		p := vec.Add(org, vec.Multiply(dir, t))
+		transmittance *= 1 - v.Extinction*v.Density(p)/majorant
+	}
+}
+
+// DeltaTracking samples the distance to the first real collision in the
+// volume from t0 along the ray. Collisions are sampled against the
+// majorant and accepted with the probability that they are real, which
+// gives distances distributed by the true transmittance. It returns false
+// if the ray leaves at t1 first
+func (v *VoxelVolume) DeltaTracking(org, dir vec.Vec3, t0, t1 float64, rng *rand.Rand) (bool, float64) {
+	majorant := v.majorant()
+	if majorant <= 0 {
+		return false, t1
+	}
+	for t := t0; ; {
+		t -= math.Log(1-rng.Float64()) / majorant
+		if t >= t1 {
+			return false, t1
+		}
+		p := vec.Add(org, vec.Multiply(dir, t))
+		if rng.Float64() < v.Extinction*v.Density(p)/majorant {
+			return true, t
+		}
+	}
+}
+
+// Transmittance averages Samples ratio tracking estimates of the light
+// that gets through the volume along the ray up to maxDist
+func (v *VoxelVolume) Transmittance(ray *cam.Ray, maxDist float64, rng *rand.Rand) float64 {
+	isHit, dir, t0, t1 := v.segment(ray, maxDist)
+	if !isHit || v.Samples < 1 {
+		return 1
+	}
+	sum := 0.0
+	for i := 0; i < v.Samples; i++ {
+		sum += v.RatioTracking(ray.Origin, dir, t0, t1, rng)
+	}
+	return sum / float64(v.Samples)
+}
+
+// InScatter estimates the light scattered toward the ray origin by the
+// volume up to maxDist. Each of Samples delta tracking walks finds a
+// collision and, weighted by the albedo and phase function, gathers the
+// light arriving there from incident
+func (v *VoxelVolume) InScatter(ray *cam.Ray, maxDist float64, incident IncidentFunc, rng *rand.Rand) [3]float64 {
+	var sum [3]float64
+	isHit, dir, t0, t1 := v.segment(ray, maxDist)
+	if !isHit || v.Samples < 1 {
+		return sum
+	}
+	for i := 0; i < v.Samples; i++ {
+		collided, t := v.DeltaTracking(ray.Origin, dir, t0, t1, rng)
+		if !collided {
+			continue
+		}
+		p := vec.Add(ray.Origin, vec.Multiply(dir, t))
+		toLight, radiance := incident(p, ray)
+		phase := HenyeyGreenstein(vec.Dot(dir, toLight), v.G)
+		for c := range sum {
+			sum[c] += v.Albedo[c] * phase * radiance[c]
+		}
+	}
+	for c := range sum {
+		sum[c] /= float64(v.Samples)
+	}
+	return sum
+}
@@ -0,0 +1,77 @@
+package obj
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/agdt3/goray/cam"
+	"github.com/agdt3/goray/vec"
+)
+
+func TestVoxelGridDensity(t *testing.T) {
+	t.Parallel()
+
+	grid, err := NewVoxelGrid(2, 1, 1, []float64{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[float64]float64{0.1: 0, 0.25: 0, 0.5: 0.5, 0.75: 1, 0.9: 1}
+	for x, d := range expected {
+		if got := grid.Density(*vec.NewVec3(x, 0.5, 0.5)); math.Abs(got-d) > 1e-12 {
+			t.Error("Density not interpolated correctly at", x, got)
+		}
+	}
+	if grid.Density(*vec.NewVec3(1.1, 0.5, 0.5)) != 0 {
+		t.Error("Density outside the grid should be 0")
+	}
+
+	if _, err := NewVoxelGrid(2, 2, 2, []float64{1}); err == nil {
+		t.Error("Grid with missing densities should fail")
+	}
+}
+
+func TestVoxelVolumeTracking(t *testing.T) {
+	t.Parallel()
+
+	// Along x the density averages 0.75, so the optical depth is 1.5
+	grid, _ := NewVoxelGrid(2, 1, 1, []float64{0.5, 1})
+	box := NewAABB(*vec.NewVec3(0, 0, -1), *vec.NewVec3(1, 1, 0))
+	volume := NewVoxelVolume("smoke", box, grid, 2, [3]float64{0.5, 0.5, 0.5}, 0)
+	volume.Samples = 20000
+	expected := math.Exp(-1.5)
+
+	rng := rand.New(rand.NewSource(1))
+	ray := cam.NewRay(0, "camera", vec.NewVec3(-1, 0.5, -0.5), vec.NewVec3(1, 0, 0))
+	if tr := volume.Transmittance(ray, math.Inf(1), rng); math.Abs(tr-expected) > 0.01 {
+		t.Error("Ratio tracking transmittance not correct", tr, expected)
+	}
+
+	escaped := 0
+	for i := 0; i < volume.Samples; i++ {
+		if collided, _ := volume.DeltaTracking(*vec.NewVec3(0, 0.5, -0.5), *vec.NewVec3(1, 0, 0), 0, 1, rng); !collided {
+			escaped++
+		}
+	}
+	if fraction := float64(escaped) / float64(volume.Samples); math.Abs(fraction-expected) > 0.01 {
+		t.Error("Delta tracking escape rate not correct", fraction, expected)
+	}
+
+	// Under constant light the scattered light is the albedo times the
+	// light that collides, spread evenly over the sphere
+	incident := func(p vec.Vec3, parent *cam.Ray) (vec.Vec3, [3]float64) {
+		if parent != ray {
+			t.Error("Light should be gathered for the traced ray")
+		}
+		return *vec.NewVec3(0, 1, 0), [3]float64{1, 1, 1}
+	}
+	scattered := volume.InScatter(ray, math.Inf(1), incident, rng)
+	if want := 0.5 * (1 - expected) / (4 * math.Pi); math.Abs(scattered[0]-want) > 0.05*want {
+		t.Error("In-scattered light not correct", scattered[0], want)
+	}
+
+	// Rays stopped before the volume are not affected
+	if tr := volume.Transmittance(ray, 0.5, rng); tr != 1 {
+		t.Error("Ray stopped before the volume should be unaffected", tr)
+	}
+}
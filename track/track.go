@@ -3,6 +3,7 @@ package track
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/agdt3/goray/cam"
@@ -14,17 +15,18 @@ type RayTreeNode struct {
 	Y         int
 	PixelX    float64
 	PixelY    float64
-	RayId     string
+	RayId     cam.RayID
 	ObjEmitId string
 	ObjHitId  string
 	RayType   string
 	Parent    *RayTreeNode
-	Children  []RayTreeNode
+	Children  []*RayTreeNode
 }
 
 type RayTree struct {
 	NodeCount int
-	Children  []RayTreeNode
+	Children  []*RayTreeNode
+	nodes     map[cam.RayID]*RayTreeNode
 }
 
 // Comparator type
@@ -33,6 +35,7 @@ type RayTree struct {
 func NewTree() *RayTree {
 	tree := new(RayTree)
 	tree.NodeCount = 0
+	tree.nodes = make(map[cam.RayID]*RayTreeNode)
 	return tree
 }
 
@@ -44,8 +47,8 @@ func (t *RayTree) String() string {
 }
 
 func (t *RayTree) AddRoot(x, y int, px, py float64, ray *cam.Ray) {
-	children := make([]RayTreeNode, 0)
-	node := RayTreeNode{
+	children := make([]*RayTreeNode, 0)
+	node := &RayTreeNode{
 		x,
 		y,
 		px,
@@ -59,6 +62,7 @@ func (t *RayTree) AddRoot(x, y int, px, py float64, ray *cam.Ray) {
 	}
 
 	t.Children = append(t.Children, node)
+	t.nodes[ray.ID] = node
 	t.NodeCount += 1
 }
 
@@ -91,49 +95,37 @@ func (t *RayTree) AddNode(ray, parentray *cam.Ray, parentobj, hitobj obj.Object)
 	}
 
 	node.Parent = parent
-	parent.Children = append(parent.Children, *node)
+	parent.Children = append(parent.Children, node)
+	t.nodes[ray.ID] = node
 	t.NodeCount += 1
 }
 
-func (t *RayTree) FindNodeByRayId(id string) *RayTreeNode {
-	/*
-		if ChildNodeId: AAA|BBB|NNN
-		then ParentNodeId: AAA|BBB
-	*/
-	return t.Find(t.Children, id)
+func (t *RayTree) FindNodeByRayId(id cam.RayID) *RayTreeNode {
+	return t.nodes[id]
 }
 
-func (t *RayTree) Find(children []RayTreeNode, id string) *RayTreeNode {
-	// TODO: Replace with search term and comparator function, maybe
-	if len(children) > 0 {
-		for i, v := range children {
-			// id may be longer than RayId
-			if strings.HasPrefix(id, v.RayId) {
-				if (len(id) > len(v.RayId)) && (len(v.Children) > 0) {
-					// TODO: Potential bug:
-					// len(v.Chil) == 0 but id is searchable
-
-					// id is longer, keep searching
-					return t.Find(children[i].Children, id)
-				} else {
-					// id lengths match, this is the correct ray node
-					return &children[i]
-				}
-				return &children[i]
-			}
-		}
+// ParentChain returns the ids of every ray from the root camera ray down
+// to the ray with the given id
+func (t *RayTree) ParentChain(id cam.RayID) []cam.RayID {
+	chain := make([]cam.RayID, 0)
+	for node := t.FindNodeByRayId(id); node != nil; node = node.Parent {
+		chain = append(chain, node.RayId)
 	}
 
-	return nil
+	// Reverse so the root comes first
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
 }
 
 func (t *RayTree) FindRootByPixel(x, y int) *RayTreeNode {
 	// TODO: Optimize this by structuring Children in a way that doesn't
 	// force a linear O(theta) = n search
 
-	for i, _ := range t.Children {
-		if t.Children[i].X == x && t.Children[i].Y == y {
-			return &t.Children[i]
+	for _, v := range t.Children {
+		if v.X == x && v.Y == y {
+			return v
 		}
 	}
 
@@ -162,7 +154,7 @@ func (t *RayTree) accumulateNodes(node *RayTreeNode, accumulator *[]string, verb
 		*accumulator = append(*accumulator, shortId)
 		break
 	case 1:
-		*accumulator = append(*accumulator, node.RayId)
+		*accumulator = append(*accumulator, t.chainString(node))
 		break
 	case 2:
 		break
@@ -170,20 +162,26 @@ func (t *RayTree) accumulateNodes(node *RayTreeNode, accumulator *[]string, verb
 		*accumulator = append(*accumulator, node.String())
 		break
 	default:
-		*accumulator = append(*accumulator, node.RayId)
+		*accumulator = append(*accumulator, t.chainString(node))
 	}
 
 	if len(node.Children) > 0 {
 		for _, v := range node.Children {
-			t.accumulateNodes(&v, accumulator, verbosity)
+			t.accumulateNodes(v, accumulator, verbosity)
 		}
 	}
 }
 
 func (t *RayTree) shortenId(node *RayTreeNode) string {
-	parts := strings.Split(node.RayId, "|")
-	for i, v := range parts {
-		parts[i] = v[:8]
+	return strconv.FormatUint(uint64(node.RayId), 10)
+}
+
+// chainString joins the ids from the root down to the node, as "1|5|9"
+func (t *RayTree) chainString(node *RayTreeNode) string {
+	chain := t.ParentChain(node.RayId)
+	parts := make([]string, len(chain), len(chain))
+	for i, v := range chain {
+		parts[i] = strconv.FormatUint(uint64(v), 10)
 	}
 	return strings.Join(parts, "|")
 }
@@ -14,8 +14,8 @@ import (
 func TestNewRayTree(t *testing.T) {
 	t.Parallel()
 
-	ray1 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	ray2 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
+	ray1 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	ray2 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
 	tree := NewTree()
 	tree.AddRoot(0, 0, 0.5, 0.5, ray1)
 	tree.AddRoot(1, 1, 0.75, 0.75, ray2)
@@ -28,13 +28,13 @@ func TestNewRayTree(t *testing.T) {
 func TestMakeSubTree(t *testing.T) {
 	t.Parallel()
 
-	ray1 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	ray2 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
-	sphere1 := obj.Sphere{"sphere1", *vec.NewVec3(0, 0, -5), 1, color.RGBA{0, 0, 255, 1}, 1, 1}
+	ray1 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	ray2 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
+	sphere1 := obj.Sphere{ID: "sphere1", Center: *vec.NewVec3(0, 0, -5), Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1}
 	tree := NewTree()
 	tree.AddRoot(0, 0, 0.5, 0.5, ray1)
 	tree.AddRoot(1, 1, 0.75, 0.75, ray2)
-	ray3 := cam.NewRay(cam.GenerateID(ray1), "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 0.5))
+	ray3 := cam.NewChildRay(ray1, "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 0.5))
 	tree.AddNode(ray3, ray1, sphere1, sphere1)
 
 	if tree.NodeCount != 3 {
@@ -44,13 +44,13 @@ func TestMakeSubTree(t *testing.T) {
 
 func TestFindNodeById(t *testing.T) {
 	t.Parallel()
-	ray1 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	ray2 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
-	ray3 := cam.NewRay(cam.GenerateID(ray1), "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 0.5))
-	ray4 := cam.NewRay(cam.GenerateID(ray3), "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0.5, 0.5))
-	ray5 := cam.NewRay(cam.GenerateID(ray2), "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0.5, 0))
-	sphere1 := obj.Sphere{"sphere1", *vec.NewVec3(0, 0, -5), 1, color.RGBA{0, 0, 255, 1}, 1, 1}
-	sphere2 := obj.Sphere{"sphere2", *vec.NewVec3(0, 0, -8), 1, color.RGBA{0, 0, 255, 1}, 1, 1}
+	ray1 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	ray2 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
+	ray3 := cam.NewChildRay(ray1, "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 0.5))
+	ray4 := cam.NewChildRay(ray3, "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0.5, 0.5))
+	ray5 := cam.NewChildRay(ray2, "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0.5, 0))
+	sphere1 := obj.Sphere{ID: "sphere1", Center: *vec.NewVec3(0, 0, -5), Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1}
+	sphere2 := obj.Sphere{ID: "sphere2", Center: *vec.NewVec3(0, 0, -8), Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1}
 
 	tree := NewTree()
 
@@ -97,8 +97,8 @@ func TestFindNodeById(t *testing.T) {
 
 func TestFindNodeByXY(t *testing.T) {
 	t.Parallel()
-	ray1 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
-	ray2 := cam.NewRay("", "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
+	ray1 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	ray2 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, -1, 0))
 	tree := NewTree()
 
 	tree.AddRoot(0, 0, 0.5, 0.5, ray1)
@@ -113,3 +113,29 @@ func TestFindNodeByXY(t *testing.T) {
 	}
 
 }
+
+func TestParentChain(t *testing.T) {
+	t.Parallel()
+	ray1 := cam.NewRay(0, "camera", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, -1))
+	ray2 := cam.NewChildRay(ray1, "reflection", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0, 0.5))
+	ray3 := cam.NewChildRay(ray2, "refraction", vec.NewVec3(0, 0, 0), vec.NewVec3(0, 0.5, 0.5))
+	sphere1 := obj.Sphere{ID: "sphere1", Center: *vec.NewVec3(0, 0, -5), Radius: 1, Col: color.RGBA{0, 0, 255, 1}, RefractiveIndex: 1}
+
+	tree := NewTree()
+	tree.AddRoot(0, 0, 0.5, 0.5, ray1)
+	tree.AddNode(ray2, ray1, sphere1, sphere1)
+	tree.AddNode(ray3, ray2, sphere1, sphere1)
+
+	if ray3.ParentID != ray2.ID {
+		t.Error("Child ray should keep the id of its parent")
+	}
+
+	chain := tree.ParentChain(ray3.ID)
+	if len(chain) != 3 || chain[0] != ray1.ID || chain[1] != ray2.ID || chain[2] != ray3.ID {
+		t.Error("Parent chain was not reconstructed")
+	}
+
+	if len(tree.ParentChain(ray3.ID+100)) != 0 {
+		t.Error("Unknown ray should have an empty chain")
+	}
+}